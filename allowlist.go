@@ -0,0 +1,112 @@
+package sqlite3
+
+import (
+	"fmt"
+
+	"github.com/rs/rest-layer/schema"
+)
+
+// FilterOp is a filter operator, named like in rest-layer queries.
+type FilterOp string
+
+// Filter operators checked by the filter allow-list. $and and $or are always
+// allowed, their operands are checked.
+const (
+	FilterEqual          FilterOp = "$eq"
+	FilterNotEqual       FilterOp = "$ne"
+	FilterGreaterThan    FilterOp = "$gt"
+	FilterGreaterOrEqual FilterOp = "$gte"
+	FilterLowerThan      FilterOp = "$lt"
+	FilterLowerOrEqual   FilterOp = "$lte"
+	FilterIn             FilterOp = "$in"
+	FilterNotIn          FilterOp = "$nin"
+	FilterExists         FilterOp = "$exists"
+	FilterRegex          FilterOp = "$regex"
+	// FilterWildcard is the operator of Wildcard filters, which have no
+	// rest-layer query syntax.
+	FilterWildcard FilterOp = "$wildcard"
+)
+
+// WithFilterAllowList restricts the filters of Find and Clear lookups to the
+// listed fields and operators, e.g. {"status": {FilterEqual, FilterIn}}.
+// Other filters fail with a descriptive error before any statement is built.
+// It is meant as a second line of defense on top of the Filterable schema
+// flag for publicly exposed resources; the filters the handler builds itself
+// (e.g. on the id for Update) are not checked.
+func WithFilterAllowList(allowed map[string][]FilterOp) Option {
+	return func(h *Handler) {
+		h.allowedFilters = map[string]map[FilterOp]bool{}
+		for field, ops := range allowed {
+			h.allowedFilters[field] = map[FilterOp]bool{}
+			for _, op := range ops {
+				h.allowedFilters[field][op] = true
+			}
+		}
+	}
+}
+
+// checkFilter returns an error if the query uses a field or operator missing
+// from the handler's allow-list.
+func (h *Handler) checkFilter(q schema.Query) error {
+	if h.allowedFilters == nil {
+		return nil
+	}
+	stack := []schema.Expression(q)
+	for len(stack) > 0 {
+		exp := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch t := exp.(type) {
+		case schema.And:
+			stack = append(stack, t...)
+			continue
+		case schema.Or:
+			stack = append(stack, t...)
+			continue
+		}
+		field, op, ok := filterField(exp)
+		if !ok {
+			return fmt.Errorf("sqlite3: filter %T is not allowed", exp)
+		}
+		if _, found := h.allowedFilters[field]; !found {
+			return fmt.Errorf("sqlite3: filtering on field %q is not allowed", field)
+		}
+		if !h.allowedFilters[field][op] {
+			return fmt.Errorf("sqlite3: operator %s is not allowed on field %q", op, field)
+		}
+	}
+	return nil
+}
+
+// filterField returns the field and operator of a comparison expression. ok is
+// false for other expressions.
+func filterField(exp schema.Expression) (field string, op FilterOp, ok bool) {
+	switch t := exp.(type) {
+	case schema.Equal:
+		return t.Field, FilterEqual, true
+	case schema.NotEqual:
+		return t.Field, FilterNotEqual, true
+	case schema.GreaterThan:
+		return t.Field, FilterGreaterThan, true
+	case schema.GreaterOrEqual:
+		return t.Field, FilterGreaterOrEqual, true
+	case schema.LowerThan:
+		return t.Field, FilterLowerThan, true
+	case schema.LowerOrEqual:
+		return t.Field, FilterLowerOrEqual, true
+	case schema.In:
+		return t.Field, FilterIn, true
+	case schema.NotIn:
+		return t.Field, FilterNotIn, true
+	case schema.Exist:
+		return t.Field, FilterExists, true
+	case schema.NotExist:
+		return t.Field, FilterExists, true
+	case schema.Regex:
+		return t.Field, FilterRegex, true
+	case Wildcard:
+		return t.Field, FilterWildcard, true
+	case TextSearch:
+		return SearchField, FilterEqual, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilterAllowList(t *testing.T) {
+	Convey("Filters should be checked against the allow-list", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithFilterAllowList(map[string][]FilterOp{
+			"f1": {FilterEqual, FilterIn},
+			"f2": {FilterGreaterThan},
+		}))
+		So(h.checkFilter(schema.Query{
+			schema.Equal{Field: "f1", Value: "foo"},
+			schema.Or{schema.GreaterThan{Field: "f2", Value: 1}, schema.In{Field: "f1", Values: []schema.Value{"a"}}},
+		}), ShouldBeNil)
+
+		err := h.checkFilter(schema.Query{schema.Equal{Field: "f3", Value: "foo"}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, `sqlite3: filtering on field "f3" is not allowed`)
+
+		err = h.checkFilter(schema.Query{schema.And{schema.Equal{Field: "f1", Value: "foo"}, schema.NotEqual{Field: "f1", Value: "bar"}}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, `sqlite3: operator $ne is not allowed on field "f1"`)
+
+		So(NewHandler(nil, DB_TABLE).checkFilter(schema.Query{schema.Equal{Field: "f3", Value: "foo"}}), ShouldBeNil)
+	})
+
+	Convey("Given stored items and a handler with an allow-list", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		ah := NewHandler(h.session, DB_TABLE, WithFilterAllowList(map[string][]FilterOp{"f1": {FilterEqual}}))
+
+		Convey("Find and Clear should reject other filters", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: 0}})
+			_, err := ah.Find(context.Background(), l, 1, 10)
+			So(err, ShouldNotBeNil)
+			_, err = ah.Clear(context.Background(), l)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Update should still work", func() {
+			updated, _ := resource.NewItem(map[string]interface{}{"id": i1.ID, "created": i1.Payload["created"], "f1": "new", "f2": 1})
+			So(ah.Update(context.Background(), updated, i1), ShouldBeNil)
+		})
+	})
+}
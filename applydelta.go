@@ -0,0 +1,209 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// ApplyResult is the outcome of ApplyDelta.
+type ApplyResult struct {
+	// Applied counts the items written and removed.
+	Applied int
+	// Skipped are the ids of the changes left out because the stored item was
+	// changed at the same time or later.
+	Skipped []interface{}
+}
+
+// ApplyDelta applies the changes of another store, as returned by its
+// FindChangedSince, so that stores synchronize with each other, like the
+// store of an offline client with the API's. The changes are applied in a
+// single transaction, the latest write winning: an item is only written, with
+// its etag and updated time, and a tombstone only removes an item, if the
+// stored item was updated before. Removed items get a tombstone when the
+// handler records them.
+//
+// Like the other writes, ApplyDelta is retried when the database is busy,
+// counts as an update for budgets and metrics, and reports the inserted,
+// updated and removed items as changes.
+//
+// go-sqlite3 doesn't expose SQLite's session extension, so deltas are built
+// from the items and tombstones rather than from changesets.
+func (h *Handler) ApplyDelta(ctx context.Context, d *Delta) (*ApplyResult, error) {
+	start := time.Now()
+	var a *applied
+	err := h.retryBusy(ctx, OpUpdate, func() (err error) {
+		a, err = h.applyDelta(ctx, d)
+		return err
+	})
+	if err != nil {
+		h.observe(OpUpdate, start, 0, err)
+		return nil, err
+	}
+	h.observe(OpUpdate, start, a.result.Applied, nil)
+	for _, c := range []struct {
+		op    Operation
+		items []*resource.Item
+	}{{OpInsert, a.inserted}, {OpUpdate, a.updated}, {OpDelete, a.deleted}} {
+		if len(c.items) > 0 {
+			h.emitItems(c.op, c.items)
+		}
+	}
+	return a.result, nil
+}
+
+// applied is the outcome of applying a delta, with the items it changed.
+type applied struct {
+	result   *ApplyResult
+	inserted []*resource.Item
+	updated  []*resource.Item
+	// deleted are the items removed by tombstones, with their id and etag.
+	deleted []*resource.Item
+}
+
+// applyDelta runs ApplyDelta.
+func (h *Handler) applyDelta(ctx context.Context, d *Delta) (*applied, error) {
+	if err := h.ops.begin(); err != nil {
+		return nil, err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpUpdate)
+	defer cancel()
+
+	txPtr, err := h.beginTx(ctx)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting apply transaction.")
+		return nil, h.storageErr(ctx, ErrExec, OpUpdate, "", err)
+	}
+	a := &applied{result: &ApplyResult{Skipped: []interface{}{}}}
+	for _, i := range d.Items {
+		if err = h.applyItem(ctx, txPtr, i, a); err != nil {
+			txPtr.Rollback()
+			return nil, err
+		}
+	}
+	for _, t := range d.Tombstones {
+		if err = h.applyTombstone(ctx, txPtr, t, a); err != nil {
+			txPtr.Rollback()
+			return nil, err
+		}
+	}
+	if err = txPtr.Commit(); err != nil {
+		log.WithField("error", err).Warn("Error committing apply transaction.")
+		return nil, h.storageErr(ctx, ErrExec, OpUpdate, "", err)
+	}
+	return a, nil
+}
+
+// storedVersion returns the etag and updated time of the stored item of an id
+// literal, and whether there is one. An item of the previous version is moved
+// to the current table first.
+func (h *Handler) storedVersion(ctx context.Context, tx txConn, lit string) (string, time.Time, bool, error) {
+	if h.previous != nil {
+		for _, u := range getUpgrade(h, lit) {
+			if _, err := tx.ExecContext(ctx, h.annotate(ctx, u)); err != nil {
+				return "", time.Time{}, false, ctxErr(ctx, err)
+			}
+		}
+	}
+	col := h.column("etag")
+	if h.etagMode == ETagNone {
+		col = "''"
+	}
+	var etag string
+	var updated interface{}
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s,%s FROM %s WHERE %s;",
+		col, h.column("updated"), h.tableName, h.liveWhere("id = "+lit))).Scan(&etag, &updated)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, false, nil
+	}
+	if err != nil {
+		return "", time.Time{}, false, ctxErr(ctx, err)
+	}
+	t, err := h.timeFormat.parse(updated)
+	return etag, t, true, err
+}
+
+// applyItem writes an item of a delta unless the stored one is as recent.
+func (h *Handler) applyItem(ctx context.Context, tx txConn, i *resource.Item, a *applied) error {
+	lit, err := h.idLiteral(i.ID)
+	if err != nil {
+		return err
+	}
+	etag, updated, found, err := h.storedVersion(ctx, tx, lit)
+	if err != nil {
+		return err
+	}
+	if found && !updated.Before(i.Updated) {
+		a.result.Skipped = append(a.result.Skipped, i.ID)
+		return nil
+	}
+	if found {
+		n, err := h.runUpdate(ctx, tx, i, &resource.Item{ID: i.ID, ETag: etag}, true)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			a.result.Applied++
+			a.updated = append(a.updated, i)
+		}
+		return nil
+	}
+	s, err := getInsert(h, i)
+	if err != nil {
+		return h.storageErr(ctx, ErrStatementBuild, OpInsert, "", err)
+	}
+	if _, err = tx.ExecContext(ctx, h.annotate(ctx, s)); err != nil {
+		if isUniqueErr(err) {
+			// a soft deleted item has the id
+			a.result.Skipped = append(a.result.Skipped, i.ID)
+			return nil
+		}
+		return h.storageErr(ctx, ErrExec, OpInsert, s, err)
+	}
+	a.result.Applied++
+	a.inserted = append(a.inserted, i)
+	return nil
+}
+
+// applyTombstone removes the item of a tombstone unless it was updated after
+// its deletion.
+func (h *Handler) applyTombstone(ctx context.Context, tx txConn, t Tombstone, a *applied) error {
+	lit, err := h.idLiteral(t.ID)
+	if err != nil {
+		return err
+	}
+	_, updated, found, err := h.storedVersion(ctx, tx, lit)
+	if err != nil || !found {
+		return err
+	}
+	if updated.After(t.Deleted) {
+		a.result.Skipped = append(a.result.Skipped, t.ID)
+		return nil
+	}
+	where := "id = " + lit
+	s := fmt.Sprintf("DELETE FROM %s WHERE %s;", h.tableName, where)
+	if h.softDelete {
+		s = h.softDeleteStatement(h.tableName, where)
+	}
+	if err = h.archive(ctx, tx, h.liveWhere(where)); err == nil {
+		_, err = tx.ExecContext(ctx, h.annotate(ctx, s))
+	}
+	if err == nil && h.tombstones {
+		etag, _ := valueToString(t.ETag)
+		_, err = tx.ExecContext(ctx, h.annotate(ctx, fmt.Sprintf("INSERT INTO %s(id,etag,deleted) VALUES(%s,%s,%s);",
+			tombstoneTable(h), lit, etag, h.timeFormat.literal(t.Deleted))))
+	}
+	if err != nil {
+		return h.storageErr(ctx, ErrExec, OpDelete, s, err)
+	}
+	a.result.Applied++
+	a.deleted = append(a.deleted, &resource.Item{ID: t.ID, ETag: t.ETag})
+	return nil
+}
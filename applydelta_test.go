@@ -0,0 +1,141 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// remote returns a copy of a stored item changed by another store at the
+// given time.
+func remote(i *resource.Item, f1 string, updated time.Time) *resource.Item {
+	r, _ := item(f1, 9)
+	r.ID = i.ID
+	r.Payload["id"] = i.ID
+	r.ETag = "remote-" + f1
+	r.Updated = updated
+	return r
+}
+
+func TestApplyDelta(t *testing.T) {
+	Convey("Given a handler recording tombstones", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		th := NewHandler(h.session, DB_TABLE, WithTombstones())
+		h.session.Exec("DROP TABLE `" + tombstoneTable(th) + "`;")
+		So(th.CreateTombstoneTable(context.Background()), ShouldBeNil)
+		a, _ := item("a", 1)
+		b, _ := item("b", 2)
+		now := time.Now()
+		a.Updated, b.Updated = now, now
+		So(th.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+		ctx := context.Background()
+
+		find := func(id interface{}) *resource.Item {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "id", Value: id}})
+			list, err := th.Find(ctx, l, 1, 1)
+			So(err, ShouldBeNil)
+			if len(list.Items) == 0 {
+				return nil
+			}
+			return list.Items[0]
+		}
+
+		Convey("ApplyDelta should write newer and new items", func() {
+			n, _ := item("n", 3)
+			n.ETag = "remote-n"
+			n.Updated = now
+			r, err := th.ApplyDelta(ctx, &Delta{Items: []*resource.Item{remote(a, "a2", now.Add(time.Hour)), n}})
+			So(err, ShouldBeNil)
+			So(r.Applied, ShouldEqual, 2)
+			So(r.Skipped, ShouldBeEmpty)
+			So(find(a.ID).Payload["f1"], ShouldEqual, "a2")
+			So(find(a.ID).ETag, ShouldEqual, "remote-a2")
+			So(find(n.ID).ETag, ShouldEqual, "remote-n")
+		})
+
+		Convey("ApplyDelta should skip items stored with a later change", func() {
+			r, err := th.ApplyDelta(ctx, &Delta{Items: []*resource.Item{remote(a, "a2", now.Add(-time.Hour))}})
+			So(err, ShouldBeNil)
+			So(r.Applied, ShouldEqual, 0)
+			So(r.Skipped, ShouldResemble, []interface{}{a.ID})
+			So(find(a.ID).Payload["f1"], ShouldEqual, "a")
+		})
+
+		Convey("ApplyDelta should remove items deleted after their last change", func() {
+			r, err := th.ApplyDelta(ctx, &Delta{Tombstones: []Tombstone{
+				{ID: a.ID, ETag: "x", Deleted: now.Add(time.Hour)},
+				{ID: b.ID, ETag: "y", Deleted: now.Add(-time.Hour)},
+			}})
+			So(err, ShouldBeNil)
+			So(r.Applied, ShouldEqual, 1)
+			So(r.Skipped, ShouldResemble, []interface{}{b.ID})
+			So(find(a.ID), ShouldBeNil)
+			So(find(b.ID), ShouldNotBeNil)
+			ts, err := th.Tombstones(ctx, now)
+			So(err, ShouldBeNil)
+			So(len(ts), ShouldEqual, 1)
+			So(ts[0].ETag, ShouldEqual, "x")
+		})
+
+		Convey("ApplyDelta should apply a delta of another handler", func() {
+			So(th.Delete(ctx, b), ShouldBeNil)
+			d, err := th.FindChangedSince(ctx, now.Add(-time.Minute), 0)
+			So(err, ShouldBeNil)
+			h.session.Exec(DB_DOWN_DDL)
+			h.session.Exec(DB_UP_DDL)
+			h.session.Exec("DELETE FROM `" + tombstoneTable(th) + "`;")
+			So(th.Insert(ctx, []*resource.Item{b}), ShouldBeNil)
+			r, err := th.ApplyDelta(ctx, d)
+			So(err, ShouldBeNil)
+			So(r.Applied, ShouldEqual, 2)
+			So(find(a.ID), ShouldNotBeNil)
+			So(find(b.ID), ShouldBeNil)
+		})
+
+		Convey("ApplyDelta should report the changes it applied", func() {
+			var changes []Change
+			ch := NewHandler(h.session, DB_TABLE, WithTombstones(), WithChangeHook(func(c Change) {
+				changes = append(changes, c)
+			}))
+			n, _ := item("n", 3)
+			n.Updated = now
+			_, err := ch.ApplyDelta(ctx, &Delta{
+				Items:      []*resource.Item{remote(a, "a2", now.Add(time.Hour)), n},
+				Tombstones: []Tombstone{{ID: b.ID, ETag: "y", Deleted: now.Add(time.Hour)}},
+			})
+			So(err, ShouldBeNil)
+			So(len(changes), ShouldEqual, 3)
+			So(changes[0].Op, ShouldEqual, OpInsert)
+			So(changes[0].IDs, ShouldResemble, []interface{}{n.ID})
+			So(changes[1].Op, ShouldEqual, OpUpdate)
+			So(changes[1].IDs, ShouldResemble, []interface{}{a.ID})
+			So(changes[2].Op, ShouldEqual, OpDelete)
+			So(changes[2].IDs, ShouldResemble, []interface{}{b.ID})
+		})
+
+		Convey("ApplyDelta should run in the shared transaction", func() {
+			tx, err := h.session.Begin()
+			So(err, ShouldBeNil)
+			_, err = th.WithTx(tx).ApplyDelta(ctx, &Delta{Items: []*resource.Item{remote(a, "a2", now.Add(time.Hour))}})
+			So(err, ShouldBeNil)
+			So(tx.Rollback(), ShouldBeNil)
+			So(find(a.ID).Payload["f1"], ShouldEqual, "a")
+		})
+
+		Convey("ApplyDelta should be refused by a drained handler", func() {
+			So(th.Drain(ctx), ShouldBeNil)
+			_, err := th.ApplyDelta(ctx, &Delta{Items: []*resource.Item{remote(a, "a2", now.Add(time.Hour))}})
+			So(err, ShouldEqual, ErrDraining)
+		})
+	})
+}
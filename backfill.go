@@ -0,0 +1,111 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultBackfillBatch is the batch size of Backfill when none is given.
+const DefaultBackfillBatch = 1000
+
+// Expr is a SQL expression written as is in a statement, e.g.
+// Expr("lower(name)"), where a value would otherwise be quoted. Never build
+// one from client input.
+type Expr string
+
+// BackfillProgress is called by Backfill after each batch with the number of
+// rows filled so far.
+type BackfillProgress func(filled int)
+
+// Backfill sets the column of a field to value in the rows where it is NULL,
+// typically after Migrate added the column of a new field with a default.
+// value is either a Go value, quoted like filter values, or an Expr evaluated
+// for each row. Rows are updated in batches of batchSize, each in its own
+// transaction, so the table is never locked for long; progress, if not nil,
+// is called after each batch. Rows are visited once, in rowid order, so an
+// expression evaluating to NULL can't make Backfill loop. Etags and updated
+// timestamps are left unchanged. It returns the number of rows filled.
+// Only fields stored in their own column can be backfilled.
+func (h *Handler) Backfill(ctx context.Context, field string, value interface{}, batchSize int, progress BackfillProgress) (int, error) {
+	col, skip, err := h.payloadColumn(field)
+	if err != nil {
+		return 0, err
+	}
+	if skip || !identRe.MatchString(col) || !h.hasColumn(field) {
+		return 0, fmt.Errorf("sqlite3: can't backfill field %q", field)
+	}
+	v, ok := value.(Expr)
+	if !ok {
+		s, err := valueToString(value)
+		if err != nil {
+			return 0, err
+		}
+		v = Expr(s)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatch
+	}
+
+	sel := fmt.Sprintf("SELECT MAX(rowid) FROM (SELECT rowid FROM %s WHERE rowid > ? AND %s IS NULL ORDER BY rowid LIMIT ?);",
+		h.tableName, col)
+	upd := fmt.Sprintf("UPDATE %s SET %s = %s WHERE rowid > ? AND rowid <= ? AND %s IS NULL;",
+		h.tableName, col, v, col)
+	if err := h.ops.begin(); err != nil {
+		return 0, err
+	}
+	defer h.ops.end()
+
+	var last int64
+	filled := 0
+	for {
+		n, next, err := h.backfillBatch(ctx, sel, upd, last, batchSize)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"table": h.tableName,
+				"field": field,
+				"error": err,
+			}).Warn("Error backfilling column.")
+			return filled, ctxErr(ctx, err)
+		}
+		if next == 0 {
+			return filled, nil
+		}
+		last = next
+		filled += n
+		if progress != nil {
+			progress(filled)
+		}
+	}
+}
+
+// backfillBatch fills the next batch of rows after the last rowid. It returns
+// the number of rows filled and the last rowid of the batch, or 0 if there
+// are no rows left.
+func (h *Handler) backfillBatch(ctx context.Context, sel, upd string, last int64, batchSize int) (int, int64, error) {
+	txPtr, err := h.beginTx(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var next *int64
+	var n int64
+	err = txPtr.QueryRowContext(ctx, h.annotate(ctx, sel), last, batchSize).Scan(&next)
+	if err == nil && next != nil {
+		var result sql.Result
+		if result, err = txPtr.ExecContext(ctx, h.annotate(ctx, upd), last, *next); err == nil {
+			n, err = result.RowsAffected()
+		}
+	}
+	if err != nil {
+		txPtr.Rollback()
+		return 0, 0, err
+	}
+	if err = txPtr.Commit(); err != nil || next == nil {
+		return 0, 0, err
+	}
+	return int(n), *next, nil
+}
@@ -0,0 +1,65 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackfill(t *testing.T) {
+	Convey("Given stored items with an empty column", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		items := []*resource.Item{}
+		for n := 0; n < 5; n++ {
+			it, _ := item("foo", n)
+			items = append(items, it)
+		}
+		So(h.Insert(context.Background(), items), ShouldBeNil)
+		_, err = h.session.Exec("UPDATE " + DB_TABLE + " SET f1 = NULL WHERE f2 > 0")
+		So(err, ShouldBeNil)
+
+		count := func(where string) int {
+			var n int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE+" WHERE "+where).Scan(&n), ShouldBeNil)
+			return n
+		}
+
+		Convey("Backfill should fill a value in batches", func() {
+			progress := []int{}
+			n, err := h.Backfill(context.Background(), "f1", "it's", 3, func(filled int) {
+				progress = append(progress, filled)
+			})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 4)
+			So(progress, ShouldResemble, []int{3, 4})
+			So(count("f1 = 'it''s'"), ShouldEqual, 4)
+			So(count("f1 = 'foo'"), ShouldEqual, 1)
+		})
+
+		Convey("Backfill should evaluate an expression per row", func() {
+			n, err := h.Backfill(context.Background(), "f1", Expr("'v' || f2"), 0, nil)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 4)
+			So(count("f1 = 'v' || f2"), ShouldEqual, 4)
+		})
+
+		Convey("Backfill should stop on an expression evaluating to NULL", func() {
+			n, err := h.Backfill(context.Background(), "f1", Expr("NULL"), 2, nil)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 4)
+			So(count("f1 IS NULL"), ShouldEqual, 4)
+		})
+
+		Convey("Backfill should reject invalid fields", func() {
+			_, err := h.Backfill(context.Background(), "f1; DROP TABLE x", 1, 0, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,131 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrInvalidBackup is returned by RestoreBackup when the snapshot is corrupted
+// or doesn't have the handler's table.
+var ErrInvalidBackup = errors.New("sqlite3: invalid backup")
+
+// Backup copies the handler's database into the file at dest with SQLite's
+// online backup API, while it is in use. An existing file is overwritten.
+func (h *Handler) Backup(ctx context.Context, dest string) error {
+	db, err := sql.Open(DriverName, dest)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err = copyDatabase(ctx, db, h.session); err != nil {
+		log.WithFields(log.Fields{
+			"dest":  dest,
+			"error": err,
+		}).Warn("Error backing up the database.")
+		return ctxErr(ctx, err)
+	}
+	return nil
+}
+
+// RestoreBackup replaces the content of the handler's database with the
+// snapshot at src, as made by Backup. The snapshot is checked first: it must
+// pass SQLite's integrity check and have the handler's table, or
+// ErrInvalidBackup is returned and the database is left untouched. It is then
+// loaded into the open database with the online backup API, atomically for
+// the other connections, and the handler's pragmas are applied again.
+func (h *Handler) RestoreBackup(ctx context.Context, src string) error {
+	if h.tx != nil {
+		return errors.New("sqlite3: can't restore a backup in a shared transaction")
+	}
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if err := h.ops.begin(); err != nil {
+		return err
+	}
+	defer h.ops.end()
+
+	snap, err := sql.Open(DriverName, "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+	if err = h.checkBackup(ctx, snap); err != nil {
+		log.WithFields(log.Fields{
+			"src":   src,
+			"error": err,
+		}).Warn("Rejected backup.")
+		return err
+	}
+
+	if err = copyDatabase(ctx, h.session, snap); err != nil {
+		log.WithFields(log.Fields{
+			"src":   src,
+			"error": err,
+		}).Warn("Error restoring the database.")
+		return ctxErr(ctx, err)
+	}
+
+	// what was learned about the previous content is outdated
+	h.created.mu.Lock()
+	h.created.known = false
+	h.created.mu.Unlock()
+	if h.etagCache != nil {
+		h.etagCache.Purge()
+	}
+	if len(h.pragmas) > 0 {
+		return h.configurePool(ctx)
+	}
+	return nil
+}
+
+// checkBackup returns ErrInvalidBackup if a snapshot is corrupted or doesn't
+// have the handler's table.
+func (h *Handler) checkBackup(ctx context.Context, snap *sql.DB) error {
+	var result string
+	if err := snap.QueryRowContext(ctx, "PRAGMA integrity_check;").Scan(&result); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// not even a database
+		return ErrInvalidBackup
+	}
+	if result != "ok" {
+		return ErrInvalidBackup
+	}
+	var n int
+	err := snap.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;", h.tableName).Scan(&n)
+	if err != nil {
+		return ctxErr(ctx, err)
+	}
+	if n == 0 {
+		return ErrInvalidBackup
+	}
+	return nil
+}
+
+// copyDatabase copies the main database of src over the one of dest with
+// SQLite's online backup API, in a single step.
+func copyDatabase(ctx context.Context, dest, src *sql.DB) error {
+	dc, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+	sc, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	return dc.Raw(func(d interface{}) error {
+		return sc.Raw(func(s interface{}) error {
+			return backupConns(d, s)
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackup(t *testing.T) {
+	if DriverImpl != "go-sqlite3" {
+		t.Skip("backups need the go-sqlite3 driver")
+	}
+	Convey("Given a handler with stored items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+		i1, _ := item("foo", 1)
+		So(h.Insert(ctx, []*resource.Item{i1}), ShouldBeNil)
+		dir, err := ioutil.TempDir("", "backup")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		snapshot := dir + "/snapshot.db"
+
+		Convey("A restored backup should bring the items back", func() {
+			So(h.Backup(ctx, snapshot), ShouldBeNil)
+			So(h.Delete(ctx, i1), ShouldBeNil)
+			i2, _ := item("bar", 2)
+			So(h.Insert(ctx, []*resource.Item{i2}), ShouldBeNil)
+
+			So(h.RestoreBackup(ctx, snapshot), ShouldBeNil)
+			list, err := h.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].ID, ShouldEqual, i1.ID)
+		})
+
+		Convey("Invalid backups should be rejected", func() {
+			So(ioutil.WriteFile(snapshot, []byte("not a database"), 0644), ShouldBeNil)
+			So(h.RestoreBackup(ctx, snapshot), ShouldEqual, ErrInvalidBackup)
+
+			other := dir + "/other.db"
+			db, err := sql.Open(DriverName, other)
+			So(err, ShouldBeNil)
+			_, err = db.Exec("CREATE TABLE other (id VARCHAR(128));")
+			So(err, ShouldBeNil)
+			db.Close()
+			So(h.RestoreBackup(ctx, other), ShouldEqual, ErrInvalidBackup)
+
+			list, err := h.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+		})
+	})
+}
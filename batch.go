@@ -0,0 +1,219 @@
+package sqlite3
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// WithInsertBatching groups the Insert calls arriving within window of each
+// other into a single transaction, so a sustained flow of small inserts pays
+// for one commit per batch instead of one per call. A batch is written when
+// its window is over, or as soon as it holds maxItems items if maxItems is
+// positive. Each call still succeeds or fails on its own: the items of a
+// failing call are rolled back without affecting the rest of the batch.
+// Inserts wait for at most window before being written, or until their
+// context is done: the call then returns the context's error, and its items
+// are left out of the batch unless it was already being written. A window of
+// 0 disables batching.
+func WithInsertBatching(window time.Duration, maxItems int) Option {
+	return func(h *Handler) {
+		if window > 0 {
+			h.batcher = &insertBatcher{window: window, maxItems: maxItems}
+		} else {
+			h.batcher = nil
+		}
+	}
+}
+
+// insertBatcher groups concurrent Insert calls into batches.
+type insertBatcher struct {
+	window   time.Duration
+	maxItems int
+
+	mu sync.Mutex
+	// open is the batch accepting new calls, if any
+	open *insertBatch
+}
+
+// insertBatch is a group of Insert calls written in a single transaction.
+type insertBatch struct {
+	calls []*insertCall
+	items int
+	// full is closed when the batch reaches maxItems items
+	full chan struct{}
+	// done is closed when the batch was written
+	done chan struct{}
+}
+
+// insertCall is an Insert call of a batch and its result.
+type insertCall struct {
+	ctx   context.Context
+	items []*resource.Item
+	err   error
+	// end is called once the batch was written
+	end func()
+}
+
+// add adds the items to the open batch, or opens a new one, and returns the
+// result of the call once the batch was written, or the error of ctx if it is
+// done first. The first call of a batch starts flushing it. end is called
+// once the batch was written, even if the call returned first.
+func (b *insertBatcher) add(ctx context.Context, items []*resource.Item, write func([]*insertCall), end func()) error {
+	c := &insertCall{ctx: ctx, items: items, end: end}
+
+	b.mu.Lock()
+	batch := b.open
+	first := batch == nil
+	if first {
+		batch = &insertBatch{full: make(chan struct{}), done: make(chan struct{})}
+		b.open = batch
+	}
+	batch.calls = append(batch.calls, c)
+	batch.items += len(items)
+	if b.maxItems > 0 && batch.items >= b.maxItems {
+		// stop accepting calls, and write the batch now
+		b.open = nil
+		close(batch.full)
+	}
+	b.mu.Unlock()
+
+	if first {
+		go b.flush(batch, write)
+	}
+	select {
+	case <-batch.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush waits for the end of the window of a batch, or for the batch to be
+// full, then writes it with write.
+func (b *insertBatcher) flush(batch *insertBatch, write func([]*insertCall)) {
+	t := time.NewTimer(b.window)
+	select {
+	case <-t.C:
+	case <-batch.full:
+		t.Stop()
+	}
+	b.mu.Lock()
+	if b.open == batch {
+		b.open = nil
+	}
+	b.mu.Unlock()
+
+	write(batch.calls)
+	close(batch.done)
+	for _, c := range batch.calls {
+		c.end()
+	}
+}
+
+// batchInsert runs Insert through the batcher.
+func (h *Handler) batchInsert(ctx context.Context, items []*resource.Item) error {
+	if err := h.ops.begin(); err != nil {
+		return err
+	}
+
+	for _, i := range items {
+		if err := h.setTimestamps(ctx, i); err != nil {
+			h.ops.end()
+			log.WithField("error", err).Warn("Error setting timestamps.")
+			return ctxErr(ctx, err)
+		}
+		if err := h.setETag(i); err != nil {
+			h.ops.end()
+			log.WithField("error", err).Warn("Error computing ETag.")
+			return err
+		}
+	}
+	// the operation lasts until the batch is written, so Drain waits for it
+	return h.batcher.add(ctx, items, h.writeBatch, h.ops.end)
+}
+
+// writeBatch writes a batch of Insert calls in a single transaction, and sets
+// the result of each call. Calls whose context is done are left out.
+func (h *Handler) writeBatch(calls []*insertCall) {
+	live := make([]*insertCall, 0, len(calls))
+	for _, c := range calls {
+		if c.err = c.ctx.Err(); c.err == nil {
+			live = append(live, c)
+		}
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	// the batch outlives the context of the call which wrote it
+	ctx, cancel := h.withBudget(context.Background(), OpInsert)
+	defer cancel()
+	err := h.retryBusy(ctx, OpInsert, func() error {
+		return h.insertCalls(ctx, live)
+	})
+	for _, c := range live {
+		if err != nil {
+			c.err = ctxErr(ctx, err)
+		} else if c.err == nil {
+			for _, i := range c.items {
+				h.cacheETag(i.ID, i.ETag)
+			}
+		}
+	}
+}
+
+// insertCalls runs the inserts of the calls in an immediate transaction, each
+// call within a savepoint rolled back if one of its inserts fails. It returns
+// an error if the transaction as a whole failed.
+func (h *Handler) insertCalls(ctx context.Context, calls []*insertCall) error {
+	tx, err := h.beginImmediate(ctx)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting insert batch transaction.")
+		return h.storageErr(ctx, ErrExec, OpInsert, "", err)
+	}
+	for _, c := range calls {
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT batch;"); err != nil {
+			tx.Rollback()
+			return h.storageErr(ctx, ErrExec, OpInsert, "SAVEPOINT batch;", err)
+		}
+		if c.err = h.insertCall(ctx, tx, c); c.err != nil {
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO batch;"); err != nil {
+				tx.Rollback()
+				return h.storageErr(ctx, ErrExec, OpInsert, "ROLLBACK TO batch;", err)
+			}
+		}
+		if _, err = tx.ExecContext(ctx, "RELEASE batch;"); err != nil {
+			tx.Rollback()
+			return h.storageErr(ctx, ErrExec, OpInsert, "RELEASE batch;", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		log.WithField("error", err).Warn("Error committing insert batch.")
+		return h.storageErr(ctx, ErrExec, OpInsert, "", err)
+	}
+	return nil
+}
+
+// insertCall runs the inserts of a call of a batch.
+func (h *Handler) insertCall(ctx context.Context, tx txConn, c *insertCall) error {
+	for _, i := range c.items {
+		s, err := getInsert(h, i)
+		if err != nil {
+			log.WithField("error", err).Warn("Error creating insert statement.")
+			return h.storageErr(ctx, ErrStatementBuild, OpInsert, "", err)
+		}
+		if _, err = tx.ExecContext(ctx, h.annotate(c.ctx, s)); err != nil {
+			if err = h.replayed(ctx, tx, i, err); err == nil {
+				continue
+			}
+			log.WithField("error", err).Warn("Error executing insert statement.")
+			return h.storageErr(ctx, ErrExec, OpInsert, s, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package sqlite3
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInsertBatching(t *testing.T) {
+	Convey("Given a handler batching inserts", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		c := &recordingCollector{}
+		bh := NewHandler(h.session, DB_TABLE, WithInsertBatching(20*time.Millisecond, 0), WithCollector(c))
+		ctx := context.Background()
+
+		Convey("Concurrent inserts should all be stored", func() {
+			var wg sync.WaitGroup
+			errs := make([]error, 10)
+			for n := range errs {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					i, _ := item("foo", n)
+					errs[n] = bh.Insert(ctx, []*resource.Item{i})
+				}(n)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				So(err, ShouldBeNil)
+			}
+			var count int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE+";").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 10)
+			So(len(c.obs), ShouldEqual, 10)
+		})
+
+		Convey("A failing insert should not fail the rest of its batch", func() {
+			i1, _ := item("foo", 1)
+			So(bh.Insert(ctx, []*resource.Item{i1}), ShouldBeNil)
+
+			dup, _ := item("bar", 2)
+			dup.ID = i1.ID
+			dup.Payload["id"] = i1.ID
+			i2, _ := item("baz", 3)
+			var wg sync.WaitGroup
+			var errDup, err2 error
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				errDup = bh.Insert(ctx, []*resource.Item{dup})
+			}()
+			go func() {
+				defer wg.Done()
+				err2 = bh.Insert(ctx, []*resource.Item{i2})
+			}()
+			wg.Wait()
+			So(errDup, ShouldNotBeNil)
+			So(err2, ShouldBeNil)
+			var count int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE+";").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 2)
+		})
+
+		Convey("A full batch should be written before the end of its window", func() {
+			fh := NewHandler(h.session, DB_TABLE, WithInsertBatching(time.Hour, 2))
+			i1, _ := item("foo", 1)
+			i2, _ := item("bar", 2)
+			So(fh.Insert(ctx, []*resource.Item{i1, i2}), ShouldBeNil)
+		})
+
+		Convey("An insert should not wait past its deadline", func() {
+			wh := NewHandler(h.session, DB_TABLE, WithInsertBatching(100*time.Millisecond, 0))
+			i1, _ := item("foo", 1)
+			dctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			So(wh.Insert(dctx, []*resource.Item{i1}), ShouldEqual, context.DeadlineExceeded)
+			So(time.Since(start), ShouldBeLessThan, 100*time.Millisecond)
+			// the batch is written without the expired call
+			time.Sleep(150 * time.Millisecond)
+			var count int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE+";").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("Failed batches should return storage errors", func() {
+			mh := NewHandler(h.session, "missing", WithInsertBatching(time.Millisecond, 0))
+			i1, _ := item("foo", 1)
+			err := mh.Insert(ctx, []*resource.Item{i1})
+			So(errors.Is(err, ErrExec), ShouldBeTrue)
+			So(err.(*StorageError).Op, ShouldEqual, OpInsert)
+		})
+	})
+}
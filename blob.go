@@ -0,0 +1,50 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"strings"
+)
+
+// BlobMode controls how the values of BLOB columns are returned.
+type BlobMode int
+
+const (
+	// BlobBytes returns the values of BLOB columns as []byte payload values.
+	// This is the default.
+	BlobBytes BlobMode = iota
+	// BlobBase64 returns the values of BLOB columns as standard base64
+	// strings, as they are encoded in JSON responses.
+	BlobBase64
+)
+
+// WithBlobMode sets how the values of BLOB columns are returned. []byte
+// payload values are always stored as blobs, in columns declared BLOB so they
+// can be told apart from text on read.
+func WithBlobMode(m BlobMode) Option {
+	return func(h *Handler) {
+		h.blobMode = m
+	}
+}
+
+// blobColumns reports which columns of a result are declared BLOB. Byte
+// arrays read from other columns are text.
+func blobColumns(rows *sql.Rows, n int) []bool {
+	blobs := make([]bool, n)
+	types, err := rows.ColumnTypes()
+	if err != nil || len(types) != n {
+		return blobs
+	}
+	for i, t := range types {
+		blobs[i] = strings.Contains(strings.ToUpper(t.DatabaseTypeName()), "BLOB")
+	}
+	return blobs
+}
+
+// blobValue converts the value read from a BLOB column to a payload value.
+func (h *Handler) blobValue(b []byte) interface{} {
+	if h.blobMode == BlobBase64 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return b
+}
@@ -0,0 +1,56 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBlobs(t *testing.T) {
+	Convey("Password fields should be stored in BLOB columns", t, func() {
+		s := schema.Schema{"hash": schema.Field{Validator: &schema.Password{}}}
+		So(TableDDL("t", s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`hash` BLOB);")
+	})
+
+	Convey("Given a table with a BLOB column", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE blobs;")
+		_, err = h.session.Exec("CREATE TABLE blobs (id VARCHAR(128) PRIMARY KEY, etag VARCHAR(128), updated VARCHAR(128), f1 TEXT, data BLOB);")
+		So(err, ShouldBeNil)
+		defer h.session.Exec("DROP TABLE blobs;")
+		bh := NewHandler(h.session, "blobs")
+		data := []byte{0, 1, 'a', 0xff, '\''}
+		i, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "foo", "data": data})
+		So(bh.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+
+		Convey("Find should return the bytes as stored", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "data", Value: data}})
+			list, err := bh.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].Payload["data"], ShouldResemble, data)
+			So(list.Items[0].Payload["f1"], ShouldEqual, "foo")
+		})
+
+		Convey("Updates should replace the bytes", func() {
+			n, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "foo", "data": []byte("new")})
+			So(bh.Update(context.Background(), n, i), ShouldBeNil)
+			list, err := bh.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(list.Items[0].Payload["data"], ShouldResemble, []byte("new"))
+		})
+
+		Convey("BlobBase64 handlers should return base64 strings", func() {
+			b64 := NewHandler(h.session, "blobs", WithBlobMode(BlobBase64))
+			list, err := b64.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(list.Items[0].Payload["data"], ShouldEqual, "AAFh/yc=")
+		})
+	})
+}
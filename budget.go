@@ -0,0 +1,38 @@
+package sqlite3
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Operation identifies a kind of handler operation in per-operation settings.
+type Operation string
+
+// Operations of the handler. OpImport covers bulk loads.
+const (
+	OpFind   Operation = "find"
+	OpInsert Operation = "insert"
+	OpUpdate Operation = "update"
+	OpDelete Operation = "delete"
+	OpClear  Operation = "clear"
+	OpImport Operation = "import"
+)
+
+// WithTimeout sets the time budget of an operation. The budget applies on top
+// of any deadline of the request context, so an admin Clear can be allowed to
+// run longer than interactive reads. A zero duration removes the budget.
+func WithTimeout(op Operation, d time.Duration) Option {
+	return func(h *Handler) {
+		h.timeouts[op] = d
+	}
+}
+
+// withBudget returns a context bounded by the time budget of the operation.
+// The returned cancel function must always be called.
+func (h *Handler) withBudget(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	if d := h.timeouts[op]; d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
@@ -0,0 +1,42 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBudgets(t *testing.T) {
+	Convey("Budgets should only apply to their operation", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithTimeout(OpClear, time.Minute))
+
+		ctx, cancel := h.withBudget(context.Background(), OpClear)
+		defer cancel()
+		_, ok := ctx.Deadline()
+		So(ok, ShouldBeTrue)
+
+		ctx, cancel = h.withBudget(context.Background(), OpFind)
+		defer cancel()
+		_, ok = ctx.Deadline()
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Operations exceeding their budget should fail", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		bh := NewHandler(h.session, DB_TABLE, WithTimeout(OpFind, time.Nanosecond))
+
+		_, err = bh.Find(context.Background(), resource.NewLookup(), 1, 10)
+		So(err, ShouldEqual, context.DeadlineExceeded)
+
+		_, err = h.Find(context.Background(), resource.NewLookup(), 1, 10)
+		So(err, ShouldBeNil)
+	})
+}
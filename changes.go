@@ -0,0 +1,140 @@
+package sqlite3
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// Change describes a committed Insert, Update, Delete or Clear, so
+// applications can invalidate caches, push notifications or index items
+// without polling.
+type Change struct {
+	Table string
+	Op    Operation
+	// IDs are the ids of the affected items.
+	IDs []interface{}
+	// Items are the items as written by Insert and Update, or as given to
+	// Delete. Clear only reports ids. They must not be modified.
+	Items []*resource.Item
+}
+
+// ChangeFunc is called with each change committed by a handler.
+type ChangeFunc func(Change)
+
+// WithChangeHook adds a function called with each change committed by the
+// handler, in the goroutine of the write, once it returned. It should return
+// quickly, as the write waits for it.
+func WithChangeHook(fn ChangeFunc) Option {
+	return func(h *Handler) {
+		h.changes.mu.Lock()
+		h.changes.hooks = append(h.changes.hooks, fn)
+		h.changes.mu.Unlock()
+	}
+}
+
+// changeHub dispatches the changes of a handler to its hooks and
+// subscribers. It is shared by copies of the handler.
+type changeHub struct {
+	mu    sync.Mutex
+	hooks []ChangeFunc
+	subs  map[chan Change]bool
+}
+
+// Subscribe returns a channel receiving the changes committed by the handler
+// from now on, and a function ending the subscription and closing the
+// channel. Writes don't wait for subscribers: changes are dropped when the
+// channel's buffer is full.
+func (h *Handler) Subscribe(buffer int) (<-chan Change, func()) {
+	c := make(chan Change, buffer)
+	h.changes.mu.Lock()
+	if h.changes.subs == nil {
+		h.changes.subs = map[chan Change]bool{}
+	}
+	h.changes.subs[c] = true
+	h.changes.mu.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			h.changes.mu.Lock()
+			delete(h.changes.subs, c)
+			h.changes.mu.Unlock()
+			close(c)
+		})
+	}
+}
+
+// active reports whether anyone listens to the changes.
+func (hub *changeHub) active() bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.hooks) > 0 || len(hub.subs) > 0
+}
+
+// emit sends a change to the hooks and subscribers.
+func (hub *changeHub) emit(c Change) {
+	hub.mu.Lock()
+	hooks := hub.hooks
+	for sub := range hub.subs {
+		select {
+		case sub <- c:
+		default:
+			log.WithFields(log.Fields{
+				"table": c.Table,
+				"op":    c.Op,
+			}).Warn("Change dropped for a slow subscriber.")
+		}
+	}
+	hub.mu.Unlock()
+	for _, fn := range hooks {
+		fn(c)
+	}
+}
+
+// emitItems sends the change of a write of items.
+func (h *Handler) emitItems(op Operation, items []*resource.Item) {
+	if !h.changes.active() {
+		return
+	}
+	ids := make([]interface{}, len(items))
+	for n, i := range items {
+		ids[n] = i.ID
+	}
+	h.changes.emit(Change{Table: h.tableName, Op: op, IDs: ids, Items: items})
+}
+
+// selectIDs returns the ids of the rows matching a WHERE clause, for changes
+// whose items aren't known.
+func selectIDs(ctx context.Context, h *Handler, db querier, where string) ([]interface{}, error) {
+	q := "SELECT id FROM " + h.tableName
+	if where != "" {
+		q += " WHERE " + where
+	}
+	rows, err := db.QueryContext(ctx, h.annotate(ctx, q+";"))
+	if err != nil {
+		log.WithField("error", err).Warn("Error selecting changed ids.")
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []interface{}{}
+	for rows.Next() {
+		var raw interface{}
+		if err = rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		if b, ok := raw.([]byte); ok {
+			raw = string(b)
+		}
+		id, err := h.idCodec.Decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite3: invalid id %v: %v", raw, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,56 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChanges(t *testing.T) {
+	Convey("Given a handler with a change hook and a subscriber", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		hooked := []Change{}
+		ch := NewHandler(h.session, DB_TABLE, WithChangeHook(func(c Change) {
+			hooked = append(hooked, c)
+		}))
+		sub, cancel := ch.Subscribe(10)
+		ctx := context.Background()
+
+		Convey("Committed writes should be reported", func() {
+			i1, _ := item("foo", 1)
+			i2, _ := item("bar", 2)
+			So(ch.Insert(ctx, []*resource.Item{i1, i2}), ShouldBeNil)
+			So(ch.Delete(ctx, i1), ShouldBeNil)
+			So(ch.Delete(ctx, i1), ShouldEqual, resource.ErrNotFound)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "bar"}})
+			_, err := ch.Clear(ctx, l)
+			So(err, ShouldBeNil)
+
+			So(hooked, ShouldHaveLength, 3)
+			So(hooked[0].Op, ShouldEqual, OpInsert)
+			So(hooked[0].IDs, ShouldResemble, []interface{}{i1.ID, i2.ID})
+			So(hooked[0].Items, ShouldHaveLength, 2)
+			So(hooked[1].Op, ShouldEqual, OpDelete)
+			So(hooked[1].IDs, ShouldResemble, []interface{}{i1.ID})
+			So(hooked[2].Op, ShouldEqual, OpClear)
+			So(hooked[2].IDs, ShouldResemble, []interface{}{i2.ID})
+			So(hooked[2].Table, ShouldEqual, DB_TABLE)
+
+			for _, c := range hooked {
+				So((<-sub).Op, ShouldEqual, c.Op)
+			}
+			cancel()
+			_, open := <-sub
+			So(open, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,268 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// Drift describes a difference between a table and the schema it stores.
+type Drift struct {
+	Table  string
+	Column string
+	// Problem describes the difference, e.g. "missing column".
+	Problem string
+}
+
+func (d Drift) String() string {
+	if d.Column == "" {
+		return d.Table + ": " + d.Problem
+	}
+	return d.Table + "." + d.Column + ": " + d.Problem
+}
+
+// CheckSchema compares the handler's table with the schema and returns the
+// differences found: missing or unknown columns, column types of a different
+// affinity than TableDDL would create, references without a foreign key and
+// foreign keys not being enforced. Each difference is also logged, so drift
+// caused by out-of-band changes to the database is noticed.
+func (h *Handler) CheckSchema(ctx context.Context, s schema.Schema) ([]Drift, error) {
+	cols, err := columnTypes(ctx, h.session, h.tableName)
+	if err != nil {
+		return nil, err
+	}
+	drifts := []Drift{}
+	add := func(col, problem string) {
+		drifts = append(drifts, Drift{Table: h.tableName, Column: col, Problem: problem})
+	}
+	if len(cols) == 0 {
+		add("", "missing table")
+		logDrifts(drifts)
+		return drifts, nil
+	}
+
+	ts := h.timeFormat.columnType()
+	expected := map[string]string{"id": "VARCHAR(128)", h.column("etag"): "VARCHAR(128)", h.column("updated"): ts, h.column("created"): ts}
+	references := map[string]string{}
+	if path, ok := referencePath(s["id"]); ok {
+		references["id"] = referenceTable(path)
+	}
+	switch h.storage {
+	case StorageJSON:
+		expected = map[string]string{"id": "VARCHAR(128)", h.column("etag"): "VARCHAR(128)", h.column("updated"): ts, PayloadColumn: "TEXT"}
+	case StorageHybrid:
+		expected[ExtraColumn] = "TEXT"
+	}
+	if h.etagMode == ETagNone {
+		delete(expected, h.column("etag"))
+	}
+	if h.softDelete {
+		expected[DeletedColumn] = ts
+	}
+	for name, f := range s {
+		if name == "id" || name == "created" || h.storage == StorageJSON {
+			continue
+		}
+		col, skip, err := h.payloadColumn(name)
+		if skip || err != nil {
+			continue
+		}
+		expected[col] = h.columnType(f)
+		if path, ok := referencePath(f); ok {
+			references[col] = referenceTable(path)
+		}
+	}
+
+	for _, col := range sortedColumns(expected) {
+		typ, found := cols[col]
+		switch {
+		case !found:
+			add(col, "missing column")
+		case affinity(typ) != affinity(expected[col]):
+			add(col, fmt.Sprintf("type %s has %s affinity, expected %s", typ, affinity(typ), affinity(expected[col])))
+		}
+	}
+	for _, col := range sortedColumns(cols) {
+		if _, found := expected[col]; !found {
+			add(col, "unknown column")
+		}
+	}
+
+	if len(references) > 0 {
+		fks, err := foreignKeys(ctx, h.session, h.tableName)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range sortedColumns(references) {
+			if fks[col] != references[col] {
+				add(col, "missing foreign key to "+references[col])
+			}
+		}
+		var enabled bool
+		if err = h.session.QueryRowContext(ctx, "PRAGMA foreign_keys;").Scan(&enabled); err != nil {
+			return nil, ctxErr(ctx, err)
+		}
+		if !enabled {
+			add("", "foreign keys are not enforced")
+		}
+	}
+	logDrifts(drifts)
+	return drifts, nil
+}
+
+// SchemaError is the error of VerifySchema, listing the differences between
+// the table and the schema.
+type SchemaError struct {
+	Drifts []Drift
+}
+
+// Error returns the differences, one per line.
+func (e *SchemaError) Error() string {
+	msg := fmt.Sprintf("sqlite3: table doesn't match the schema (%d differences)", len(e.Drifts))
+	for _, d := range e.Drifts {
+		msg += "\n\t" + d.String()
+	}
+	return msg
+}
+
+// VerifySchema is CheckSchema for startup: it returns a *SchemaError listing
+// the differences if there are any, so a misconfigured table stops the
+// service at boot rather than failing its first writes.
+func (h *Handler) VerifySchema(ctx context.Context, s schema.Schema) error {
+	drifts, err := h.CheckSchema(ctx, s)
+	if err != nil {
+		return err
+	}
+	if len(drifts) > 0 {
+		return &SchemaError{Drifts: drifts}
+	}
+	return nil
+}
+
+// CheckSchemaEvery runs CheckSchema at the given interval until the returned
+// function is called, passing the differences found to report if it is not
+// nil. Check errors are logged.
+func (h *Handler) CheckSchemaEvery(s schema.Schema, d time.Duration, report func([]Drift)) (stop func()) {
+	done := make(chan struct{})
+	t := time.NewTicker(d)
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				drifts, err := h.CheckSchema(context.Background(), s)
+				if err != nil {
+					log.WithField("error", err).Warn("Error checking table schema.")
+					continue
+				}
+				if report != nil {
+					report(drifts)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// logDrifts logs schema differences.
+func logDrifts(drifts []Drift) {
+	for _, d := range drifts {
+		log.WithFields(log.Fields{
+			"table":  d.Table,
+			"column": d.Column,
+		}).Warn("Schema drift: " + d.Problem + ".")
+	}
+}
+
+// affinity returns the SQLite type affinity of a declared column type.
+func affinity(typ string) string {
+	t := strings.ToUpper(typ)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case t == "", strings.Contains(t, "BLOB"):
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	}
+	return "NUMERIC"
+}
+
+// columnTypes returns the declared type of each column of a table. The map is
+// empty if the table does not exist.
+func columnTypes(ctx context.Context, db querier, table string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(`%s`);", table))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": table,
+			"error": err,
+		}).Warn("Error querying table info.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	cols := map[string]string{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			log.WithField("error", err).Warn("Error scanning table info.")
+			return nil, err
+		}
+		cols[name] = typ
+	}
+	return cols, ctxErr(ctx, rows.Err())
+}
+
+// foreignKeys returns the table referenced by each column of a table having a
+// foreign key.
+func foreignKeys(ctx context.Context, db *sql.DB, table string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list(`%s`);", table))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": table,
+			"error": err,
+		}).Warn("Error querying foreign keys.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	fks := map[string]string{}
+	for rows.Next() {
+		var id, seq int
+		var ref, from string
+		var to, onUpdate, onDelete, match sql.NullString
+		if err := rows.Scan(&id, &seq, &ref, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			log.WithField("error", err).Warn("Error scanning foreign key.")
+			return nil, err
+		}
+		fks[from] = ref
+	}
+	return fks, ctxErr(ctx, rows.Err())
+}
+
+// sortedColumns returns the keys of a column map in name order.
+func sortedColumns(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
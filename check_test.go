@@ -0,0 +1,95 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckSchema(t *testing.T) {
+	s := schema.Schema{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"f1":      schema.Field{Validator: &schema.String{}},
+		"f2":      schema.Field{Validator: &schema.Integer{}},
+	}
+
+	Convey("Given the test table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		Convey("A matching schema should report no drift", func() {
+			drifts, err := h.CheckSchema(context.Background(), s)
+			So(err, ShouldBeNil)
+			So(drifts, ShouldBeEmpty)
+		})
+
+		Convey("Differences should be reported", func() {
+			d := schema.Schema{
+				"id":   schema.IDField,
+				"f1":   schema.Field{Validator: &schema.Float{}},
+				"f3":   schema.Field{Validator: &schema.Bool{}},
+				"user": schema.Field{Validator: &schema.Reference{Path: "users"}},
+			}
+			drifts, err := h.CheckSchema(context.Background(), d)
+			So(err, ShouldBeNil)
+			problems := []string{}
+			for _, d := range drifts {
+				problems = append(problems, d.String())
+			}
+			So(problems, ShouldResemble, []string{
+				"testtable.f1: type VARCHAR(128) has TEXT affinity, expected REAL",
+				"testtable.f3: missing column",
+				"testtable.user: missing column",
+				"testtable.f2: unknown column",
+				"testtable.user: missing foreign key to users",
+				"testtable: foreign keys are not enforced",
+			})
+		})
+
+		Convey("A missing table should be reported", func() {
+			drifts, err := NewHandler(h.session, "missingtable").CheckSchema(context.Background(), s)
+			So(err, ShouldBeNil)
+			So(len(drifts), ShouldEqual, 1)
+			So(drifts[0].Problem, ShouldEqual, "missing table")
+		})
+	})
+}
+
+func TestVerifySchema(t *testing.T) {
+	Convey("Given the test table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		Convey("A matching schema should verify", func() {
+			So(h.VerifySchema(context.Background(), schema.Schema{
+				"id":      schema.IDField,
+				"created": schema.CreatedField,
+				"f1":      schema.Field{Validator: &schema.String{}},
+				"f2":      schema.Field{Validator: &schema.Integer{}},
+			}), ShouldBeNil)
+		})
+
+		Convey("Differences should be returned in a SchemaError", func() {
+			err := h.VerifySchema(context.Background(), schema.Schema{
+				"id": schema.IDField,
+				"f1": schema.Field{Validator: &schema.String{}},
+				"f2": schema.Field{Validator: &schema.Integer{}},
+				"f3": schema.Field{Validator: &schema.Bool{}},
+			})
+			se, ok := err.(*SchemaError)
+			So(ok, ShouldBeTrue)
+			So(len(se.Drifts), ShouldEqual, 1)
+			So(err.Error(), ShouldEqual, "sqlite3: table doesn't match the schema (1 differences)\n\ttesttable.f3: missing column")
+		})
+	})
+}
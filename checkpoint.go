@@ -0,0 +1,90 @@
+package sqlite3
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// CheckpointMode is the mode of a WAL checkpoint, see SQLite's wal_checkpoint
+// pragma.
+type CheckpointMode string
+
+const (
+	// CheckpointPassive copies as many frames of the WAL as possible into the
+	// database without waiting for readers or writers.
+	CheckpointPassive CheckpointMode = "PASSIVE"
+	// CheckpointFull waits for writers to finish and copies the whole WAL.
+	CheckpointFull CheckpointMode = "FULL"
+	// CheckpointRestart is like CheckpointFull, and also waits for readers so
+	// that the next writer starts over at the beginning of the WAL.
+	CheckpointRestart CheckpointMode = "RESTART"
+	// CheckpointTruncate is like CheckpointRestart, and also truncates the WAL
+	// file to zero bytes.
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// CheckpointResult is the outcome of a checkpoint.
+type CheckpointResult struct {
+	// Busy reports whether a FULL, RESTART or TRUNCATE checkpoint couldn't
+	// complete because of other connections.
+	Busy bool
+	// Log is the number of frames in the WAL, and Checkpointed the number of
+	// them copied into the database. Both are -1 when the database is not in
+	// WAL mode.
+	Log          int
+	Checkpointed int
+}
+
+// Checkpoint copies the content of the WAL into the database file. Processes
+// replicating or backing up the WAL, like Litestream, usually take over
+// checkpoints: disable SQLite's automatic checkpoints with
+// WithPragma("wal_autocheckpoint", "0"), and run Checkpoint when they are
+// done with the frames, or at an interval with CheckpointEvery.
+func (h *Handler) Checkpoint(ctx context.Context, mode CheckpointMode) (CheckpointResult, error) {
+	var r CheckpointResult
+	switch mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+	default:
+		return r, fmt.Errorf("sqlite3: invalid checkpoint mode: %q", mode)
+	}
+	err := h.session.QueryRowContext(ctx, "PRAGMA wal_checkpoint("+string(mode)+");").Scan(&r.Busy, &r.Log, &r.Checkpointed)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mode":  mode,
+			"error": err,
+		}).Warn("Error checkpointing WAL.")
+		return r, ctxErr(ctx, err)
+	}
+	return r, nil
+}
+
+// CheckpointEvery runs Checkpoint at the given interval until the returned
+// function is called. Checkpoint errors, and checkpoints that couldn't
+// complete, are logged.
+func (h *Handler) CheckpointEvery(d time.Duration, mode CheckpointMode) (stop func()) {
+	done := make(chan struct{})
+	t := time.NewTicker(d)
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				r, err := h.Checkpoint(context.Background(), mode)
+				if err == nil && r.Busy {
+					log.WithField("mode", mode).Info("Scheduled checkpoint could not complete.")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,54 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckpoint(t *testing.T) {
+	const file = "./checkpoint_test.db"
+
+	Convey("Given a database in WAL mode without automatic checkpoints", t, func() {
+		os.Remove(file)
+		Reset(func() {
+			os.Remove(file)
+			os.Remove(file + "-wal")
+			os.Remove(file + "-shm")
+		})
+		db, err := sql.Open(DB_DRIVER, file)
+		So(err, ShouldBeNil)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		h := NewHandler(db, DB_TABLE, WithJournalMode("WAL"), WithPragma("wal_autocheckpoint", "0"))
+		_, err = db.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		i, _ := item("foo", 1)
+		So(h.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+
+		Convey("Checkpoint should copy the WAL into the database", func() {
+			r, err := h.Checkpoint(context.Background(), CheckpointPassive)
+			So(err, ShouldBeNil)
+			So(r.Busy, ShouldBeFalse)
+			So(r.Log, ShouldBeGreaterThan, 0)
+			So(r.Checkpointed, ShouldEqual, r.Log)
+
+			r, err = h.Checkpoint(context.Background(), CheckpointTruncate)
+			So(err, ShouldBeNil)
+			So(r.Log, ShouldEqual, 0)
+			st, err := os.Stat(file + "-wal")
+			So(err, ShouldBeNil)
+			So(st.Size(), ShouldEqual, 0)
+		})
+
+		Convey("Invalid modes should be rejected", func() {
+			_, err := h.Checkpoint(context.Background(), "NOW; DROP")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
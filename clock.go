@@ -0,0 +1,74 @@
+package sqlite3
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of the times set by the handler: the updated and
+// created times of inserted items, the deletion time of tombstones and the end
+// of FindChangedSince windows.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface, for instance to freeze
+// time in tests.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the clock of handlers created without the WithClock option.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// timeResolution is the resolution of the stored times, the precision of
+// timeLayout, the layout of TimeDefault.
+const timeResolution = 10 * time.Nanosecond
+
+// monotonicClock is a clock whose times always increase.
+type monotonicClock struct {
+	mu   sync.Mutex
+	src  Clock
+	tick time.Duration
+	last time.Time
+}
+
+// NewMonotonicClock returns a clock reading src whose times strictly increase
+// by at least 10ns, even if src goes back or returns the same time twice, so
+// they are stored as distinct timestamps with TimeDefault and TimeRFC3339.
+// Share it between the handlers writing to a database so the updated times
+// order their writes. With other formats, use NewMonotonicClockFor.
+func NewMonotonicClock(src Clock) Clock {
+	return NewMonotonicClockFor(src, TimeDefault)
+}
+
+// NewMonotonicClockFor is like NewMonotonicClock, with times increasing by the
+// resolution of the time format: a second with TimeUnix, a millisecond with
+// TimeUnixMilli and 10ns with text formats. Custom layouts less precise than
+// 10ns, like one without fractional seconds, don't store distinct timestamps.
+func NewMonotonicClockFor(src Clock, f TimeFormat) Clock {
+	return &monotonicClock{src: src, tick: f.resolution()}
+}
+
+// Now returns the time of the source, or just after the last time returned if
+// the source is behind.
+func (c *monotonicClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.src.Now().Truncate(c.tick)
+	if !t.After(c.last) {
+		t = c.last.Add(c.tick)
+	}
+	c.last = t
+	return t
+}
+
+// WithClock sets the clock of the times set by the handler.
+func WithClock(c Clock) Option {
+	return func(h *Handler) {
+		h.clock = c
+	}
+}
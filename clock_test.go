@@ -0,0 +1,62 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClock(t *testing.T) {
+	frozen := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	Convey("The monotonic clock should always move forward", t, func() {
+		c := NewMonotonicClock(ClockFunc(func() time.Time { return frozen }))
+		t1 := c.Now()
+		t2 := c.Now()
+		So(t1, ShouldResemble, frozen)
+		So(t2, ShouldResemble, frozen.Add(timeResolution))
+	})
+
+	Convey("The monotonic clock should store distinct integer timestamps", t, func() {
+		c := NewMonotonicClockFor(ClockFunc(func() time.Time { return frozen.Add(time.Millisecond) }), TimeUnix)
+		t1 := c.Now()
+		t2 := c.Now()
+		So(t1, ShouldResemble, frozen)
+		So(TimeUnix.literal(t2), ShouldNotEqual, TimeUnix.literal(t1))
+		So(t2, ShouldResemble, frozen.Add(time.Second))
+	})
+
+	Convey("Given a handler with a frozen clock", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ch := NewHandler(h.session, DB_TABLE, WithClock(ClockFunc(func() time.Time { return frozen })), WithTombstones())
+		h.session.Exec("DROP TABLE `" + tombstoneTable(ch) + "`;")
+		So(ch.CreateTombstoneTable(context.Background()), ShouldBeNil)
+
+		Convey("Inserted items should be timestamped by the clock", func() {
+			i, _ := item("foo", 1)
+			i.Updated = time.Time{}
+			So(ch.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+			So(i.Updated, ShouldResemble, frozen)
+
+			So(ch.Delete(context.Background(), i), ShouldBeNil)
+			ts, err := ch.Tombstones(context.Background(), frozen.Add(-time.Second))
+			So(err, ShouldBeNil)
+			So(len(ts), ShouldEqual, 1)
+			So(ts[0].Deleted.Equal(frozen), ShouldBeTrue)
+		})
+
+		Convey("Delta windows should end at the clock's time", func() {
+			d, err := ch.FindChangedSince(context.Background(), frozen.Add(-time.Hour), 0)
+			So(err, ShouldBeNil)
+			So(d.Until, ShouldResemble, frozen)
+		})
+	})
+}
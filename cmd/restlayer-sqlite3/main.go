@@ -0,0 +1,153 @@
+// Command restlayer-sqlite3 works with the databases of rest-layer-sqlite3
+// handlers.
+//
+// The ddl command prints the statements creating the tables and indexes of
+// resources, so the database structure can be versioned with the code:
+//
+//	restlayer-sqlite3 ddl [-storage columns|json] [-time default|rfc3339|unix|unixmilli] [-soft-delete] [schemas.json]
+//
+// The schemas are read from the file, or from the standard input, as a JSON
+// object mapping table names to their fields:
+//
+//	{
+//	  "users": {"name": {"type": "string", "maxLen": 64, "sortable": true}},
+//	  "posts": {
+//	    "user": {"type": "reference", "path": "users", "filterable": true},
+//	    "body": {"type": "string"}
+//	  }
+//	}
+//
+// Field types are string, integer, float, bool, time, reference, password,
+// dict and array. Reference fields become foreign keys to the referenced
+// table.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jxstanford/rest-layer-sqlite3"
+	"github.com/rs/rest-layer/schema"
+)
+
+// field is the JSON description of a schema field.
+type field struct {
+	Type       string `json:"type"`
+	MaxLen     int    `json:"maxLen"`
+	Path       string `json:"path"`
+	Filterable bool   `json:"filterable"`
+	Sortable   bool   `json:"sortable"`
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "ddl" {
+		fmt.Fprintln(os.Stderr, "usage: restlayer-sqlite3 ddl [flags] [schemas.json]")
+		os.Exit(2)
+	}
+	if err := ddl(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "restlayer-sqlite3:", err)
+		os.Exit(1)
+	}
+}
+
+// ddl runs the ddl command.
+func ddl(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("ddl", flag.ExitOnError)
+	storage := flags.String("storage", "columns", "payload storage: columns or json")
+	timeFormat := flags.String("time", "default", "time format: default, rfc3339, unix or unixmilli")
+	softDelete := flags.Bool("soft-delete", false, "add the deleted_at column")
+	flags.Parse(args)
+
+	opts := []sqlite3.Option{}
+	switch *storage {
+	case "columns":
+	case "json":
+		opts = append(opts, sqlite3.WithStorageMode(sqlite3.StorageJSON))
+	default:
+		return fmt.Errorf("unknown storage: %q", *storage)
+	}
+	switch *timeFormat {
+	case "default":
+	case "rfc3339":
+		opts = append(opts, sqlite3.WithTimeFormat(sqlite3.TimeRFC3339))
+	case "unix":
+		opts = append(opts, sqlite3.WithTimeFormat(sqlite3.TimeUnix))
+	case "unixmilli":
+		opts = append(opts, sqlite3.WithTimeFormat(sqlite3.TimeUnixMilli))
+	default:
+		return fmt.Errorf("unknown time format: %q", *timeFormat)
+	}
+	if *softDelete {
+		opts = append(opts, sqlite3.WithSoftDelete())
+	}
+
+	in := stdin
+	if flags.NArg() > 0 {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	tables := map[string]map[string]field{}
+	if err := json.NewDecoder(in).Decode(&tables); err != nil {
+		return fmt.Errorf("invalid schemas: %v", err)
+	}
+	resources := make(map[string]schema.Schema, len(tables))
+	for table, fields := range tables {
+		s, err := toSchema(fields)
+		if err != nil {
+			return fmt.Errorf("table %s: %v", table, err)
+		}
+		resources[table] = s
+	}
+
+	stmts, err := sqlite3.GenerateDDL(resources, opts...)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		fmt.Fprintln(stdout, stmt)
+	}
+	return nil
+}
+
+// toSchema converts the JSON description of a table to a schema. The id field
+// is added if missing.
+func toSchema(fields map[string]field) (schema.Schema, error) {
+	s := schema.Schema{"id": schema.IDField}
+	for name, f := range fields {
+		var v schema.FieldValidator
+		switch f.Type {
+		case "string":
+			v = &schema.String{MaxLen: f.MaxLen}
+		case "integer":
+			v = &schema.Integer{}
+		case "float":
+			v = &schema.Float{}
+		case "bool":
+			v = &schema.Bool{}
+		case "time":
+			v = &schema.Time{}
+		case "reference":
+			if f.Path == "" {
+				return nil, fmt.Errorf("field %s: reference without path", name)
+			}
+			v = &schema.Reference{Path: f.Path}
+		case "password":
+			v = &schema.Password{}
+		case "dict":
+			v = &schema.Dict{}
+		case "array":
+			v = &schema.Array{}
+		default:
+			return nil, fmt.Errorf("field %s: unknown type %q", name, f.Type)
+		}
+		s[name] = schema.Field{Validator: v, Filterable: f.Filterable, Sortable: f.Sortable}
+	}
+	return s, nil
+}
@@ -0,0 +1,84 @@
+package sqlite3
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// WithCoalescing makes concurrent Find calls running the same statement share
+// a single execution, so a burst of identical requests on a hot list endpoint
+// costs one query. Each caller gets its own copy of the list and items. A
+// caller whose context is still live when the shared execution was canceled
+// runs the statement again on its own.
+func WithCoalescing(enabled bool) Option {
+	return func(h *Handler) {
+		if enabled {
+			h.flights = &flightGroup{calls: map[string]*flightCall{}}
+		} else {
+			h.flights = nil
+		}
+	}
+}
+
+// flightGroup tracks the Find statements in flight.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a statement execution shared by concurrent callers.
+type flightCall struct {
+	done chan struct{}
+	list *resource.ItemList
+	err  error
+}
+
+// do runs fn, or waits for the result of the call in flight for the same key.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (*resource.ItemList, error)) (*resource.ItemList, error) {
+	g.mu.Lock()
+	if c, found := g.calls[key]; found {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if c.err != nil && (c.err == context.Canceled || c.err == context.DeadlineExceeded) && ctx.Err() == nil {
+			// the leader gave up, not us
+			return fn()
+		}
+		return copyList(c.list), c.err
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.list, c.err = fn()
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+	return copyList(c.list), c.err
+}
+
+// copyList returns a copy of a list whose items and payloads can be changed
+// without affecting the original.
+func copyList(l *resource.ItemList) *resource.ItemList {
+	if l == nil {
+		return nil
+	}
+	cp := *l
+	cp.Items = make([]*resource.Item, len(l.Items))
+	for i, item := range l.Items {
+		it := *item
+		it.Payload = make(map[string]interface{}, len(item.Payload))
+		for k, v := range item.Payload {
+			it.Payload[k] = v
+		}
+		cp.Items[i] = &it
+	}
+	return &cp
+}
@@ -0,0 +1,77 @@
+package sqlite3
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCoalescing(t *testing.T) {
+	Convey("Concurrent calls should share one execution", t, func() {
+		g := &flightGroup{calls: map[string]*flightCall{}}
+		release := make(chan struct{})
+		runs := 0
+		fn := func() (*resource.ItemList, error) {
+			runs++
+			<-release
+			return &resource.ItemList{Page: 1, Items: []*resource.Item{{ID: "a", Payload: map[string]interface{}{"f": 1}}}}, nil
+		}
+		results := make([]*resource.ItemList, 3)
+		var wg sync.WaitGroup
+		// the first call is in flight before the others start
+		started := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[0], _ = g.do(context.Background(), "q", func() (*resource.ItemList, error) {
+				close(started)
+				return fn()
+			})
+		}()
+		<-started
+		for n := 1; n < 3; n++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				results[n], _ = g.do(context.Background(), "q", fn)
+			}(n)
+		}
+		// let the followers block on the call
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		So(runs, ShouldEqual, 1)
+		So(results[1].Items[0].ID, ShouldEqual, "a")
+		results[1].Items[0].Payload["f"] = 2
+		So(results[2].Items[0].Payload["f"], ShouldEqual, 1)
+		So(results[0].Items[0], ShouldNotPointTo, results[2].Items[0])
+	})
+
+	Convey("Errors should be returned to the caller", t, func() {
+		g := &flightGroup{calls: map[string]*flightCall{}}
+		fail := errors.New("fail")
+		_, err := g.do(context.Background(), "q", func() (*resource.ItemList, error) { return nil, fail })
+		So(err, ShouldEqual, fail)
+		So(len(g.calls), ShouldEqual, 0)
+	})
+
+	Convey("Find should return the items through a coalescing handler", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		ch := NewHandler(h.session, DB_TABLE, WithCoalescing(true))
+		list, err := ch.Find(context.Background(), resource.NewLookup(), 1, 10)
+		So(err, ShouldBeNil)
+		So(len(list.Items), ShouldEqual, 2)
+		So(list.Total, ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,57 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithCollation sets the collating sequence of a field, like NOCASE or a
+// collation registered with the driver, so that case-insensitive matching and
+// sorting is declared rather than depending on LIKE. The collation is used by:
+//
+//   - EnsureTable and GenerateDDL, on the field's column;
+//   - EnsureIndexes, on the field's index, so collated filters and sorts use it;
+//   - filters comparing the field (Equal, NotEqual, In, NotIn and the ordering
+//     comparisons). Equal and NotEqual filters on the field compare strings
+//     with = and <> rather than LIKE, unless the value has a * wildcard;
+//   - sorts on the field, unless WithSortOption gives another collation.
+//
+// Fields folded with WithCaseFolding are compared lowercased, as before.
+func WithCollation(field, collation string) Option {
+	return func(h *Handler) {
+		if h.collations == nil {
+			h.collations = map[string]string{}
+		}
+		h.collations[field] = collation
+	}
+}
+
+// checkCollations returns an error if a collation isn't a valid identifier.
+func (h *Handler) checkCollations() error {
+	for _, f := range sortedColumns(h.collations) {
+		if !identRe.MatchString(h.collations[f]) {
+			return fmt.Errorf("sqlite3: invalid collation for %s: %q", f, h.collations[f])
+		}
+	}
+	return nil
+}
+
+// collate returns the COLLATE clause of a field, if it has a collation.
+func (h *Handler) collate(field string) string {
+	if c := h.collations[field]; c != "" {
+		return " COLLATE " + c
+	}
+	return ""
+}
+
+// compareRef returns the SQL expression comparing a field in filters, with
+// its collation.
+func (h *Handler) compareRef(field string) string {
+	return h.fieldRef(field) + h.collate(field)
+}
+
+// collatedEquality reports whether an Equal or NotEqual filter on a collated
+// field compares the value with = rather than as a LIKE pattern.
+func (h *Handler) collatedEquality(field, v string) bool {
+	return h.collations[field] != "" && !strings.Contains(v, "*")
+}
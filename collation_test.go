@@ -0,0 +1,107 @@
+package sqlite3
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollation(t *testing.T) {
+	Convey("Filters on collated fields should use the collation", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Foo"}}, WithCollation("f1", "NOCASE"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 COLLATE NOCASE = 'Foo'")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Foo"}}, WithCollation("f1", "NOCASE"), WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 COLLATE NOCASE = 'Foo'")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Fo*"}}, WithCollation("f1", "NOCASE"), WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldStartWith, "f1 LIKE ")
+
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "Foo"}}, WithCollation("f1", "NOCASE"), WithNullMatching(true))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 COLLATE NOCASE <> 'Foo' OR f1 IS NULL)")
+
+		s, err = callGetQuery(schema.Query{
+			schema.In{Field: "f1", Values: []schema.Value{"a", "b"}},
+			schema.GreaterThan{Field: "f1", Value: "a"},
+		}, WithCollation("f1", "NOCASE"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 COLLATE NOCASE IN ('a','b') AND f1 COLLATE NOCASE > 'a'")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Foo"}}, WithCollation("f1", "NOCASE"), WithCaseFolding("f1"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "lower(f1) = 'foo'")
+
+		_, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Foo"}}, WithCollation("f1", "NOCASE; DROP"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Collations should be checked once by NewHandler", t, func() {
+		So(NewHandler(nil, DB_TABLE, WithCollation("f1", "NOCASE")).optionErr, ShouldBeNil)
+		h := NewHandler(nil, DB_TABLE, WithCollation("f1", "NOCASE; DROP"))
+		So(h.optionErr, ShouldNotBeNil)
+		So(h.EnsureTable(context.Background(), schema.Schema{"id": schema.IDField}), ShouldEqual, h.optionErr)
+	})
+
+	Convey("Sorts on collated fields should use the collation", t, func() {
+		s, err := callGetSort("-f1,f2", nil, WithCollation("f1", "NOCASE"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 COLLATE NOCASE DESC,f2")
+
+		s, err = callGetSort("f1", nil, WithCollation("f1", "NOCASE"), WithSortOption("f1", SortOption{Collation: "BINARY"}))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 COLLATE BINARY")
+	})
+
+	Convey("Collated fields should be declared in the DDL", t, func() {
+		stmts, err := GenerateDDL(map[string]schema.Schema{"t": {
+			"id": schema.IDField,
+			"f1": schema.Field{Validator: &schema.String{}, Filterable: true},
+		}}, WithCollation("f1", "NOCASE"))
+		So(err, ShouldBeNil)
+		So(len(stmts), ShouldEqual, 2)
+		So(strings.Contains(stmts[0], "`f1` TEXT COLLATE NOCASE"), ShouldBeTrue)
+		So(stmts[1], ShouldContainSubstring, "(f1 COLLATE NOCASE)")
+
+		_, err = GenerateDDL(map[string]schema.Schema{"t": {"id": schema.IDField}}, WithCollation("f1", "x y"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given a table with a NOCASE field", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ch := NewHandler(h.session, DB_TABLE, WithCollation("f1", "NOCASE"))
+		a, _ := item("Bob", 1)
+		b, _ := item("alice", 2)
+		So(ch.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+
+		Convey("Equal should ignore case", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "BOB"}})
+			list, err := ch.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, a.ID)
+		})
+
+		Convey("Sorts should ignore case", func() {
+			l := resource.NewLookup()
+			l.SetSort("f1", nil)
+			list, err := ch.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 2)
+			So(list.Items[0].ID, ShouldEqual, b.ID)
+		})
+	})
+}
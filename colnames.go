@@ -0,0 +1,46 @@
+package sqlite3
+
+// WithColumnNames maps field names to the names of the columns storing them,
+// for tables that don't follow the handler's conventions. The etag, updated
+// and created meta fields can be mapped like payload fields; the id column
+// must be named id. Filters, sorts, projections and statements use the mapped
+// columns, and rows are read back under the field names.
+func WithColumnNames(names map[string]string) Option {
+	return func(h *Handler) {
+		h.columnNames = make(map[string]string, len(names))
+		h.fieldNames = make(map[string]string, len(names))
+		for f, c := range names {
+			if f == "id" || f == c {
+				continue
+			}
+			h.columnNames[f] = c
+			h.fieldNames[c] = f
+		}
+	}
+}
+
+// column returns the name of the column storing a field.
+func (h *Handler) column(field string) string {
+	if c, ok := h.columnNames[field]; ok {
+		return c
+	}
+	return field
+}
+
+// renameColumns moves the values of the mapped columns of a row under their
+// field name.
+func (h *Handler) renameColumns(row map[string]interface{}) {
+	if len(h.fieldNames) == 0 {
+		return
+	}
+	renamed := make(map[string]interface{}, len(h.fieldNames))
+	for c, f := range h.fieldNames {
+		if v, ok := row[c]; ok {
+			delete(row, c)
+			renamed[f] = v
+		}
+	}
+	for f, v := range renamed {
+		row[f] = v
+	}
+}
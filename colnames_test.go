@@ -0,0 +1,82 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestColumnNames(t *testing.T) {
+	names := map[string]string{"etag": "version", "updated": "modified", "created": "created_at", "f1": "title"}
+
+	Convey("Filters and sorts should use the mapped columns", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo"}, schema.Equal{Field: "etag", Value: "x"}}, WithColumnNames(names))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "title = 'foo' AND version IS 'x'")
+
+		h := NewHandler(nil, "t", WithColumnNames(names))
+		o, err := translateSort(h, []string{"-updated", "f2"})
+		So(err, ShouldBeNil)
+		So(o, ShouldEqual, "modified DESC,f2")
+	})
+
+	Convey("The DDL should use the mapped columns", t, func() {
+		s := schema.Schema{"f1": schema.Field{Validator: &schema.String{MaxLen: 128}}}
+		h := NewHandler(nil, "t", WithColumnNames(names))
+		So(tableDDL(h, s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`version` VARCHAR(128),`modified` VARCHAR(128),`created_at` VARCHAR(128),`title` VARCHAR(128));")
+	})
+
+	Convey("Given a legacy table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE legacy;")
+		_, err = h.session.Exec("CREATE TABLE legacy (id VARCHAR(128) PRIMARY KEY, version VARCHAR(128), modified VARCHAR(128), created_at VARCHAR(128), title TEXT, f2 INTEGER);")
+		So(err, ShouldBeNil)
+		defer h.session.Exec("DROP TABLE legacy;")
+		lh := NewHandler(h.session, "legacy", WithColumnNames(names))
+		i, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "foo", "f2": 1})
+		So(lh.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+
+		Convey("Items should be stored in the mapped columns", func() {
+			var version, title string
+			So(h.session.QueryRow("SELECT version, title FROM legacy WHERE id = 'a'").Scan(&version, &title), ShouldBeNil)
+			So(version, ShouldEqual, i.ETag)
+			So(title, ShouldEqual, "foo")
+		})
+
+		Convey("Items should be read back under their field names", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "updated", Value: i.Updated.Add(-time.Second)}})
+			list, err := lh.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ETag, ShouldEqual, i.ETag)
+			So(list.Items[0].Updated.Equal(i.Updated), ShouldBeTrue)
+			So(list.Items[0].Payload["f1"], ShouldEqual, "foo")
+			So(list.Items[0].Payload["created"].(time.Time).Equal(i.Updated), ShouldBeTrue)
+			_, found := list.Items[0].Payload["title"]
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Updates and deletes should match the mapped etag column", func() {
+			n, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "bar", "f2": 2})
+			So(lh.Update(context.Background(), n, i), ShouldBeNil)
+			So(lh.Update(context.Background(), n, i), ShouldEqual, resource.ErrConflict)
+			So(lh.Delete(context.Background(), n), ShouldBeNil)
+			list, err := lh.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 0)
+		})
+
+		Convey("Schema checks should expect the mapped columns", func() {
+			drifts, err := lh.CheckSchema(context.Background(), schema.Schema{"f1": schema.Field{Validator: &schema.String{}}, "f2": schema.Field{Validator: &schema.Integer{}}})
+			So(err, ShouldBeNil)
+			So(drifts, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,59 @@
+package sqlite3
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// maxRequestIDLen bounds the length of request ids written in statements.
+const maxRequestIDLen = 64
+
+// RequestIDFunc returns the request or trace id carried by a context, or an
+// empty string if there is none.
+type RequestIDFunc func(ctx context.Context) string
+
+// WithRequestID appends a comment carrying the request id returned by fn to
+// every statement the handler runs (e.g. "SELECT ... /* req:abc123 */;"), so
+// slow statements found in SQLite traces can be correlated with API requests.
+// Ids are truncated and stripped of any character other than letters, digits,
+// '.', ':', '_' and '-'.
+func WithRequestID(fn RequestIDFunc) Option {
+	return func(h *Handler) {
+		h.requestID = fn
+	}
+}
+
+// annotate adds the request id comment to a statement, before its terminating
+// semicolon.
+func (h *Handler) annotate(ctx context.Context, s string) string {
+	if h.requestID == nil {
+		return s
+	}
+	id := sanitizeRequestID(h.requestID(ctx))
+	if id == "" {
+		return s
+	}
+	if strings.HasSuffix(s, ";") {
+		return s[:len(s)-1] + " /* req:" + id + " */;"
+	}
+	return s + " /* req:" + id + " */"
+}
+
+// sanitizeRequestID removes anything from an id that could end the comment or
+// otherwise alter the statement.
+func sanitizeRequestID(id string) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.' || r == ':' || r == '_' || r == '-':
+			return r
+		}
+		return -1
+	}, id)
+	if len(clean) > maxRequestIDLen {
+		clean = clean[:maxRequestIDLen]
+	}
+	return clean
+}
@@ -0,0 +1,55 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type reqIDKey struct{}
+
+func ctxRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(reqIDKey{}).(string)
+	return id
+}
+
+func TestRequestIDComments(t *testing.T) {
+	Convey("Statements should carry the request id", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithRequestID(ctxRequestID))
+		ctx := context.WithValue(context.Background(), reqIDKey{}, "abc123")
+
+		So(h.annotate(ctx, "SELECT 1;"), ShouldEqual, "SELECT 1 /* req:abc123 */;")
+		So(h.annotate(ctx, "SELECT 1"), ShouldEqual, "SELECT 1 /* req:abc123 */")
+		So(h.annotate(context.Background(), "SELECT 1;"), ShouldEqual, "SELECT 1;")
+		So(NewHandler(nil, DB_TABLE).annotate(ctx, "SELECT 1;"), ShouldEqual, "SELECT 1;")
+	})
+
+	Convey("Request ids should be sanitized", t, func() {
+		So(sanitizeRequestID("abc */ DROP TABLE x; /*"), ShouldEqual, "abcDROPTABLEx")
+		So(sanitizeRequestID("trace-1:span.2_x"), ShouldEqual, "trace-1:span.2_x")
+		long := ""
+		for i := 0; i < 100; i++ {
+			long += "a"
+		}
+		So(len(sanitizeRequestID(long)), ShouldEqual, maxRequestIDLen)
+	})
+
+	Convey("Annotated statements should run", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		rh := NewHandler(h.session, DB_TABLE, WithRequestID(ctxRequestID))
+		ctx := context.WithValue(context.Background(), reqIDKey{}, "abc123")
+
+		So(rh.Insert(ctx, []*resource.Item{i1}), ShouldBeNil)
+		result, err := rh.Find(ctx, resource.NewLookup(), 1, 10)
+		So(err, ShouldBeNil)
+		So(len(result.Items), ShouldEqual, 1)
+		So(rh.Delete(ctx, i1), ShouldBeNil)
+	})
+}
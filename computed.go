@@ -0,0 +1,105 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// functions holds the functions registered with RegisterFunction.
+var functions = struct {
+	sync.Mutex
+	m map[string]Function
+}{m: map[string]Function{}}
+
+// RegisterFunction registers a Go function, like a normalization, distance or
+// hash function, on the connections of the DriverName driver and of the
+// drivers registered with RegisterDriver, so computed fields declared with
+// WithComputedField can call it. Only the connections opened afterwards have
+// the function: register functions before opening the database, e.g. in an
+// init function.
+func RegisterFunction(name string, f Function) error {
+	if !identRe.MatchString(name) {
+		return fmt.Errorf("sqlite3: invalid function name: %q", name)
+	}
+	functions.Lock()
+	defer functions.Unlock()
+	if _, found := functions.m[name]; found {
+		return fmt.Errorf("sqlite3: function %q already registered", name)
+	}
+	if err := registerFunction(name, f); err != nil {
+		return err
+	}
+	functions.m[name] = f
+	return nil
+}
+
+// registeredFunctions returns a copy of the functions registered with
+// RegisterFunction.
+func registeredFunctions() map[string]Function {
+	functions.Lock()
+	defer functions.Unlock()
+	m := make(map[string]Function, len(functions.m))
+	for n, f := range functions.m {
+		m[n] = f
+	}
+	return m
+}
+
+// computedField is a field computed by a SQL function.
+type computedField struct {
+	fn   string
+	args []string
+}
+
+// WithComputedField declares a field computed by calling the SQL function fn,
+// registered with RegisterFunction or the driver, with the given fields as
+// arguments. Filters and sorts on the field apply to the result of the call:
+// with WithComputedField("slug", "normalize", "title"), {"slug": "foo"} is
+// translated to normalize(title) = 'foo' and sorting on slug orders by
+// normalize(title). Computed fields aren't stored and don't need to be in the
+// schema given to WithSchema. Their arguments can't be computed fields.
+func WithComputedField(name, fn string, args ...string) Option {
+	return func(h *Handler) {
+		if h.computed == nil {
+			h.computed = map[string]computedField{}
+		}
+		h.computed[name] = computedField{fn: fn, args: args}
+	}
+}
+
+// checkComputed returns an error if a computed field calls an invalid
+// function name, or takes an invalid field name or a computed field as
+// argument.
+func (h *Handler) checkComputed() error {
+	for name, c := range h.computed {
+		if !identRe.MatchString(c.fn) {
+			return fmt.Errorf("sqlite3: invalid function for %s: %q", name, c.fn)
+		}
+		for _, a := range c.args {
+			for _, part := range strings.Split(a, ".") {
+				if !identRe.MatchString(part) {
+					return fmt.Errorf("sqlite3: invalid argument for %s: %q", name, a)
+				}
+			}
+			if _, found := h.computed[a]; found {
+				return fmt.Errorf("sqlite3: computed field %s takes computed field %s", name, a)
+			}
+		}
+	}
+	return nil
+}
+
+// computedRef returns the SQL expression of a computed field, and whether the
+// field is one.
+func (h *Handler) computedRef(field string) (string, bool) {
+	c, found := h.computed[field]
+	if !found {
+		return "", false
+	}
+	refs := make([]string, len(c.args))
+	for i, a := range c.args {
+		refs[i] = h.storedRef(a)
+	}
+	return c.fn + "(" + strings.Join(refs, ",") + ")", true
+}
@@ -0,0 +1,97 @@
+package sqlite3
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	if DriverImpl != "go-sqlite3" {
+		return
+	}
+	// registered before any connection is opened
+	err := RegisterFunction("squash", Function{Impl: func(s string) string {
+		return strings.ToLower(strings.Replace(s, " ", "", -1))
+	}, Pure: true})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestComputedField(t *testing.T) {
+	Convey("Filters and sorts on computed fields should call the function", t, func() {
+		opt := WithComputedField("key", "squash", "f1")
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "key", Value: "foo"}}, opt)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "squash(f1) = 'foo'")
+
+		s, err = callGetQuery(schema.Query{schema.GreaterThan{Field: "dist", Value: 1}}, WithComputedField("dist", "distance", "f1", "f2"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "distance(f1,f2) > 1")
+
+		s, err = callGetSort("-key,f2", nil, opt)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "squash(f1) DESC,f2")
+
+		_, err = callGetQuery(schema.Query{schema.Equal{Field: "key", Value: "foo"}}, WithComputedField("key", "squash;"))
+		So(err, ShouldNotBeNil)
+		_, err = callGetSort("key", nil, opt, WithComputedField("k2", "squash", "key"))
+		So(err, ShouldNotBeNil)
+		_, err = callGetQuery(schema.Query{schema.Equal{Field: "key", Value: "foo"}}, WithComputedField("key", "squash", "f1) OR (1"))
+		So(err, ShouldNotBeNil)
+		So(NewHandler(nil, DB_TABLE, WithComputedField("key", "squash", "meta.name")).optionErr, ShouldBeNil)
+	})
+
+	Convey("Computed fields should pass the schema checks", t, func() {
+		_, err := callGetQuery(schema.Query{schema.Equal{Field: "key", Value: "foo"}},
+			WithSchema(schema.Schema{"id": schema.IDField, "f1": schema.Field{}}), WithComputedField("key", "squash", "f1"))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("RegisterFunction should reject invalid and duplicate names", t, func() {
+		So(RegisterFunction("x y", Function{}), ShouldNotBeNil)
+		if DriverImpl == "go-sqlite3" {
+			So(RegisterFunction("squash", Function{}), ShouldNotBeNil)
+		}
+	})
+
+	if DriverImpl != "go-sqlite3" {
+		return
+	}
+	Convey("Given a table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ch := NewHandler(h.session, DB_TABLE, WithComputedField("key", "squash", "f1"))
+		a, _ := item("New York", 1)
+		b, _ := item("Boston", 2)
+		So(ch.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+
+		Convey("Find should filter on the registered function", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "key", Value: "newyork"}})
+			list, err := ch.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, a.ID)
+			So(list.Items[0].Payload["key"], ShouldBeNil)
+		})
+
+		Convey("Find should sort on the registered function", func() {
+			l := resource.NewLookup()
+			l.SetSort("-key", nil)
+			list, err := ch.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 2)
+			So(list.Items[0].ID, ShouldEqual, a.ID)
+		})
+	})
+}
@@ -0,0 +1,52 @@
+package sqlite3
+
+import (
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// ConflictResolver is called by Update when the stored item changed since the
+// original was read. It receives the item being written and the currently
+// stored item, and returns the item to write over the stored one, typically
+// built with resource.NewItem so it gets a fresh etag. Returning nil or an
+// error (e.g. resource.ErrConflict) gives up the update.
+type ConflictResolver func(ctx context.Context, item, current *resource.Item) (*resource.Item, error)
+
+// LastWriterWins is a ConflictResolver writing the new item over whatever
+// version is stored.
+func LastWriterWins(ctx context.Context, item, current *resource.Item) (*resource.Item, error) {
+	return item, nil
+}
+
+// WithConflictResolver sets the function resolving Update conflicts. Without
+// a resolver, conflicting updates fail with resource.ErrConflict.
+func WithConflictResolver(r ConflictResolver) Option {
+	return func(h *Handler) {
+		h.resolver = r
+	}
+}
+
+// resolveConflict loads the stored item with the given select statement and
+// asks the resolver for the item to write. It returns the item to write and
+// the stored item it replaces.
+func (h *Handler) resolveConflict(ctx context.Context, db querier, sel string, item *resource.Item) (*resource.Item, *resource.Item, error) {
+	list, err := runSelect(ctx, h, db, sel, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, nil, resource.ErrNotFound
+	}
+	current := list.Items[0]
+	merged, err := h.resolver(ctx, item, current)
+	if err != nil {
+		return nil, nil, err
+	}
+	if merged == nil {
+		return nil, nil, resource.ErrConflict
+	}
+	log.WithField("id", current.ID).Info("Resolved update conflict.")
+	return merged, current, nil
+}
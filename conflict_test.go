@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConflictResolver(t *testing.T) {
+	Convey("Given a stored item and a stale original", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		stale := *i1
+		stale.ETag = "stale"
+		updated, _ := resource.NewItem(map[string]interface{}{"id": i1.ID, "created": i1.Payload["created"], "f1": "new", "f2": 1})
+
+		find := func() *resource.Item {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "id", Value: i1.ID}})
+			result, err := h.Find(context.Background(), l, 1, 1)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			return result.Items[0]
+		}
+
+		Convey("Update should fail without a resolver", func() {
+			So(h.Update(context.Background(), updated, &stale), ShouldEqual, resource.ErrConflict)
+			So(find().Payload["f1"], ShouldEqual, "foo")
+		})
+
+		Convey("Update should write the resolved item", func() {
+			rh := NewHandler(h.session, DB_TABLE, WithConflictResolver(LastWriterWins))
+			So(rh.Update(context.Background(), updated, &stale), ShouldBeNil)
+			stored := find()
+			So(stored.Payload["f1"], ShouldEqual, "new")
+			So(stored.ETag, ShouldEqual, updated.ETag)
+		})
+
+		Convey("Update should fail when the resolver gives up", func() {
+			var got *resource.Item
+			rh := NewHandler(h.session, DB_TABLE, WithConflictResolver(func(ctx context.Context, item, current *resource.Item) (*resource.Item, error) {
+				got = current
+				return nil, nil
+			}))
+			So(rh.Update(context.Background(), updated, &stale), ShouldEqual, resource.ErrConflict)
+			So(got.ETag, ShouldEqual, i1.ETag)
+		})
+	})
+}
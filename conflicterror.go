@@ -0,0 +1,66 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// ConflictError is the error returned in place of resource.ErrConflict by
+// handlers created with WithConflictDetails. It describes the stored version
+// of the item, so the API layer can return an informative 409 response and
+// clients can tell whether re-fetching is worth it.
+type ConflictError struct {
+	ID interface{}
+	// ETag and Updated are the etag and updated time of the stored item.
+	ETag    string
+	Updated time.Time
+}
+
+// Error returns the message of resource.ErrConflict.
+func (e *ConflictError) Error() string {
+	return resource.ErrConflict.Error()
+}
+
+// Unwrap returns resource.ErrConflict.
+func (e *ConflictError) Unwrap() error {
+	return resource.ErrConflict
+}
+
+// IsConflict reports whether an error is resource.ErrConflict or a
+// ConflictError.
+func IsConflict(err error) bool {
+	if _, ok := err.(*ConflictError); ok {
+		return true
+	}
+	return err == resource.ErrConflict
+}
+
+// WithConflictDetails makes Update, Delete and idempotent Insert return a
+// *ConflictError describing the stored item instead of resource.ErrConflict.
+// As it isn't equal to resource.ErrConflict, the API layer must recognize it,
+// with IsConflict, to answer 409.
+func WithConflictDetails(enabled bool) Option {
+	return func(h *Handler) {
+		h.conflictDetails = enabled
+	}
+}
+
+// conflict returns the error of a write conflicting with the stored item of
+// the given etag and updated time.
+func (h *Handler) conflict(id interface{}, etag string, updated sql.NullString) error {
+	if !h.conflictDetails {
+		return resource.ErrConflict
+	}
+	e := &ConflictError{ID: id, ETag: etag}
+	if updated.Valid {
+		t, err := h.timeFormat.parse(updated.String)
+		if err != nil {
+			log.WithField("error", err).Warn("Error parsing updated.")
+		}
+		e.Updated = t
+	}
+	return e
+}
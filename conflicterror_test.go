@@ -0,0 +1,46 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConflictDetails(t *testing.T) {
+	Convey("Given a handler returning conflict details", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ch := NewHandler(h.session, DB_TABLE, WithConflictDetails(true), WithIdempotentInsert(true))
+		i, _ := item("foo", 1)
+		So(ch.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+		stale := *i
+		stale.ETag = "stale"
+
+		Convey("Conflicts should describe the stored item", func() {
+			err := ch.Delete(context.Background(), &stale)
+			So(IsConflict(err), ShouldBeTrue)
+			ce, ok := err.(*ConflictError)
+			So(ok, ShouldBeTrue)
+			So(ce.ID, ShouldEqual, i.ID)
+			So(ce.ETag, ShouldEqual, i.ETag)
+			So(ce.Updated.Equal(i.Updated), ShouldBeTrue)
+			So(ce.Unwrap(), ShouldEqual, resource.ErrConflict)
+
+			err = ch.Insert(context.Background(), []*resource.Item{&stale})
+			So(IsConflict(err), ShouldBeTrue)
+			So(err.(*ConflictError).ETag, ShouldEqual, i.ETag)
+		})
+
+		Convey("Conflicts should be plain without the option", func() {
+			So(h.Delete(context.Background(), &stale), ShouldEqual, resource.ErrConflict)
+			So(IsConflict(resource.ErrConflict), ShouldBeTrue)
+			So(IsConflict(resource.ErrNotFound), ShouldBeFalse)
+		})
+	})
+}
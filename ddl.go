@@ -0,0 +1,177 @@
+package sqlite3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// IDColumnDDL returns the definition of the id column for a schema. When the
+// id field is a schema.Reference, the id is also a foreign key to the
+// referenced table, making the resource a 1:1 extension of it (e.g. user
+// profiles keyed by user id). Extension rows are deleted with the row they
+// extend.
+func IDColumnDDL(s schema.Schema) string {
+	def := "`id` VARCHAR(128) PRIMARY KEY"
+	if path, ok := referencePath(s["id"]); ok {
+		def += fmt.Sprintf(" REFERENCES `%s`(`id`) ON DELETE CASCADE", referenceTable(path))
+	}
+	return def
+}
+
+// referencePath returns the resource path referenced by a field, if any.
+func referencePath(f schema.Field) (string, bool) {
+	switch v := f.Validator.(type) {
+	case *schema.Reference:
+		return v.Path, true
+	case schema.Reference:
+		return v.Path, true
+	}
+	return "", false
+}
+
+// referenceTable returns the table of a referenced resource path. Tables are
+// assumed to be named after the last element of the path, so "users.posts"
+// refers to the "posts" table.
+func referenceTable(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+// TableDDL returns the CREATE TABLE statement of a table storing items of the
+// schema: the id, etag, updated and created columns, followed by a column per
+// schema field in name order. Reference fields are foreign keys to the
+// referenced table, deleted in cascade. The statement does nothing if the
+// table already exists.
+func TableDDL(table string, s schema.Schema) string {
+	return tableDDL(NewHandler(nil, table), s)
+}
+
+// GenerateDDL returns the statements creating the tables of the resources, by
+// table name, with the indexes of their filterable and sortable fields as
+// EnsureIndexes would create them. Tables come in name order, each followed
+// by its indexes, so the output can be versioned along with the schemas. The
+// options describe the handlers that will serve the tables.
+func GenerateDDL(resources map[string]schema.Schema, opts ...Option) ([]string, error) {
+	tables := make([]string, 0, len(resources))
+	for table := range resources {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	stmts := []string{}
+	for _, table := range tables {
+		if !identRe.MatchString(table) {
+			return nil, fmt.Errorf("sqlite3: invalid table name: %q", table)
+		}
+		h := NewHandler(nil, table, opts...)
+		indexes, err := indexStatements(h, resources[table], nil)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, tableDDL(h, resources[table]))
+		for _, idx := range sortedColumns(indexes) {
+			stmts = append(stmts, indexes[idx])
+		}
+	}
+	return stmts, nil
+}
+
+// EnsureTable creates the handler's table from the schema, as described by
+// TableDDL, if it does not exist yet. Fields colliding with meta columns get
+// their own column when the handler namespaces them. With StorageJSON, the
+// table only has the id, etag, updated and payload columns, with hybrid
+// storage it gets the extra column. Handlers running without etags
+// (ETagNone) get no etag column, soft deleting handlers get the deleted_at
+// column. An existing table is left as it is, even if it doesn't match the
+// schema.
+func (h *Handler) EnsureTable(ctx context.Context, s schema.Schema) error {
+	if h.optionErr != nil {
+		return h.optionErr
+	}
+	if _, err := h.session.ExecContext(ctx, tableDDL(h, s)); err != nil {
+		log.WithFields(log.Fields{
+			"table": h.tableName,
+			"error": err,
+		}).Warn("Error creating table.")
+		return ctxErr(ctx, err)
+	}
+	return nil
+}
+
+// tableDDL returns the CREATE TABLE statement of the handler's table.
+func tableDDL(h *Handler, s schema.Schema) string {
+	etag := "`" + h.column("etag") + "` VARCHAR(128),"
+	if h.etagMode == ETagNone {
+		etag = ""
+	}
+	deleted := ""
+	if h.softDelete {
+		deleted = ",`" + DeletedColumn + "` " + h.timeFormat.columnType()
+	}
+	if h.storage == StorageJSON {
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s,%s`%s` %s,`%s` TEXT%s);",
+			h.tableName, IDColumnDDL(s), etag, h.column("updated"), h.timeFormat.columnType(), PayloadColumn, deleted)
+	}
+	cols := []string{IDColumnDDL(s)}
+	if etag != "" {
+		cols = append(cols, strings.TrimSuffix(etag, ","))
+	}
+	ts := h.timeFormat.columnType()
+	cols = append(cols, "`"+h.column("updated")+"` "+ts, "`"+h.column("created")+"` "+ts)
+	names := make([]string, 0, len(s))
+	for name := range s {
+		if name == "id" || name == "created" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		col, skip, err := h.payloadColumn(name)
+		if skip || err != nil {
+			continue
+		}
+		cols = append(cols, fmt.Sprintf("`%s` %s%s", col, h.columnType(s[name]), h.collate(name)))
+	}
+	if h.storage == StorageHybrid {
+		cols = append(cols, "`"+ExtraColumn+"` TEXT")
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s%s);", h.tableName, strings.Join(cols, ","), deleted)
+}
+
+// columnType returns the column type of a schema field, with its foreign key
+// clause if it is a reference. Time fields get the column type of the
+// handler's time format.
+func (h *Handler) columnType(f schema.Field) string {
+	if path, ok := referencePath(f); ok {
+		return fmt.Sprintf("VARCHAR(128) REFERENCES `%s`(`id`) ON DELETE CASCADE", referenceTable(path))
+	}
+	switch v := f.Validator.(type) {
+	case *schema.String:
+		return stringType(v.MaxLen)
+	case schema.String:
+		return stringType(v.MaxLen)
+	case *schema.Integer, schema.Integer, *schema.Bool, schema.Bool:
+		return "INTEGER"
+	case *schema.Float, schema.Float:
+		return "REAL"
+	case *schema.Time, schema.Time:
+		return h.timeFormat.columnType()
+	case *schema.Password, schema.Password:
+		// password hashes are byte arrays
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// stringType returns the column type of a string of at most maxLen characters.
+func stringType(maxLen int) string {
+	if maxLen > 0 {
+		return fmt.Sprintf("VARCHAR(%d)", maxLen)
+	}
+	return "TEXT"
+}
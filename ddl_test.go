@@ -0,0 +1,104 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDDL(t *testing.T) {
+	Convey("The id column should be a primary key", t, func() {
+		s := schema.Schema{"id": schema.IDField}
+		So(IDColumnDDL(s), ShouldEqual, "`id` VARCHAR(128) PRIMARY KEY")
+	})
+
+	Convey("A reference id should also be a foreign key", t, func() {
+		s := schema.Schema{"id": schema.Field{Validator: &schema.Reference{Path: "users"}}}
+		So(IDColumnDDL(s), ShouldEqual, "`id` VARCHAR(128) PRIMARY KEY REFERENCES `users`(`id`) ON DELETE CASCADE")
+
+		s = schema.Schema{"id": schema.Field{Validator: &schema.Reference{Path: "users.profiles"}}}
+		So(IDColumnDDL(s), ShouldEqual, "`id` VARCHAR(128) PRIMARY KEY REFERENCES `profiles`(`id`) ON DELETE CASCADE")
+	})
+}
+
+func TestTableDDL(t *testing.T) {
+	Convey("Tables should have a column per schema field", t, func() {
+		s := schema.Schema{
+			"id":      schema.IDField,
+			"created": schema.CreatedField,
+			"updated": schema.UpdatedField,
+			"user":    schema.Field{Validator: &schema.Reference{Path: "users"}},
+			"public":  schema.Field{Validator: &schema.Bool{}},
+			"title":   schema.Field{Validator: &schema.String{MaxLen: 150}},
+			"body":    schema.Field{Validator: &schema.String{}},
+			"score":   schema.Field{Validator: &schema.Float{}},
+			"tags":    schema.Field{Validator: &schema.Array{}},
+		}
+		So(TableDDL("posts", s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `posts` ("+
+			"`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),"+
+			"`body` TEXT,`public` INTEGER,`score` REAL,`tags` TEXT,`title` VARCHAR(150),"+
+			"`user` VARCHAR(128) REFERENCES `users`(`id`) ON DELETE CASCADE);")
+	})
+}
+
+func TestEnsureTable(t *testing.T) {
+	s := schema.Schema{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"updated": schema.UpdatedField,
+		"etag":    schema.Field{Validator: &schema.String{}},
+		"f1":      schema.Field{Validator: &schema.String{MaxLen: 128}},
+		"f2":      schema.Field{Validator: &schema.Integer{}},
+	}
+
+	Convey("Meta fields should only get a column when namespaced", t, func() {
+		So(TableDDL("t", s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`f1` VARCHAR(128),`f2` INTEGER);")
+		h := NewHandler(nil, "t", WithMetaCollision(MetaNamespace))
+		So(tableDDL(h, s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`_etag` TEXT,`f1` VARCHAR(128),`f2` INTEGER,`_updated` VARCHAR(128));")
+	})
+
+	Convey("EnsureTable should create a usable table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		So(h.EnsureTable(context.Background(), s), ShouldBeNil)
+		So(h.EnsureTable(context.Background(), s), ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+		result, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+		So(err, ShouldBeNil)
+		So(len(result.Items), ShouldEqual, 1)
+	})
+}
+
+func TestGenerateDDL(t *testing.T) {
+	resources := map[string]schema.Schema{
+		"users": schema.Schema{
+			"id":   schema.IDField,
+			"name": schema.Field{Validator: &schema.String{MaxLen: 64}, Sortable: true},
+		},
+		"posts": schema.Schema{
+			"id":   schema.IDField,
+			"user": schema.Field{Validator: &schema.Reference{Path: "users"}, Filterable: true},
+		},
+	}
+
+	Convey("Tables should come in name order, followed by their indexes", t, func() {
+		stmts, err := GenerateDDL(resources)
+		So(err, ShouldBeNil)
+		So(stmts, ShouldResemble, []string{
+			"CREATE TABLE IF NOT EXISTS `posts` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`user` VARCHAR(128) REFERENCES `users`(`id`) ON DELETE CASCADE);",
+			"CREATE INDEX IF NOT EXISTS `posts_idx_user` ON `posts`(user);",
+			"CREATE TABLE IF NOT EXISTS `users` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`name` VARCHAR(64));",
+			"CREATE INDEX IF NOT EXISTS `users_idx_name` ON `users`(name);",
+		})
+	})
+
+	Convey("Invalid table names should be rejected", t, func() {
+		_, err := GenerateDDL(map[string]schema.Schema{"a b": schema.Schema{}})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,59 @@
+package sqlite3
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// Delta lists the changes made to a resource during a time window.
+type Delta struct {
+	// Items are the items created or updated during the window, oldest first.
+	Items []*resource.Item
+	// Tombstones are the items deleted during the window, if the handler
+	// records tombstones.
+	Tombstones []Tombstone
+	// Until is the end of the window. Pass it as since on the next call to
+	// continue the synchronization.
+	Until time.Time
+}
+
+// FindChangedSince returns the items created or updated, and the tombstones
+// recorded, since the given time. A positive window bounds the amount of
+// history returned at once: changes are then only read up to since+window, so
+// a client far behind can catch up in steps. Both lists are read in a single
+// transaction.
+func (h *Handler) FindChangedSince(ctx context.Context, since time.Time, window time.Duration) (*Delta, error) {
+	until := h.clock.Now()
+	if window > 0 && since.Add(window).Before(until) {
+		until = since.Add(window)
+	}
+
+	txPtr, err := h.session.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting delta transaction.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer txPtr.Rollback()
+
+	upd := h.column("updated")
+	q := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY %s;", h.tableName,
+		h.liveWhere(fmt.Sprintf("%s >= %s AND %s < %s", upd, h.timeFormat.literal(since), upd, h.timeFormat.literal(until))), upd)
+	list, err := runSelect(ctx, h, txPtr, q, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Delta{Items: list.Items, Tombstones: []Tombstone{}, Until: until}
+	if h.tombstones {
+		d.Tombstones, err = tombstonesBetween(ctx, h, txPtr, since, until)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
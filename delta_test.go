@@ -0,0 +1,48 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindChangedSince(t *testing.T) {
+	Convey("Given a handler recording tombstones", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		th := NewHandler(h.session, DB_TABLE, WithTombstones())
+		h.session.Exec("DROP TABLE `" + tombstoneTable(th) + "`;")
+		So(th.CreateTombstoneTable(context.Background()), ShouldBeNil)
+
+		old, _ := item("old", 1)
+		old.Updated = time.Now().Add(-time.Hour)
+		So(th.Insert(context.Background(), []*resource.Item{old, i1, i2}), ShouldBeNil)
+		So(th.Delete(context.Background(), i2), ShouldBeNil)
+		since := time.Now().Add(-time.Minute)
+
+		Convey("FindChangedSince should return changes and deletions", func() {
+			d, err := th.FindChangedSince(context.Background(), since, 0)
+			So(err, ShouldBeNil)
+			So(len(d.Items), ShouldEqual, 1)
+			So(d.Items[0].ID, ShouldEqual, i1.ID)
+			So(len(d.Tombstones), ShouldEqual, 1)
+			So(d.Tombstones[0].ID, ShouldEqual, i2.ID)
+			So(d.Until.After(since), ShouldBeTrue)
+		})
+
+		Convey("FindChangedSince should honor the window", func() {
+			d, err := th.FindChangedSince(context.Background(), time.Now().Add(-2*time.Hour), 90*time.Minute)
+			So(err, ShouldBeNil)
+			So(len(d.Items), ShouldEqual, 1)
+			So(d.Items[0].ID, ShouldEqual, old.ID)
+			So(len(d.Tombstones), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// SchemaHandler is a read-only resource storage handler listing the tables of a
+// SQLite3 database with their columns, indexes and row counts. Bind it to an
+// admin resource to browse the database structure through the REST API.
+type SchemaHandler struct {
+	session *sql.DB
+}
+
+// NewSchemaHandler creates a new schema discovery handler for the database.
+func NewSchemaHandler(s *sql.DB) *SchemaHandler {
+	return &SchemaHandler{session: s}
+}
+
+// Find returns one item per table matching the lookup. The item ID is the
+// table name.
+func (h *SchemaHandler) Find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*resource.ItemList, error) {
+	tables, err := tableNames(ctx, h.session)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*resource.Item{}
+	for _, t := range tables {
+		p, err := describeTable(ctx, h.session, t)
+		if err != nil {
+			return nil, err
+		}
+		if !lookup.Filter().Match(p) {
+			continue
+		}
+		item, err := resource.NewItem(p)
+		if err != nil {
+			log.WithField("error", err).Warn("Error creating an Item from a table description.")
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return pageItems(items, page, perPage), nil
+}
+
+// Insert is not supported by the schema handler.
+func (h *SchemaHandler) Insert(ctx context.Context, items []*resource.Item) error {
+	return resource.ErrNotImplemented
+}
+
+// Update is not supported by the schema handler.
+func (h *SchemaHandler) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	return resource.ErrNotImplemented
+}
+
+// Delete is not supported by the schema handler.
+func (h *SchemaHandler) Delete(ctx context.Context, item *resource.Item) error {
+	return resource.ErrNotImplemented
+}
+
+// Clear is not supported by the schema handler.
+func (h *SchemaHandler) Clear(ctx context.Context, lookup *resource.Lookup) (int, error) {
+	return 0, resource.ErrNotImplemented
+}
+
+// tableNames returns the names of the user tables in the database, excluding
+// SQLite's internal tables.
+func tableNames(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY name;")
+	if err != nil {
+		log.WithField("error", err).Warn("Error listing tables.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			log.WithField("error", err).Warn("Error scanning table name.")
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// describeTable returns the columns, indexes and row count of a table as an
+// item payload.
+func describeTable(ctx context.Context, db *sql.DB, table string) (map[string]interface{}, error) {
+	cols, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := tableIndexes(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM `%s`;", table)).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": table,
+			"error": err,
+		}).Warn("Error counting rows.")
+		return nil, ctxErr(ctx, err)
+	}
+	return map[string]interface{}{
+		"id":      table,
+		"name":    table,
+		"columns": cols,
+		"indexes": idx,
+		"rows":    count,
+	}, nil
+}
+
+// tableColumns describes the columns of a table using PRAGMA table_info.
+func tableColumns(ctx context.Context, db *sql.DB, table string) ([]interface{}, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(`%s`);", table))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": table,
+			"error": err,
+		}).Warn("Error querying table info.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	cols := []interface{}{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			log.WithField("error", err).Warn("Error scanning table info.")
+			return nil, err
+		}
+		col := map[string]interface{}{
+			"name":    name,
+			"type":    typ,
+			"notnull": notNull != 0,
+			"pk":      pk != 0,
+		}
+		if dflt.Valid {
+			col["default"] = dflt.String
+		}
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
+
+// tableIndexes describes the indexes of a table using PRAGMA index_list.
+func tableIndexes(ctx context.Context, db *sql.DB, table string) ([]interface{}, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA index_list(`%s`);", table))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": table,
+			"error": err,
+		}).Warn("Error querying index list.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	idx := []interface{}{}
+	for rows.Next() {
+		// the number of columns returned by index_list varies between SQLite
+		// versions, only the first three are used.
+		cols, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			log.WithField("error", err).Warn("Error scanning index list.")
+			return nil, err
+		}
+		name := vals[1]
+		if b, ok := name.([]byte); ok {
+			name = string(b)
+		}
+		unique, _ := vals[2].(int64)
+		idx = append(idx, map[string]interface{}{
+			"name":   name,
+			"unique": unique != 0,
+		})
+	}
+	return idx, rows.Err()
+}
@@ -0,0 +1,52 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaHandler(t *testing.T) {
+	Convey("Given a schema handler on the test database", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		sh := NewSchemaHandler(h.session)
+
+		Convey("Find should describe the test table", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "name", Value: DB_TABLE}})
+			list, err := sh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Total, ShouldEqual, 1)
+			So(len(list.Items), ShouldEqual, 1)
+
+			item := list.Items[0]
+			So(item.ID, ShouldEqual, DB_TABLE)
+			So(item.Payload["rows"], ShouldEqual, 2)
+			cols := item.Payload["columns"].([]interface{})
+			So(len(cols), ShouldEqual, 6)
+			So(cols[0].(map[string]interface{})["name"], ShouldEqual, "id")
+			So(cols[0].(map[string]interface{})["pk"], ShouldBeTrue)
+		})
+
+		Convey("Find should stop when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := sh.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("Mutations should not be implemented", func() {
+			So(sh.Insert(context.Background(), []*resource.Item{i1}), ShouldEqual, resource.ErrNotImplemented)
+			So(sh.Delete(context.Background(), i1), ShouldEqual, resource.ErrNotImplemented)
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package sqlite3
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrDraining is returned by the operations of a handler being drained.
+var ErrDraining = errors.New("sqlite3: handler is shutting down")
+
+// opTracker counts the operations in flight on a handler.
+type opTracker struct {
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+}
+
+// begin registers a new operation, or returns ErrDraining if the handler is
+// being drained. Each successful begin must be followed by a call to end.
+func (t *opTracker) begin() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return ErrDraining
+	}
+	t.wg.Add(1)
+	return nil
+}
+
+// end unregisters a finished operation.
+func (t *opTracker) end() {
+	t.wg.Done()
+}
+
+// Drain stops the handler from accepting new operations, which then return
+// ErrDraining, and waits for the operations in flight to finish before
+// checkpointing the WAL into the database file, so the process can be
+// stopped without interrupting a write. If ctx is done first, Drain returns
+// its error without checkpointing. A drained handler can't be reused.
+func (h *Handler) Drain(ctx context.Context) error {
+	h.ops.mu.Lock()
+	h.ops.draining = true
+	h.ops.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.ops.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.WithField("table", h.tableName).Warn("Drain deadline reached with operations in flight.")
+		return ctx.Err()
+	}
+
+	_, err := h.Checkpoint(ctx, CheckpointTruncate)
+	return err
+}
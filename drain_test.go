@@ -0,0 +1,53 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDrain(t *testing.T) {
+	Convey("Given a handler", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		Convey("Drain should refuse new operations", func() {
+			So(h.Drain(context.Background()), ShouldBeNil)
+			_, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldEqual, ErrDraining)
+			So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldEqual, ErrDraining)
+			So(h.Delete(context.Background(), i1), ShouldEqual, ErrDraining)
+			_, _, err = h.InsertOrGet(context.Background(), i1, []string{"f1"})
+			So(err, ShouldEqual, ErrDraining)
+			_, err = h.Backfill(context.Background(), "f1", "x", 0, nil)
+			So(err, ShouldEqual, ErrDraining)
+			_, err = h.Purge(context.Background(), time.Now())
+			So(err, ShouldEqual, ErrDraining)
+			So(h.Undelete(context.Background(), i1.ID), ShouldEqual, ErrDraining)
+			So(h.Restore(context.Background(), i1.ID, 1), ShouldEqual, ErrDraining)
+			_, err = h.Migrate(context.Background(), schema.Schema{"f1": schema.Field{}}, MigrateOptions{})
+			So(err, ShouldEqual, ErrDraining)
+			So(h.RestoreBackup(context.Background(), DB_FILE), ShouldEqual, ErrDraining)
+		})
+
+		Convey("Drain should wait for operations in flight", func() {
+			So(h.ops.begin(), ShouldBeNil)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			So(h.Drain(ctx), ShouldEqual, context.DeadlineExceeded)
+
+			drained := make(chan error)
+			go func() { drained <- h.Drain(context.Background()) }()
+			h.ops.end()
+			So(<-drained, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,113 @@
+//go:build !modernc
+// +build !modernc
+
+package sqlite3
+
+import (
+	"database/sql"
+	"errors"
+
+	gosqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// DriverImpl names the SQLite driver the package is built with:
+// "go-sqlite3", or "modernc" when built with the modernc tag.
+const DriverImpl = "go-sqlite3"
+
+func init() {
+	sql.Register(DriverName, &gosqlite3.SQLiteDriver{
+		ConnectHook: registerFunctions,
+	})
+}
+
+// registerFunctions registers REGEXP and the functions registered with
+// RegisterFunction on a connection.
+func registerFunctions(conn *gosqlite3.SQLiteConn) error {
+	if err := conn.RegisterFunc("regexp", regexpMatch, true); err != nil {
+		return err
+	}
+	for n, f := range registeredFunctions() {
+		if err := conn.RegisterFunc(n, f.Impl, f.Pure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerFunction does nothing: the connect hooks register the functions of
+// RegisterFunction on each new connection.
+func registerFunction(name string, f Function) error {
+	return nil
+}
+
+// registerDriver registers a go-sqlite3 driver whose connect hook applies c.
+// Extensions with the default entry point are loaded by the driver, since
+// LoadExtension always passes SQLite an entry point name.
+func registerDriver(name string, c DriverConfig) error {
+	var paths []string
+	for _, e := range c.Extensions {
+		if e.Entry == "" {
+			paths = append(paths, e.Path)
+		}
+	}
+	sql.Register(name, &gosqlite3.SQLiteDriver{
+		Extensions: paths,
+		ConnectHook: func(conn *gosqlite3.SQLiteConn) error {
+			if err := registerFunctions(conn); err != nil {
+				return err
+			}
+			for _, e := range c.Extensions {
+				if e.Entry == "" {
+					continue
+				}
+				if err := conn.LoadExtension(e.Path, e.Entry); err != nil {
+					return err
+				}
+			}
+			for n, f := range c.Functions {
+				if err := conn.RegisterFunc(n, f.Impl, f.Pure); err != nil {
+					return err
+				}
+			}
+			for n, cmp := range c.Collations {
+				if err := conn.RegisterCollation(n, cmp); err != nil {
+					return err
+				}
+			}
+			if c.ConnectHook != nil {
+				return c.ConnectHook(conn)
+			}
+			return nil
+		},
+	})
+	return nil
+}
+
+// errorCode returns the extended result code of an error returned by the
+// driver, and whether it is one.
+func errorCode(err error) (int, bool) {
+	var se gosqlite3.Error
+	if errors.As(err, &se) {
+		return int(se.ExtendedCode), true
+	}
+	return 0, false
+}
+
+// backupConns copies the main database of the driver connection s over the
+// one of d with SQLite's online backup API, in a single step.
+func backupConns(d, s interface{}) error {
+	dconn, ok := d.(*gosqlite3.SQLiteConn)
+	sconn, ok2 := s.(*gosqlite3.SQLiteConn)
+	if !ok || !ok2 {
+		return errors.New("sqlite3: backups need go-sqlite3 connections")
+	}
+	b, err := dconn.Backup("main", sconn, "main")
+	if err != nil {
+		return err
+	}
+	if _, err = b.Step(-1); err != nil {
+		b.Finish()
+		return err
+	}
+	return b.Finish()
+}
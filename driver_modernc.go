@@ -0,0 +1,86 @@
+//go:build modernc
+// +build modernc
+
+package sqlite3
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"modernc.org/sqlite"
+)
+
+// DriverImpl names the SQLite driver the package is built with:
+// "go-sqlite3", or "modernc" when built with the modernc tag.
+const DriverImpl = "modernc"
+
+func init() {
+	sql.Register(DriverName, &sqlite.Driver{})
+	// modernc registers functions for all its connections
+	err := sqlite.RegisterDeterministicScalarFunction("regexp", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		pattern, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("sqlite3: invalid REGEXP pattern: %v", args[0])
+		}
+		match, err := regexpMatch(pattern, args[1])
+		if err != nil || !match {
+			return int64(0), err
+		}
+		return int64(1), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// registerDriver registers a modernc driver. Its functions and collations are
+// registered for all the connections of the process.
+func registerDriver(name string, c DriverConfig) error {
+	if len(c.Extensions) > 0 || c.ConnectHook != nil {
+		return errors.New("sqlite3: extensions and connect hooks need the go-sqlite3 driver")
+	}
+	for n, f := range c.Functions {
+		if err := registerFunction(n, f); err != nil {
+			return err
+		}
+	}
+	for n, cmp := range c.Collations {
+		if err := sqlite.RegisterCollationUtf8(n, cmp); err != nil {
+			return err
+		}
+	}
+	sql.Register(name, &sqlite.Driver{})
+	return nil
+}
+
+// registerFunction registers a function for all the connections of the
+// process.
+func registerFunction(name string, f Function) error {
+	impl, ok := f.Impl.(func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error))
+	if !ok {
+		return fmt.Errorf("sqlite3: function %s has an unsupported signature", name)
+	}
+	register := sqlite.RegisterScalarFunction
+	if f.Pure {
+		register = sqlite.RegisterDeterministicScalarFunction
+	}
+	return register(name, -1, impl)
+}
+
+// errorCode returns the extended result code of an error returned by the
+// driver, and whether it is one.
+func errorCode(err error) (int, bool) {
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		return se.Code(), true
+	}
+	return 0, false
+}
+
+// backupConns is not supported by the modernc driver, whose backup API takes
+// the URI of the other database rather than its connection.
+func backupConns(d, s interface{}) error {
+	return errors.New("sqlite3: backups need the go-sqlite3 driver")
+}
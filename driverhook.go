@@ -0,0 +1,80 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Extension is a SQLite extension loaded on the connections of a driver
+// registered with RegisterDriver.
+type Extension struct {
+	// Path is the path of the shared library.
+	Path string
+	// Entry is the name of its entry point; empty means the default one.
+	Entry string
+}
+
+// Function is a Go function callable from SQL on the connections of a driver
+// registered with RegisterDriver.
+type Function struct {
+	// Impl is the function. With go-sqlite3, it is any function accepted by
+	// SQLiteConn.RegisterFunc; with the modernc driver, a
+	// func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error).
+	Impl interface{}
+	// Pure tells SQLite the function always returns the same result for the
+	// same arguments, so it can be used in indexes and optimized.
+	Pure bool
+}
+
+// DriverConfig customizes the connections of a driver registered with
+// RegisterDriver.
+type DriverConfig struct {
+	// Extensions are loaded on each connection, in order, those with the
+	// default entry point first.
+	Extensions []Extension
+	// Functions are registered on each connection, by SQL name.
+	Functions map[string]Function
+	// Collations are registered on each connection, by name, and can be used
+	// with WithCollation.
+	Collations map[string]func(a, b string) int
+	// ConnectHook, if set, is called last on each new connection with the
+	// driver connection (a *sqlite3.SQLiteConn of go-sqlite3), for anything
+	// the other fields don't cover.
+	ConnectHook func(conn interface{}) error
+}
+
+// RegisterDriver registers a SQLite driver under name whose connections load
+// the extensions and register the functions and collations of c, besides the
+// REGEXP function of DriverName, so applications don't have to write their
+// own sql.Register and connect hook. Open the database with
+// sql.Open(name, path), or set the Driver of Bootstrap or ReadOnlyOptions.
+//
+// With the modernc driver, functions and collations are registered for all
+// the connections of the process, and extensions and connect hooks aren't
+// supported.
+func RegisterDriver(name string, c DriverConfig) error {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return fmt.Errorf("sqlite3: driver %q already registered", name)
+		}
+	}
+	for f := range c.Functions {
+		if !identRe.MatchString(f) {
+			return fmt.Errorf("sqlite3: invalid function name: %q", f)
+		}
+	}
+	for n := range c.Collations {
+		if !identRe.MatchString(n) {
+			return fmt.Errorf("sqlite3: invalid collation name: %q", n)
+		}
+	}
+	return registerDriver(name, c)
+}
+
+// driverName returns the driver to open databases with: name, or DriverName.
+func driverName(name string) string {
+	if name == "" {
+		return DriverName
+	}
+	return name
+}
@@ -0,0 +1,147 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegisterDriver(t *testing.T) {
+	if DriverImpl != "go-sqlite3" {
+		t.Skip("connect hooks need go-sqlite3")
+	}
+	const name = "sqlite3_driverhook_test"
+	hooked := 0
+	err := RegisterDriver(name, DriverConfig{
+		Functions: map[string]Function{
+			"reverse": {Impl: func(s string) string {
+				r := []rune(s)
+				for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+					r[i], r[j] = r[j], r[i]
+				}
+				return string(r)
+			}, Pure: true},
+		},
+		Collations: map[string]func(a, b string) int{
+			"bylength": func(a, b string) int { return len(a) - len(b) },
+		},
+		ConnectHook: func(conn interface{}) error {
+			hooked++
+			return nil
+		},
+	})
+
+	Convey("RegisterDriver should register the driver", t, func() {
+		So(err, ShouldBeNil)
+		So(RegisterDriver(name, DriverConfig{}), ShouldNotBeNil)
+		So(RegisterDriver(name+"_bad", DriverConfig{Functions: map[string]Function{"x y": {}}}), ShouldNotBeNil)
+		So(RegisterDriver(name+"_bad", DriverConfig{Collations: map[string]func(a, b string) int{"x;": nil}}), ShouldNotBeNil)
+	})
+
+	Convey("Given a database opened with the driver", t, func() {
+		db, err := sql.Open(name, ":memory:")
+		So(err, ShouldBeNil)
+		db.SetMaxOpenConns(1)
+		Reset(func() { db.Close() })
+
+		Convey("Its connections should have the functions and collations", func() {
+			var s string
+			So(db.QueryRow("SELECT reverse('abc')").Scan(&s), ShouldBeNil)
+			So(s, ShouldEqual, "cba")
+			So(db.QueryRow("SELECT 'b' REGEXP '^[a-c]$'").Scan(&s), ShouldBeNil)
+			So(s, ShouldEqual, "1")
+			So(hooked, ShouldBeGreaterThan, 0)
+
+			h := NewHandler(db, "t", WithCollation("f1", "bylength"))
+			So(h.EnsureTable(context.Background(), schema.Schema{
+				"id": schema.IDField,
+				"f1": schema.Field{Validator: &schema.String{}, Sortable: true},
+			}), ShouldBeNil)
+			var items []*resource.Item
+			for _, v := range []string{"ccc", "a", "bb"} {
+				it, _ := resource.NewItem(map[string]interface{}{"id": v, "f1": v})
+				items = append(items, it)
+			}
+			So(h.Insert(context.Background(), items), ShouldBeNil)
+			l := resource.NewLookup()
+			l.SetSort("f1", nil)
+			list, err := h.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 3)
+			So(list.Items[0].ID, ShouldEqual, "a")
+			So(list.Items[2].ID, ShouldEqual, "ccc")
+		})
+	})
+
+	Convey("Extensions failing to load should fail the connection", t, func() {
+		So(RegisterDriver(name+"_ext", DriverConfig{Extensions: []Extension{{Path: "./no_such_extension"}}}), ShouldBeNil)
+		db, err := sql.Open(name+"_ext", ":memory:")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		err = db.Ping()
+		So(err, ShouldNotBeNil)
+		So(strings.Contains(err.Error(), "no_such_extension"), ShouldBeTrue)
+	})
+
+	Convey("Given an extension library", t, func() {
+		cc, err := exec.LookPath("cc")
+		if err != nil {
+			SkipSo("no C compiler to build the extension")
+			return
+		}
+		dir, err := ioutil.TempDir("", "driverhook")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(dir) })
+		// the entry points don't need the SQLite API: loading succeeds if
+		// SQLite finds and calls them
+		src := filepath.Join(dir, "ext.c")
+		So(ioutil.WriteFile(src, []byte(
+			"int sqlite3_extension_init(void *db, char **err, const void *api) { return 0; }\n"+
+				"int named_init(void *db, char **err, const void *api) { return 0; }\n"), 0644), ShouldBeNil)
+		lib := filepath.Join(dir, "ext.so")
+		out, err := exec.Command(cc, "-shared", "-fPIC", "-o", lib, src).CombinedOutput()
+		So(err, ShouldBeNil)
+		So(string(out), ShouldBeEmpty)
+		ping := func(driver string, e Extension) error {
+			So(RegisterDriver(driver, DriverConfig{Extensions: []Extension{e}}), ShouldBeNil)
+			db, err := sql.Open(driver, ":memory:")
+			So(err, ShouldBeNil)
+			defer db.Close()
+			return db.Ping()
+		}
+
+		Convey("It should load with the default entry point", func() {
+			So(ping(name+"_default", Extension{Path: lib}), ShouldBeNil)
+		})
+
+		Convey("It should load with a named entry point", func() {
+			So(ping(name+"_named", Extension{Path: lib, Entry: "named_init"}), ShouldBeNil)
+		})
+
+		Convey("It should fail with a missing entry point", func() {
+			So(ping(name+"_missing", Extension{Path: lib, Entry: "missing_init"}), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Open should use the driver of the bootstrap", t, func() {
+		const file = "./driverhook_test.db"
+		os.Remove(file)
+		defer os.Remove(file)
+		db, err := Open(context.Background(), file, Bootstrap{Driver: name})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		var s string
+		So(db.QueryRow("SELECT reverse('ab')").Scan(&s), ShouldBeNil)
+		So(s, ShouldEqual, "ba")
+	})
+}
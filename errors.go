@@ -0,0 +1,139 @@
+package sqlite3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+)
+
+// The kinds of StorageError, so callers can tell storage failures apart with
+// errors.Is.
+var (
+	// ErrStatementBuild is the kind of the errors building a statement, like
+	// a filter value that can't be written in SQL.
+	ErrStatementBuild = errors.New("sqlite3: error building statement")
+	// ErrExec is the kind of the errors running a statement.
+	ErrExec = errors.New("sqlite3: error executing statement")
+	// ErrScan is the kind of the errors reading a row of a result or
+	// converting it to an item.
+	ErrScan = errors.New("sqlite3: error scanning row")
+)
+
+// The SQLite result codes the handler tells apart, the same for both drivers.
+const (
+	codeBusy                 = 5
+	codeLocked               = 6
+	codeConstraintForeignKey = 787
+	codeConstraintPrimaryKey = 1555
+	codeConstraintUnique     = 2067
+)
+
+// StorageError is the error of a failed Find, Insert, Update, Delete or
+// Clear, wrapping the error that caused it. The errors the API layer already
+// recognizes, like resource.ErrNotImplemented or context errors, are returned
+// as they are, unique constraint violations as resource.ErrConflict, and
+// foreign key violations as ErrInvalidReference, or as resource.ErrConflict
+// when deleting an item still referenced.
+type StorageError struct {
+	// Kind is ErrStatementBuild, ErrExec or ErrScan.
+	Kind  error
+	Op    Operation
+	Table string
+	// SQL is the failed statement, only set by handlers created with
+	// WithErrorSQL.
+	SQL string
+	Err error
+}
+
+// Error returns the kind, operation and table of the error, followed by the
+// wrapped error and the statement, if any.
+func (e *StorageError) Error() string {
+	msg := fmt.Sprintf("%v (%s on %s): %v", e.Kind, e.Op, e.Table, e.Err)
+	if e.SQL != "" {
+		msg += ": " + e.SQL
+	}
+	return msg
+}
+
+// Unwrap returns the wrapped error.
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of the error.
+func (e *StorageError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// WithErrorSQL makes StorageError carry the failed statement. Statements
+// contain the filtered and written values, so errors shouldn't be exposed to
+// clients with this option.
+func WithErrorSQL(enabled bool) Option {
+	return func(h *Handler) {
+		h.errorSQL = enabled
+	}
+}
+
+// storageErr returns the error of an operation on the handler's table that
+// failed with err running (or building, or reading the result of) the
+// statement q, wrapped in a StorageError of the given kind.
+func (h *Handler) storageErr(ctx context.Context, kind error, op Operation, q string, err error) error {
+	if err = ctxErr(ctx, err); err == nil || isKnownErr(err) {
+		return err
+	}
+	if kind == ErrExec && isUniqueErr(err) {
+		return resource.ErrConflict
+	}
+	if kind == ErrExec && isForeignKeyErr(err) {
+		if op == OpDelete || op == OpClear {
+			return resource.ErrConflict
+		}
+		return ErrInvalidReference
+	}
+	e := &StorageError{Kind: kind, Op: op, Table: h.tableName, Err: err}
+	if h.errorSQL {
+		e.SQL = q
+	}
+	return e
+}
+
+// isUniqueErr reports whether an error is a violation of the primary key or
+// of a unique constraint, from its extended result code
+// (SQLITE_CONSTRAINT_PRIMARYKEY or SQLITE_CONSTRAINT_UNIQUE) or, for errors
+// not coming from the driver, from its message.
+func isUniqueErr(err error) bool {
+	if code, ok := errorCode(err); ok {
+		return code == codeConstraintPrimaryKey || code == codeConstraintUnique
+	}
+	return strings.HasPrefix(err.Error(), SQL_UNIQUE_ERR)
+}
+
+// isForeignKeyErr reports whether an error is a foreign key constraint
+// violation (SQLITE_CONSTRAINT_FOREIGNKEY).
+func isForeignKeyErr(err error) bool {
+	if code, ok := errorCode(err); ok {
+		return code == codeConstraintForeignKey
+	}
+	return err.Error() == SQL_FOREIGNKEY_ERR
+}
+
+// isKnownErr reports whether an error is one the API layer or the callers of
+// the handler already recognize, and must not be wrapped.
+func isKnownErr(err error) bool {
+	switch err {
+	case context.Canceled, context.DeadlineExceeded,
+		resource.ErrNotFound, resource.ErrConflict, resource.ErrNotImplemented,
+		ErrInvalidReference, ErrStatementTooLarge, ErrInvalidCursor, ErrDraining:
+		return true
+	}
+	switch err.(type) {
+	case *StorageError, *ConflictError, *rest.Error:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStorageErrors(t *testing.T) {
+	Convey("Given a handler on a missing table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		mh := NewHandler(h.session, "missing", WithErrorSQL(true))
+
+		Convey("Failed statements should be ErrExec errors", func() {
+			_, err := mh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(errors.Is(err, ErrExec), ShouldBeTrue)
+			So(errors.Is(err, ErrScan), ShouldBeFalse)
+			se, ok := err.(*StorageError)
+			So(ok, ShouldBeTrue)
+			So(se.Op, ShouldEqual, OpFind)
+			So(se.Table, ShouldEqual, "missing")
+			So(se.SQL, ShouldStartWith, "SELECT ")
+			So(strings.Contains(err.Error(), "no such table"), ShouldBeTrue)
+		})
+
+		Convey("Statements should only be included with WithErrorSQL", func() {
+			i, _ := item("foo", 1)
+			err := NewHandler(h.session, "missing").Insert(context.Background(), []*resource.Item{i})
+			So(errors.Is(err, ErrExec), ShouldBeTrue)
+			So(err.(*StorageError).SQL, ShouldEqual, "")
+		})
+	})
+
+	Convey("Statements that can't be built should be ErrStatementBuild errors", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		ctx := NewHintsContext(context.Background(), Hints{IndexedBy: "a b"})
+		_, err = h.Find(ctx, resource.NewLookup(), 1, 10)
+		So(errors.Is(err, ErrStatementBuild), ShouldBeTrue)
+	})
+
+	Convey("Errors callers recognize should not be wrapped", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		i, _ := item("foo", 1)
+		So(h.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i}), ShouldEqual, resource.ErrConflict)
+
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.LowerThan{Field: "f1", Value: nil}})
+		_, err = h.Find(context.Background(), l, 1, 10)
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+	})
+
+	Convey("Inserting an existing id should conflict", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		i, _ := item("foo", 1)
+		So(h.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+
+		other, _ := item("bar", 2)
+		other.ID = i.ID
+		other.Payload["id"] = i.ID
+		So(h.Insert(context.Background(), []*resource.Item{other}), ShouldEqual, resource.ErrConflict)
+
+		bh := NewHandler(h.session, DB_TABLE, WithInsertBatching(time.Millisecond, 10))
+		So(bh.Insert(context.Background(), []*resource.Item{other}), ShouldEqual, resource.ErrConflict)
+	})
+
+	Convey("Only primary key and unique violations should be conflicts", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE checked;")
+		_, err = h.session.Exec("CREATE TABLE checked (id VARCHAR(128) PRIMARY KEY, n INTEGER NOT NULL);")
+		So(err, ShouldBeNil)
+		defer h.session.Exec("DROP TABLE checked;")
+		_, err = h.session.Exec("INSERT INTO checked VALUES ('a', 1);")
+		So(err, ShouldBeNil)
+		_, err = h.session.Exec("INSERT INTO checked VALUES ('a', 2);")
+		So(isUniqueErr(err), ShouldBeTrue)
+		_, err = h.session.Exec("INSERT INTO checked VALUES ('b', NULL);")
+		So(isUniqueErr(err), ShouldBeFalse)
+	})
+
+	Convey("Given a table referencing another with foreign keys enforced", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		db, err := sql.Open(DB_DRIVER, DB_FILE+"?_foreign_keys=1")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		db.Exec("DROP TABLE `reftable`;")
+		_, err = db.Exec("CREATE TABLE `reftable` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`parent` VARCHAR(128) REFERENCES `" + DB_TABLE + "`(`id`));")
+		So(err, ShouldBeNil)
+		defer db.Exec("DROP TABLE `reftable`;")
+		parent, _ := item("foo", 1)
+		So(h.Insert(context.Background(), []*resource.Item{parent}), ShouldBeNil)
+		rh := NewHandler(db, "reftable")
+		child, _ := resource.NewItem(map[string]interface{}{"id": "c", "parent": parent.ID})
+		So(rh.Insert(context.Background(), []*resource.Item{child}), ShouldBeNil)
+
+		Convey("Referencing a missing item should be a 422 error", func() {
+			orphan, _ := resource.NewItem(map[string]interface{}{"id": "o", "parent": "missing"})
+			err := rh.Insert(context.Background(), []*resource.Item{orphan})
+			So(err, ShouldEqual, ErrInvalidReference)
+			So(err.(*rest.Error).Code, ShouldEqual, http.StatusUnprocessableEntity)
+
+			moved, _ := resource.NewItem(map[string]interface{}{"id": "c", "parent": "missing"})
+			So(rh.Update(context.Background(), moved, child), ShouldEqual, ErrInvalidReference)
+		})
+
+		Convey("Deleting a referenced item should conflict", func() {
+			fh := NewHandler(db, DB_TABLE)
+			So(fh.Delete(context.Background(), parent), ShouldEqual, resource.ErrConflict)
+		})
+	})
+}
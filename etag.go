@@ -0,0 +1,101 @@
+package sqlite3
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// ETagMode controls how Update and Delete compare the etag of the provided
+// item with the stored one.
+type ETagMode int
+
+const (
+	// ETagExact requires the etags to be identical. This is the default.
+	ETagExact ETagMode = iota
+	// ETagWeak compares etags as HTTP weak validators: a W/ prefix and
+	// surrounding quotes are ignored.
+	ETagWeak
+	// ETagSkipVerify doesn't compare etags at all, the last write wins. Only
+	// use it for trusted internal writers.
+	ETagSkipVerify
+	// ETagNone runs without etags: they are neither stored nor compared, so
+	// the table needs no etag column, and Update and Delete match on the id
+	// only. Items are read with an empty ETag. Only use it for trusted
+	// internal writers.
+	ETagNone
+)
+
+// ETagFunc computes the etag of an item payload.
+type ETagFunc func(payload map[string]interface{}) (string, error)
+
+// WithETagMode sets how etags are compared on Update and Delete.
+func WithETagMode(m ETagMode) Option {
+	return func(h *Handler) {
+		h.etagMode = m
+	}
+}
+
+// WithETagFunc makes Insert and Update recompute the etag of written items
+// with fn instead of trusting item.ETag. The item's ETag is updated in place,
+// so the etag returned to the client matches the stored one.
+func WithETagFunc(fn ETagFunc) Option {
+	return func(h *Handler) {
+		h.etagFunc = fn
+	}
+}
+
+// MD5ETag computes the etag of a payload as the MD5 sum of its JSON encoding.
+func MD5ETag(payload map[string]interface{}) (string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", md5.Sum(b)), nil
+}
+
+// setETag recomputes the etag of an item if the handler has an ETagFunc.
+func (h *Handler) setETag(i *resource.Item) error {
+	if h.etagFunc == nil {
+		return nil
+	}
+	etag, err := h.etagFunc(i.Payload)
+	if err != nil {
+		return err
+	}
+	i.ETag = etag
+	return nil
+}
+
+// etagsMatch compares a stored etag with a provided one according to the
+// handler's etag mode.
+func (h *Handler) etagsMatch(stored, given string) bool {
+	switch h.etagMode {
+	case ETagSkipVerify, ETagNone:
+		return true
+	case ETagWeak:
+		return weakETag(stored) == weakETag(given)
+	}
+	return stored == given
+}
+
+// verifiesETags reports whether Update and Delete compare etags.
+func (h *Handler) verifiesETags() bool {
+	return h.etagMode == ETagExact || h.etagMode == ETagWeak
+}
+
+// metaColumnList returns the meta columns always read by a select statement.
+func (h *Handler) metaColumnList() []string {
+	if h.etagMode == ETagNone {
+		return []string{"id", "updated", "created"}
+	}
+	return []string{"id", "etag", "updated", "created"}
+}
+
+// weakETag strips the weakness indicator and quotes of an etag.
+func weakETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
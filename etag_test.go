@@ -0,0 +1,99 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestETags(t *testing.T) {
+	Convey("Etags should be compared according to the mode", t, func() {
+		h := NewHandler(nil, DB_TABLE)
+		So(h.etagsMatch("abc", "abc"), ShouldBeTrue)
+		So(h.etagsMatch("abc", `W/"abc"`), ShouldBeFalse)
+
+		h = NewHandler(nil, DB_TABLE, WithETagMode(ETagWeak))
+		So(h.etagsMatch("abc", `W/"abc"`), ShouldBeTrue)
+		So(h.etagsMatch(`"abc"`, "abc"), ShouldBeTrue)
+		So(h.etagsMatch("abc", "abd"), ShouldBeFalse)
+
+		h = NewHandler(nil, DB_TABLE, WithETagMode(ETagSkipVerify))
+		So(h.etagsMatch("abc", "abd"), ShouldBeTrue)
+	})
+
+	Convey("Tables of handlers without etags should have no etag column", t, func() {
+		s := schema.Schema{"id": schema.IDField, "f1": schema.Field{Validator: &schema.String{}}}
+		h := NewHandler(nil, "noetag", WithETagMode(ETagNone))
+		So(tableDDL(h, s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `noetag` (`id` VARCHAR(128) PRIMARY KEY,`updated` VARCHAR(128),`created` VARCHAR(128),`f1` TEXT);")
+	})
+
+	Convey("Given a handler without etags", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		s := schema.Schema{
+			"id": schema.IDField,
+			"f1": schema.Field{Validator: &schema.String{}},
+			"f2": schema.Field{Validator: &schema.Integer{}},
+		}
+		h.session.Exec("DROP TABLE noetag;")
+		nh := NewHandler(h.session, "noetag", WithETagMode(ETagNone))
+		So(nh.EnsureTable(context.Background(), s), ShouldBeNil)
+		it, _ := item("foo", 1)
+		So(nh.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+
+		Convey("Items should be read with an empty etag", func() {
+			list, err := nh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].ETag, ShouldEqual, "")
+		})
+
+		Convey("Update and Delete should match on the id only", func() {
+			stale := *it
+			stale.ETag = "stale"
+			updated, _ := item("bar", 2)
+			updated.ID = it.ID
+			updated.Payload["id"] = it.ID
+			So(nh.Update(context.Background(), updated, &stale), ShouldBeNil)
+			So(nh.Delete(context.Background(), &stale), ShouldBeNil)
+			So(nh.Delete(context.Background(), &stale), ShouldEqual, resource.ErrNotFound)
+			So(nh.Update(context.Background(), updated, &stale), ShouldEqual, resource.ErrNotFound)
+		})
+	})
+
+	Convey("Given a stored item", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		Convey("Insert should recompute etags with an ETagFunc", func() {
+			eh := NewHandler(h.session, DB_TABLE, WithETagFunc(MD5ETag))
+			it, _ := item("foo", 1)
+			it.ETag = "untrusted"
+			So(eh.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+			expected, err := MD5ETag(it.Payload)
+			So(err, ShouldBeNil)
+			So(it.ETag, ShouldEqual, expected)
+
+			result, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(result.Items[0].ETag, ShouldEqual, expected)
+		})
+
+		Convey("Delete should skip verification when asked to", func() {
+			So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+			stale := *i1
+			stale.ETag = "stale"
+			So(h.Delete(context.Background(), &stale), ShouldEqual, resource.ErrConflict)
+			sh := NewHandler(h.session, DB_TABLE, WithETagMode(ETagSkipVerify))
+			So(sh.Delete(context.Background(), &stale), ShouldBeNil)
+			So(sh.Delete(context.Background(), &stale), ShouldEqual, resource.ErrNotFound)
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package sqlite3
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ETagCache holds the stored etag of recently written items, keyed by the SQL
+// literal of their id. Implementations must be safe for concurrent use.
+type ETagCache interface {
+	// Get returns the cached etag of an item.
+	Get(id string) (etag string, ok bool)
+	// Set records the etag of an item.
+	Set(id, etag string)
+	// Remove forgets an item.
+	Remove(id string)
+	// Purge forgets all items.
+	Purge()
+}
+
+// WithETagCache makes Update and Delete skip the SELECT reading the stored etag
+// in ETagWeak mode, when the cache holds a stored etag matching the one of the
+// provided item: the write statement then compares the cached etag with the
+// stored one itself, and the row is only read when it matches nothing, so a
+// stale cache (e.g. a row written by another process) can't let a conflicting
+// write through. Exact etags are always compared by the write statement, so
+// the cache is only used in ETagWeak mode, on handlers without a previous
+// version table.
+func WithETagCache(c ETagCache) Option {
+	return func(h *Handler) {
+		h.etagCache = c
+	}
+}
+
+// lruETagCache is an ETagCache keeping the most recently used entries.
+type lruETagCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	id   string
+	etag string
+}
+
+// NewLRUETagCache returns an ETagCache holding at most size items, evicting
+// the least recently used ones.
+func NewLRUETagCache(size int) ETagCache {
+	return &lruETagCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *lruETagCache) Get(id string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).etag, true
+}
+
+func (c *lruETagCache) Set(id, etag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if e, ok := c.entries[id]; ok {
+		e.Value.(*lruEntry).etag = etag
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[id] = c.order.PushFront(&lruEntry{id: id, etag: etag})
+	for c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.entries, last.Value.(*lruEntry).id)
+	}
+}
+
+func (c *lruETagCache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		c.order.Remove(e)
+		delete(c.entries, id)
+	}
+}
+
+func (c *lruETagCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+}
+
+// useETagCache reports whether etag checks may be answered by the cache.
+func (h *Handler) useETagCache() bool {
+	return h.etagCache != nil && h.etagMode == ETagWeak && h.previous == nil
+}
+
+// cachedETag returns the stored etag cached for an item, if it matches the
+// given one.
+func (h *Handler) cachedETag(id interface{}, etag string) (string, bool) {
+	if !h.useETagCache() {
+		return "", false
+	}
+	lit, err := h.idLiteral(id)
+	if err != nil {
+		return "", false
+	}
+	stored, ok := h.etagCache.Get(lit)
+	if !ok || !h.etagsMatch(stored, etag) {
+		return "", false
+	}
+	return stored, true
+}
+
+// cacheETag records the stored etag of a written item, or forgets the item
+// when etag is empty.
+func (h *Handler) cacheETag(id interface{}, etag string) {
+	if !h.useETagCache() {
+		return
+	}
+	lit, err := h.idLiteral(id)
+	if err != nil {
+		return
+	}
+	if etag == "" {
+		h.etagCache.Remove(lit)
+		return
+	}
+	h.etagCache.Set(lit, etag)
+}
@@ -0,0 +1,98 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestETagCache(t *testing.T) {
+	Convey("The LRU cache should evict the least recently used items", t, func() {
+		c := NewLRUETagCache(2)
+		c.Set("a", "1")
+		c.Set("b", "2")
+		c.Get("a")
+		c.Set("c", "3")
+		_, ok := c.Get("b")
+		So(ok, ShouldBeFalse)
+		etag, ok := c.Get("a")
+		So(ok, ShouldBeTrue)
+		So(etag, ShouldEqual, "1")
+		c.Remove("a")
+		_, ok = c.Get("a")
+		So(ok, ShouldBeFalse)
+		c.Purge()
+		_, ok = c.Get("c")
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Given a handler with an etag cache", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		c := NewLRUETagCache(10)
+		ch := NewHandler(h.session, DB_TABLE, WithETagMode(ETagWeak), WithETagCache(c))
+		it, _ := item("foo", 1)
+		So(ch.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+		lit, _ := ch.idLiteral(it.ID)
+		weak := &resource.Item{ID: it.ID, ETag: `W/"` + it.ETag + `"`, Payload: it.Payload}
+
+		Convey("Insert and Update should cache the written etag", func() {
+			etag, ok := c.Get(lit)
+			So(ok, ShouldBeTrue)
+			So(etag, ShouldEqual, it.ETag)
+
+			updated, _ := resource.NewItem(map[string]interface{}{"id": it.ID, "created": it.Payload["created"], "f1": "new", "f2": 1})
+			So(ch.Update(context.Background(), updated, weak), ShouldBeNil)
+			etag, _ = c.Get(lit)
+			So(etag, ShouldEqual, updated.ETag)
+		})
+
+		Convey("A stale cache should not let a conflicting write through", func() {
+			// another writer changes the item behind the cache
+			other, _ := resource.NewItem(map[string]interface{}{"id": it.ID, "created": it.Payload["created"], "f1": "other", "f2": 1})
+			So(h.Update(context.Background(), other, it), ShouldBeNil)
+
+			updated, _ := resource.NewItem(map[string]interface{}{"id": it.ID, "created": it.Payload["created"], "f1": "new", "f2": 1})
+			So(ch.Update(context.Background(), updated, weak), ShouldEqual, resource.ErrConflict)
+			etag, ok := c.Get(lit)
+			So(ok, ShouldBeTrue)
+			So(etag, ShouldEqual, other.ETag)
+			So(ch.Delete(context.Background(), weak), ShouldEqual, resource.ErrConflict)
+		})
+
+		Convey("A stale cache should not fail a matching write", func() {
+			// the cached etag matches the given one, but isn't the stored one
+			c.Set(lit, weak.ETag)
+			updated, _ := resource.NewItem(map[string]interface{}{"id": it.ID, "created": it.Payload["created"], "f1": "new", "f2": 1})
+			So(ch.Update(context.Background(), updated, weak), ShouldBeNil)
+			c.Set(lit, `W/"`+updated.ETag+`"`)
+			So(ch.Delete(context.Background(), updated), ShouldBeNil)
+		})
+
+		Convey("Delete should forget the item", func() {
+			So(ch.Delete(context.Background(), weak), ShouldBeNil)
+			_, ok := c.Get(lit)
+			So(ok, ShouldBeFalse)
+			So(ch.Delete(context.Background(), weak), ShouldEqual, resource.ErrNotFound)
+		})
+
+		Convey("A cached item removed by another writer should not be found", func() {
+			So(h.Delete(context.Background(), it), ShouldBeNil)
+			So(ch.Delete(context.Background(), weak), ShouldEqual, resource.ErrNotFound)
+		})
+
+		Convey("The cache should not be used with exact etags", func() {
+			c.Purge()
+			eh := NewHandler(h.session, DB_TABLE, WithETagCache(c))
+			So(eh.Delete(context.Background(), it), ShouldBeNil)
+			_, ok := c.Get(lit)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
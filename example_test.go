@@ -1,10 +1,13 @@
+//go:build !modernc
+// +build !modernc
+
 package sqlite3_test
 
 import (
-    "os"
 	"log"
 	"net/http"
-	"database/sql"
+
+	"golang.org/x/net/context"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/cors"
@@ -15,15 +18,9 @@ import (
 )
 
 const (
-	DB_DRIVER   = "sqlite3"
-	DB_FILE     = "./example.db"
-	USER_TABLE    = "users"
+	DB_FILE    = "./example.db"
+	USER_TABLE = "users"
 	POST_TABLE = "posts"
-    ENABLE_FK = "PRAGMA foreign_keys = ON;"
-	USERS_UP_DDL   = "CREATE TABLE `" + USER_TABLE + "` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128), `name` VARCHAR(150));"
-	POSTS_UP_DDL   = "CREATE TABLE `" + POST_TABLE + "` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128), `created` VARCHAR(128), `user` VARCHAR(128) REFERENCES users(id) ON DELETE CASCADE, `public` INTEGER, `title` VARCHAR(150), `body` VARCHAR(100000));"
-	USERS_DN_DDL   = "DROP TABLE `" + USER_TABLE + "`;"
-	POSTS_DN_DDL   = "DROP TABLE `" + POST_TABLE + "`;"
 )
 
 var (
@@ -71,27 +68,27 @@ var (
 	}
 )
 
-// handler returns a new handler with the database and table information,
-// or an error.
-
-
 func Example() {
-    dbDn()
-    // get a database connection and set up the tables.
-	db, err := sql.Open(DB_DRIVER, DB_FILE)
+	// open the database, creating the tables on first run.
+	db, err := sqlite3.Open(context.Background(), DB_FILE, sqlite3.Bootstrap{
+		Pragmas: []sqlite3.Pragma{{Name: "foreign_keys", Value: "ON"}},
+		Resources: []sqlite3.Resource{
+			{Table: USER_TABLE, Schema: user},
+			{Table: POST_TABLE, Schema: post},
+		},
+		Version: 1,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-    dbUp(db)
-    //defer dbDn(db)
 
 	index := resource.NewIndex()
 
-	users := index.Bind("users", resource.New(user, sqlite3.NewHandler(db, USER_TABLE), resource.Conf{
+	users := index.Bind("users", resource.New(user, sqlite3.NewHandler(db, USER_TABLE, sqlite3.WithForeignKeys(true)), resource.Conf{
 		AllowedModes: resource.ReadWrite,
 	}))
 
-	users.Bind("posts", "user", resource.New(post, sqlite3.NewHandler(db, POST_TABLE), resource.Conf{
+	users.Bind("posts", "user", resource.New(post, sqlite3.NewHandler(db, POST_TABLE, sqlite3.WithForeignKeys(true)), resource.Conf{
 		AllowedModes: resource.ReadWrite,
 	}))
 
@@ -107,28 +104,3 @@ func Example() {
 		log.Fatal(err)
 	}
 }
-
-func dbDn() {
-    err := os.Remove(DB_FILE)
-    if err != nil {
-        //log.Warn(err)
-    }
-}
-
-func dbUp(db *sql.DB) {
-    var err error
-    _, err = db.Exec(ENABLE_FK)
-    if err != nil {
-        log.Fatal(err)
-    }
-    _, err = db.Exec(USERS_UP_DDL) 
-    if err != nil {
-        log.Fatal(err)
-    }
-    _, err = db.Exec(POSTS_UP_DDL) 
-    if err != nil {
-        log.Fatal(err)
-    }
-}
-
-
@@ -0,0 +1,132 @@
+package sqlite3
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// ExportFormat is the format of Export.
+type ExportFormat string
+
+const (
+	// ExportCSV writes a header line, then one line per item.
+	ExportCSV ExportFormat = "csv"
+	// ExportJSONLines writes one JSON object per line.
+	ExportJSONLines ExportFormat = "jsonl"
+)
+
+// The fields carrying the etag and updated time of an exported item.
+const (
+	ExportETagField    = "_etag"
+	ExportUpdatedField = "_updated"
+)
+
+// Export writes the items matching the lookup to w, as they are read, in the
+// given format, and returns the number of items written. The lookup is
+// translated as by Find, and all the matching items are exported. Each item
+// is written as its payload, with its etag and updated time in the _etag and
+// _updated fields.
+//
+// The CSV columns are the fields of the first item, sorted with id first:
+// fields the first item doesn't have, as can happen with WithStorageMode
+// (StorageJSON), are left out. Times are written in RFC 3339 and dicts and
+// arrays as JSON.
+func (h *Handler) Export(ctx context.Context, w io.Writer, format ExportFormat, lookup *resource.Lookup) (int, error) {
+	var write func(map[string]interface{}) error
+	var flush func() error
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		var header []string
+		write = func(row map[string]interface{}) error {
+			if header == nil {
+				header = exportHeader(row)
+				if err := cw.Write(header); err != nil {
+					return err
+				}
+			}
+			record := make([]string, len(header))
+			for i, f := range header {
+				v, err := csvValue(row[f])
+				if err != nil {
+					return fmt.Errorf("field %s: %v", f, err)
+				}
+				record[i] = v
+			}
+			return cw.Write(record)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case ExportJSONLines:
+		enc := json.NewEncoder(w)
+		write = func(row map[string]interface{}) error {
+			return enc.Encode(row)
+		}
+		flush = func() error { return nil }
+	default:
+		return 0, fmt.Errorf("sqlite3: invalid export format: %q", format)
+	}
+
+	it, err := h.FindIter(ctx, lookup, 1, -1)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		i := it.Item()
+		row := make(map[string]interface{}, len(i.Payload)+2)
+		for k, v := range i.Payload {
+			row[k] = v
+		}
+		if h.etagMode != ETagNone {
+			row[ExportETagField] = i.ETag
+		}
+		row[ExportUpdatedField] = i.Updated
+		if err = write(row); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err = it.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// exportHeader returns the CSV columns of an exported item.
+func exportHeader(row map[string]interface{}) []string {
+	header := make([]string, 0, len(row))
+	for f := range row {
+		if f != "id" {
+			header = append(header, f)
+		}
+	}
+	sort.Strings(header)
+	return append([]string{"id"}, header...)
+}
+
+// csvValue returns the CSV text of a value: empty for nil, RFC 3339 for
+// times, and JSON for anything but strings.
+func csvValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,67 @@
+package sqlite3
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExport(t *testing.T) {
+	Convey("Given a table with items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		a, _ := item("a,\"quoted\"", 1)
+		b, _ := item("b", 2)
+		So(h.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+		l := resource.NewLookup()
+		l.SetSort("f2", nil)
+
+		Convey("Export should write CSV", func() {
+			var buf bytes.Buffer
+			n, err := h.Export(context.Background(), &buf, ExportCSV, l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			records, err := csv.NewReader(&buf).ReadAll()
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 3)
+			So(records[0], ShouldResemble, []string{"id", "_etag", "_updated", "created", "f1", "f2"})
+			So(records[1][0], ShouldEqual, a.ID)
+			So(records[1][1], ShouldEqual, a.ETag)
+			So(records[1][4], ShouldEqual, "a,\"quoted\"")
+			So(records[1][5], ShouldEqual, "1")
+			So(records[2][0], ShouldEqual, b.ID)
+		})
+
+		Convey("Export should write JSON Lines of the matching items", func() {
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "b"}})
+			var buf bytes.Buffer
+			n, err := h.Export(context.Background(), &buf, ExportJSONLines, l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			So(len(lines), ShouldEqual, 1)
+			var row map[string]interface{}
+			So(json.Unmarshal([]byte(lines[0]), &row), ShouldBeNil)
+			So(row["id"], ShouldEqual, b.ID)
+			So(row["f1"], ShouldEqual, "b")
+			So(row[ExportETagField], ShouldEqual, b.ETag)
+			So(row[ExportUpdatedField], ShouldNotBeEmpty)
+		})
+
+		Convey("Export should reject unknown formats", func() {
+			_, err := h.Export(context.Background(), &bytes.Buffer{}, "xml", l)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
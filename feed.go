@@ -0,0 +1,136 @@
+package sqlite3
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// FeedOrder is the key feeds are paginated by, newest first.
+type FeedOrder string
+
+const (
+	// FeedByID orders feeds by descending id. The ids must sort in creation
+	// order, like time based ids.
+	FeedByID FeedOrder = "id"
+	// FeedByUpdated orders feeds by descending updated time, then id.
+	FeedByUpdated FeedOrder = "updated"
+)
+
+// FeedQuery selects a page of a feed by cursor rather than by page number.
+// Cursors are returned by FeedCursor.
+type FeedQuery struct {
+	// SinceID only selects the items newer than the cursor.
+	SinceID string
+	// MaxID only selects the items older than the cursor. Pass the cursor of
+	// the last item of a page to get the next page.
+	MaxID string
+}
+
+type feedKey struct{}
+
+// WithFeedPagination makes Find paginate the requests carrying a FeedQuery
+// by the order key instead of by offset. Such requests are sorted newest
+// first, their page number and sort are ignored, and the page is read with a
+// range predicate on the key, so deep pages cost as much as the first one when
+// the key is indexed.
+func WithFeedPagination(o FeedOrder) Option {
+	return func(h *Handler) {
+		h.feedOrder = o
+	}
+}
+
+// NewFeedContext returns a copy of ctx carrying the feed query.
+func NewFeedContext(ctx context.Context, q FeedQuery) context.Context {
+	return context.WithValue(ctx, feedKey{}, q)
+}
+
+// FeedFromContext returns the feed query attached to ctx, if any.
+func FeedFromContext(ctx context.Context) (FeedQuery, bool) {
+	q, ok := ctx.Value(feedKey{}).(FeedQuery)
+	return q, ok
+}
+
+// ParseFeedQuery reads a feed query from the since_id and max_id request
+// parameters. ok is false when neither is set.
+func ParseFeedQuery(v url.Values) (q FeedQuery, ok bool) {
+	q = FeedQuery{SinceID: v.Get("since_id"), MaxID: v.Get("max_id")}
+	return q, q.SinceID != "" || q.MaxID != ""
+}
+
+// FeedCursor returns the cursor of an item in the handler's feed order.
+func (h *Handler) FeedCursor(i *resource.Item) string {
+	id := fmt.Sprintf("%v", i.ID)
+	if h.feedOrder == FeedByUpdated {
+		return i.Updated.UTC().Format(time.RFC3339Nano) + "~" + id
+	}
+	return id
+}
+
+// feedBound is a range predicate on the feed key. It is only produced by
+// feedFilter and only understood by translateQuery.
+type feedBound struct {
+	// Op is the comparison operator, < or >.
+	Op string
+	// Key is the SQL literal of the bound, a row value when ordering by
+	// updated.
+	Key string
+}
+
+// Match is required by schema.Expression, in-memory matching is not supported.
+func (e feedBound) Match(payload map[string]interface{}) bool {
+	return false
+}
+
+// feedKeyRef returns the SQL expression of the feed key.
+func (h *Handler) feedKeyRef() string {
+	if h.feedOrder == FeedByUpdated {
+		return "(" + h.column("updated") + ",id)"
+	}
+	return "id"
+}
+
+// feedLiteral returns the SQL literal of a cursor.
+func (h *Handler) feedLiteral(cursor string) (string, error) {
+	if h.feedOrder != FeedByUpdated {
+		return h.idLiteral(cursor)
+	}
+	i := strings.IndexByte(cursor, '~')
+	if i < 0 {
+		return "", fmt.Errorf("sqlite3: invalid feed cursor: %q", cursor)
+	}
+	t, err := time.Parse(time.RFC3339Nano, cursor[:i])
+	if err != nil {
+		return "", fmt.Errorf("sqlite3: invalid feed cursor: %q", cursor)
+	}
+	id, err := h.idLiteral(cursor[i+1:])
+	if err != nil {
+		return "", err
+	}
+	return "(" + h.timeFormat.literal(t) + "," + id + ")", nil
+}
+
+// feedFilter returns the filter and sort of a feed page.
+func (h *Handler) feedFilter(filter schema.Query, q FeedQuery) (schema.Query, []string, error) {
+	f := append(schema.Query{}, filter...)
+	for _, b := range []struct{ op, cursor string }{{">", q.SinceID}, {"<", q.MaxID}} {
+		if b.cursor == "" {
+			continue
+		}
+		lit, err := h.feedLiteral(b.cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		f = append(f, feedBound{Op: b.op, Key: lit})
+	}
+	if h.feedOrder == FeedByUpdated {
+		return f, []string{"-updated", "-id"}, nil
+	}
+	return f, []string{"-id"}, nil
+}
@@ -0,0 +1,90 @@
+package sqlite3
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFeed(t *testing.T) {
+	Convey("Feed queries should be read from the request parameters", t, func() {
+		q, ok := ParseFeedQuery(url.Values{"since_id": {"a"}, "max_id": {"b"}})
+		So(ok, ShouldBeTrue)
+		So(q, ShouldResemble, FeedQuery{SinceID: "a", MaxID: "b"})
+		_, ok = ParseFeedQuery(url.Values{"page": {"2"}})
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Given a feed of items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		base := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		items := []*resource.Item{}
+		for i, id := range []string{"a", "b", "c", "d", "e"} {
+			it, _ := item(id, i)
+			it.ID = id
+			it.Payload["id"] = id
+			// updated runs in the opposite order of the ids
+			it.Updated = base.Add(-time.Duration(i) * time.Second)
+			items = append(items, it)
+		}
+		So(h.Insert(context.Background(), items), ShouldBeNil)
+		ids := func(l *resource.ItemList) []interface{} {
+			out := []interface{}{}
+			for _, i := range l.Items {
+				out = append(out, i.ID)
+			}
+			return out
+		}
+
+		Convey("Pages should follow the ids, newest first", func() {
+			fh := NewHandler(h.session, DB_TABLE, WithFeedPagination(FeedByID))
+			ctx := NewFeedContext(context.Background(), FeedQuery{MaxID: "e"})
+			list, err := fh.Find(ctx, resource.NewLookup(), 3, 2)
+			So(err, ShouldBeNil)
+			So(ids(list), ShouldResemble, []interface{}{"d", "c"})
+
+			ctx = NewFeedContext(context.Background(), FeedQuery{MaxID: fh.FeedCursor(list.Items[1])})
+			list, err = fh.Find(ctx, resource.NewLookup(), 1, 2)
+			So(err, ShouldBeNil)
+			So(ids(list), ShouldResemble, []interface{}{"b", "a"})
+
+			ctx = NewFeedContext(context.Background(), FeedQuery{SinceID: "c"})
+			list, err = fh.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(ids(list), ShouldResemble, []interface{}{"e", "d"})
+		})
+
+		Convey("Pages should follow the updated times, newest first", func() {
+			fh := NewHandler(h.session, DB_TABLE, WithFeedPagination(FeedByUpdated))
+			ctx := NewFeedContext(context.Background(), FeedQuery{})
+			list, err := fh.Find(ctx, resource.NewLookup(), 1, 2)
+			So(err, ShouldBeNil)
+			So(ids(list), ShouldResemble, []interface{}{"a", "b"})
+
+			ctx = NewFeedContext(context.Background(), FeedQuery{MaxID: fh.FeedCursor(list.Items[1])})
+			list, err = fh.Find(ctx, resource.NewLookup(), 1, 2)
+			So(err, ShouldBeNil)
+			So(ids(list), ShouldResemble, []interface{}{"c", "d"})
+
+			ctx = NewFeedContext(context.Background(), FeedQuery{MaxID: "yesterday"})
+			_, err = fh.Find(ctx, resource.NewLookup(), 1, 2)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Requests without a feed query should be paginated by offset", func() {
+			fh := NewHandler(h.session, DB_TABLE, WithFeedPagination(FeedByID))
+			list, err := fh.Find(context.Background(), resource.NewLookup(), 2, 2)
+			So(err, ShouldBeNil)
+			So(ids(list), ShouldResemble, []interface{}{"c", "d"})
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package sqlite3
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+)
+
+// WithSchema sets the schema of the resource served by the handler. Filters,
+// sorts and projection hints on fields missing from it are then rejected with
+// a 422 rest.Error, before any statement is built, instead of being written
+// into SQL. The id, etag, updated and created fields are always known, as is
+// SearchField with full-text search. Dotted names are checked on their first
+// element.
+func WithSchema(s schema.Schema) Option {
+	return func(h *Handler) {
+		h.schema = s
+	}
+}
+
+// checkField returns an error if the handler has a schema and the field is
+// missing from it. usage names the use of the field in the error.
+func (h *Handler) checkField(field, usage string) error {
+	if h.schema == nil {
+		return nil
+	}
+	name := field
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if _, found := h.schema[name]; found {
+		return nil
+	}
+	switch {
+	case name == "id" || name == "created" || isMetaColumn(name):
+		return nil
+	case name == SearchField && len(h.ftsFields) > 0:
+		return nil
+	case h.computed[field].fn != "":
+		return nil
+	}
+	return &rest.Error{
+		Code:    http.StatusUnprocessableEntity,
+		Message: fmt.Sprintf("Unknown %s field", usage),
+		Issues:  map[string][]interface{}{field: {"unknown field"}},
+	}
+}
+
+// checkExpressionField checks the field of a filter expression with
+// checkField.
+func (h *Handler) checkExpressionField(exp schema.Expression) error {
+	field, _, ok := filterField(exp)
+	if t, spilled := exp.(inTable); spilled {
+		field, ok = t.Field, true
+	}
+	if !ok {
+		return nil
+	}
+	return h.checkField(field, "filter")
+}
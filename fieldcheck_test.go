@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaFields(t *testing.T) {
+	s := schema.Schema{"id": schema.IDField, "f1": schema.Field{}, "f2": schema.Field{}}
+
+	Convey("Filters on fields of the schema should be translated", t, func() {
+		q, err := callGetQuery(schema.Query{schema.Or{
+			schema.Equal{Field: "f1", Value: "foo"},
+			schema.GreaterThan{Field: "updated", Value: 1},
+			schema.Equal{Field: "f2.sub", Value: 1},
+		}}, WithSchema(s))
+		So(err, ShouldBeNil)
+		So(q, ShouldNotBeEmpty)
+	})
+
+	Convey("Filters on unknown fields should be rejected", t, func() {
+		_, err := callGetQuery(schema.Query{schema.And{
+			schema.Equal{Field: "f1", Value: "foo"},
+			schema.Equal{Field: "f3", Value: "foo"},
+		}}, WithSchema(s))
+		So(err, ShouldNotBeNil)
+		e, ok := err.(*rest.Error)
+		So(ok, ShouldBeTrue)
+		So(e.Code, ShouldEqual, http.StatusUnprocessableEntity)
+		So(e.Issues, ShouldContainKey, "f3")
+
+		_, err = callGetQuery(schema.Query{schema.Equal{Field: "f3", Value: "foo"}})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Sorts on unknown fields should be rejected", t, func() {
+		_, err := callGetSort("-f3", schema.Schema{"f3": schema.Field{Sortable: true}}, WithSchema(s))
+		So(err, ShouldHaveSameTypeAs, &rest.Error{})
+		_, err = callGetSort("-f1", schema.Schema{"f1": schema.Field{Sortable: true}}, WithSchema(s))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Projections on unknown fields should be rejected", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		sh := NewHandler(h.session, DB_TABLE, WithSchema(s))
+		ctx := NewHintsContext(context.Background(), Hints{Fields: []string{"f1", "nope"}})
+		_, err = sh.Find(ctx, resource.NewLookup(), 1, 10)
+		So(err, ShouldHaveSameTypeAs, &rest.Error{})
+	})
+}
@@ -0,0 +1,85 @@
+package sqlite3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// WithCaseFolding makes Equal and NotEqual filters on the given string fields
+// case-insensitive, and keeps them indexed, along with Wildcard filters. Their
+// values are compared lowercased against an index on the lowercased field
+// created by EnsureCaseFolding, patterns with GLOB instead of LIKE, so prefix
+// searches like {"name": "jo*"} are range scans of the index instead of
+// applying the comparison to every row. The index is
+// on the lower() expression rather than a generated column, so the table
+// keeps the columns of the schema. Like SQLite's lower() and LIKE, only ASCII
+// letters are folded.
+func WithCaseFolding(fields ...string) Option {
+	return func(h *Handler) {
+		h.foldFields = map[string]bool{}
+		for _, f := range fields {
+			h.foldFields[f] = true
+		}
+	}
+}
+
+// foldIndex returns the name of the case folding index of a field.
+func foldIndex(h *Handler, field string) string {
+	return h.tableName + "_" + strings.Replace(field, ".", "_", -1) + "_fold"
+}
+
+// EnsureCaseFolding creates the indexes of the case folded fields, if they
+// don't exist yet.
+func (h *Handler) EnsureCaseFolding(ctx context.Context) error {
+	for _, f := range sortedFields(h.foldFields) {
+		for _, part := range strings.Split(f, ".") {
+			if !identRe.MatchString(part) {
+				return fmt.Errorf("sqlite3: invalid case folded field: %q", f)
+			}
+		}
+		s := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(lower(%s));", foldIndex(h, f), h.tableName, h.fieldRef(f))
+		if _, err := h.session.ExecContext(ctx, s); err != nil {
+			log.WithFields(log.Fields{
+				"table": h.tableName,
+				"field": f,
+				"error": err,
+			}).Warn("Error creating case folding index.")
+			return ctxErr(ctx, err)
+		}
+	}
+	return nil
+}
+
+// sortedFields returns the fields of a set in name order.
+func sortedFields(set map[string]bool) []string {
+	fields := make([]string, 0, len(set))
+	for f := range set {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// foldCase lowercases the ASCII letters of a string, like SQLite's lower().
+func foldCase(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, v)
+}
+
+// foldPattern returns the GLOB pattern matching the lowercased values equal
+// to a filter value, where * is a wildcard.
+func foldPattern(v string) string {
+	v = foldCase(v)
+	// [ and ? are GLOB metacharacters, matched literally as character classes
+	v = strings.Replace(v, "[", "[[]", -1)
+	return strings.Replace(v, "?", "[?]", -1)
+}
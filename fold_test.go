@@ -0,0 +1,70 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCaseFolding(t *testing.T) {
+	Convey("Filters on case folded fields should compare lowercased values", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Fo[o]?_*"}}, WithCaseFolding("f1"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "lower(f1) = 'fo[o]?_*'")
+
+		s, err = callGetQuery(schema.Query{Wildcard{Field: "f1", Value: "Fo[o]?_*"}}, WithCaseFolding("f1"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "lower(f1) GLOB 'fo[[]o][?]_*'")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "Fo[o]?_*"}}, WithCaseFolding("f1"), WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "lower(f1) GLOB 'fo[[]o][?]_*'")
+
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "Foo"}}, WithCaseFolding("f1"), WithNullMatching(true))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(lower(f1) <> 'foo' OR f1 IS NULL)")
+
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "Foo"}}, WithCaseFolding("f1"), WithNullMatching(true), WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(lower(f1) NOT GLOB 'foo' OR f1 IS NULL)")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f2", Value: "Foo"}}, WithCaseFolding("f1"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f2 = 'Foo'")
+	})
+
+	Convey("Given a handler with a case folded field", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		fh := NewHandler(h.session, DB_TABLE, WithCaseFolding("f1"))
+		So(fh.EnsureCaseFolding(context.Background()), ShouldBeNil)
+		So(fh.EnsureCaseFolding(context.Background()), ShouldBeNil)
+		i1, _ := item("FooBar", 1)
+		i2, _ := item("foxy", 2)
+		So(fh.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+
+		Convey("Prefix searches should ignore case", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{Wildcard{Field: "f1", Value: "fOO*"}})
+			list, err := fh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].ID, ShouldEqual, i1.ID)
+		})
+
+		Convey("Prefix searches should use the index", func() {
+			var id, parent, notused int
+			var detail string
+			err := h.session.QueryRow("EXPLAIN QUERY PLAN SELECT * FROM "+DB_TABLE+" WHERE lower(f1) GLOB 'foo*';").Scan(&id, &parent, &notused, &detail)
+			So(err, ShouldBeNil)
+			So(detail, ShouldContainSubstring, foldIndex(fh, "f1"))
+		})
+	})
+}
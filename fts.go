@@ -0,0 +1,146 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// SearchField is the pseudo field searched by full-text search: on handlers
+// with WithFullTextSearch, an Equal filter on it (e.g. {"_search": "sqlite
+// AND go"}) is translated like a TextSearch expression, so REST clients can
+// search once the resource schema declares it as a filterable string field.
+const SearchField = "_search"
+
+// TextSearch is a filter expression matching the items whose full-text
+// indexed fields match an FTS5 query.
+type TextSearch struct {
+	Query string
+}
+
+// Match is required by schema.Expression, in-memory matching is not supported.
+func (e TextSearch) Match(payload map[string]interface{}) bool {
+	return false
+}
+
+// WithFullTextSearch indexes the given text fields in an FTS5 table, created
+// and kept up to date by EnsureFullTextIndex, and enables TextSearch filters.
+// Finds with a text search and no explicit sort return the best matches
+// first. The fields must be stored in their own column, and the SQLite
+// library must be built with FTS5 (the sqlite_fts5 build tag of go-sqlite3).
+func WithFullTextSearch(fields ...string) Option {
+	return func(h *Handler) {
+		h.ftsFields = fields
+	}
+}
+
+// ftsTable returns the name of the FTS5 table of the handler.
+func ftsTable(h *Handler) string {
+	return h.tableName + "_fts"
+}
+
+// EnsureFullTextIndex creates the FTS5 table indexing the full-text search
+// fields, with triggers keeping it in sync with every change to the handler's
+// table, and indexes the existing rows. It does nothing if the FTS5 table
+// already exists.
+func (h *Handler) EnsureFullTextIndex(ctx context.Context) error {
+	stmts, err := getFullTextDDL(h)
+	if err != nil {
+		return err
+	}
+	var exists int
+	err = h.session.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;", ftsTable(h)).Scan(&exists)
+	if err != nil || exists > 0 {
+		return ctxErr(ctx, err)
+	}
+	txPtr, err := h.session.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting full-text index transaction.")
+		return ctxErr(ctx, err)
+	}
+	for _, s := range stmts {
+		if _, err = txPtr.ExecContext(ctx, s); err != nil {
+			txPtr.Rollback()
+			log.WithFields(log.Fields{
+				"table": h.tableName,
+				"error": err,
+			}).Warn("Error creating full-text index.")
+			return ctxErr(ctx, err)
+		}
+	}
+	return ctxErr(ctx, txPtr.Commit())
+}
+
+// getFullTextDDL returns the statements creating the FTS5 table of the
+// handler, its triggers, and indexing the existing rows.
+func getFullTextDDL(h *Handler) ([]string, error) {
+	if len(h.ftsFields) == 0 {
+		return nil, fmt.Errorf("sqlite3: no full-text search fields")
+	}
+	for _, f := range h.ftsFields {
+		if !identRe.MatchString(f) || !h.hasColumn(f) {
+			return nil, fmt.Errorf("sqlite3: invalid full-text search field: %q", f)
+		}
+	}
+	t, fts := h.tableName, ftsTable(h)
+	cols := strings.Join(h.ftsFields, ",")
+	values := func(prefix string) string {
+		return prefix + strings.Join(h.ftsFields, ","+prefix)
+	}
+	return []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content='%s');", fts, cols, t),
+		fmt.Sprintf("CREATE TRIGGER %s_ai AFTER INSERT ON %s BEGIN INSERT INTO %s(rowid,%s) VALUES(new.rowid,%s); END;",
+			fts, t, fts, cols, values("new.")),
+		fmt.Sprintf("CREATE TRIGGER %s_ad AFTER DELETE ON %s BEGIN INSERT INTO %s(%s,rowid,%s) VALUES('delete',old.rowid,%s); END;",
+			fts, t, fts, fts, cols, values("old.")),
+		fmt.Sprintf("CREATE TRIGGER %s_au AFTER UPDATE ON %s BEGIN INSERT INTO %s(%s,rowid,%s) VALUES('delete',old.rowid,%s); INSERT INTO %s(rowid,%s) VALUES(new.rowid,%s); END;",
+			fts, t, fts, fts, cols, values("old."), fts, cols, values("new.")),
+		fmt.Sprintf("INSERT INTO %s(%s) VALUES('rebuild');", fts, fts),
+	}, nil
+}
+
+// textSearch returns the FTS5 query of a text search expression.
+func (h *Handler) textSearch(exp schema.Expression) (string, bool) {
+	if len(h.ftsFields) == 0 {
+		return "", false
+	}
+	switch t := exp.(type) {
+	case TextSearch:
+		return t.Query, true
+	case schema.Equal:
+		if q, ok := t.Value.(string); ok && t.Field == SearchField {
+			return q, true
+		}
+	}
+	return "", false
+}
+
+// writeTextSearch writes the SQL matching the rows of a text search.
+func writeTextSearch(h *Handler, b *strings.Builder, q string) error {
+	if h.previous != nil {
+		return fmt.Errorf("sqlite3: full-text search is not supported with a previous version table")
+	}
+	v, err := valueToString(q)
+	if err != nil {
+		return err
+	}
+	b.WriteString(fmt.Sprintf("rowid IN (SELECT rowid FROM %s WHERE %s MATCH %s)", ftsTable(h), ftsTable(h), v))
+	return nil
+}
+
+// searchRank returns the ORDER BY expression ranking the rows by relevance
+// for the first top level text search of the filter, if any.
+func searchRank(h *Handler, filter schema.Query) (string, bool) {
+	for _, exp := range filter {
+		if q, ok := h.textSearch(exp); ok && h.previous == nil {
+			v, _ := valueToString(q)
+			return fmt.Sprintf("(SELECT rank FROM %s WHERE %s MATCH %s AND rowid = %s.rowid)",
+				ftsTable(h), ftsTable(h), v, h.tableName), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,56 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFullTextIndex(t *testing.T) {
+	Convey("Given a full-text indexed table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE IF EXISTS " + DB_TABLE + "_fts;")
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		a, _ := item("sqlite storage for go", 1)
+		b, _ := item("go go go", 2)
+		So(h.Insert(context.Background(), []*resource.Item{a}), ShouldBeNil)
+		fh := NewHandler(h.session, DB_TABLE, WithFullTextSearch("f1"))
+		So(fh.EnsureFullTextIndex(context.Background()), ShouldBeNil)
+		So(fh.EnsureFullTextIndex(context.Background()), ShouldBeNil)
+		So(fh.Insert(context.Background(), []*resource.Item{b}), ShouldBeNil)
+
+		search := func(q string) []*resource.Item {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{TextSearch{Query: q}})
+			list, err := fh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			return list.Items
+		}
+
+		Convey("Find should return the matches, best first", func() {
+			items := search("go")
+			So(len(items), ShouldEqual, 2)
+			So(items[0].ID, ShouldEqual, b.ID)
+			So(len(search("sqlite")), ShouldEqual, 1)
+		})
+
+		Convey("The index should follow updates and deletes", func() {
+			updated, _ := resource.NewItem(map[string]interface{}{"id": a.ID, "created": a.Payload["created"], "f1": "rest layer", "f2": 1})
+			So(fh.Update(context.Background(), updated, a), ShouldBeNil)
+			So(len(search("sqlite")), ShouldEqual, 0)
+			So(len(search("rest")), ShouldEqual, 1)
+			So(fh.Delete(context.Background(), b), ShouldBeNil)
+			So(len(search("go")), ShouldEqual, 0)
+		})
+	})
+}
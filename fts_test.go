@@ -0,0 +1,47 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	Convey("Text searches should be translated to MATCH", t, func() {
+		s, err := callGetQuery(schema.Query{TextSearch{Query: "sqlite"}}, WithFullTextSearch("f1"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "rowid IN (SELECT rowid FROM testtable_fts WHERE testtable_fts MATCH 'sqlite')")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: SearchField, Value: "it's"}}, WithFullTextSearch("f1"))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "rowid IN (SELECT rowid FROM testtable_fts WHERE testtable_fts MATCH 'it''s')")
+
+		_, err = callGetQuery(schema.Query{TextSearch{Query: "sqlite"}})
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+	})
+
+	Convey("Searches without a sort should be ranked", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithFullTextSearch("f1"))
+		s, err := buildSelect(h, schema.Query{TextSearch{Query: "go"}}, nil, 1, 10, Hints{})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "SELECT * FROM testtable WHERE rowid IN (SELECT rowid FROM testtable_fts WHERE testtable_fts MATCH 'go')"+
+			" ORDER BY (SELECT rank FROM testtable_fts WHERE testtable_fts MATCH 'go' AND rowid = testtable.rowid) LIMIT 10 OFFSET 0;")
+	})
+
+	Convey("The FTS5 table should be kept in sync by triggers", t, func() {
+		stmts, err := getFullTextDDL(NewHandler(nil, "t", WithFullTextSearch("a", "b")))
+		So(err, ShouldBeNil)
+		So(stmts, ShouldResemble, []string{
+			"CREATE VIRTUAL TABLE t_fts USING fts5(a,b, content='t');",
+			"CREATE TRIGGER t_fts_ai AFTER INSERT ON t BEGIN INSERT INTO t_fts(rowid,a,b) VALUES(new.rowid,new.a,new.b); END;",
+			"CREATE TRIGGER t_fts_ad AFTER DELETE ON t BEGIN INSERT INTO t_fts(t_fts,rowid,a,b) VALUES('delete',old.rowid,old.a,old.b); END;",
+			"CREATE TRIGGER t_fts_au AFTER UPDATE ON t BEGIN INSERT INTO t_fts(t_fts,rowid,a,b) VALUES('delete',old.rowid,old.a,old.b); INSERT INTO t_fts(rowid,a,b) VALUES(new.rowid,new.a,new.b); END;",
+			"INSERT INTO t_fts(t_fts) VALUES('rebuild');",
+		})
+
+		_, err = getFullTextDDL(NewHandler(nil, "t", WithFullTextSearch("a b")))
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,82 @@
+package sqlite3
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Hints adjust the statements built for a single request, for surgical
+// performance fixes on specific endpoints. Attach them to the request context
+// with NewHintsContext.
+type Hints struct {
+	// IndexedBy forces the use of the named index (INDEXED BY).
+	IndexedBy string
+	// NotIndexed prevents the use of any index (NOT INDEXED).
+	NotIndexed bool
+	// Limit overrides the page size of Find when positive. The page offset
+	// is still computed from the requested page size.
+	Limit int
+	// Fields restricts the columns read by Find to the named payload fields,
+	// so large columns the response doesn't need aren't fetched. The id,
+	// etag (unless the handler runs without etags), updated and created
+	// columns are always read. Empty reads all columns.
+	Fields []string
+}
+
+type hintsKey struct{}
+
+// identRe matches the identifiers accepted in hints.
+var identRe = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// NewHintsContext returns a copy of ctx carrying the query hints.
+func NewHintsContext(ctx context.Context, hints Hints) context.Context {
+	return context.WithValue(ctx, hintsKey{}, hints)
+}
+
+// HintsFromContext returns the query hints attached to ctx, if any.
+func HintsFromContext(ctx context.Context) Hints {
+	hints, _ := ctx.Value(hintsKey{}).(Hints)
+	return hints
+}
+
+// tableRef returns the table reference of a statement with the index hints
+// applied.
+func (hints Hints) tableRef(table string) (string, error) {
+	switch {
+	case hints.IndexedBy != "":
+		if !identRe.MatchString(hints.IndexedBy) {
+			return "", fmt.Errorf("sqlite3: invalid index name in hints: %q", hints.IndexedBy)
+		}
+		return table + " INDEXED BY " + hints.IndexedBy, nil
+	case hints.NotIndexed:
+		return table + " NOT INDEXED", nil
+	}
+	return table, nil
+}
+
+// columns returns the column list of a select statement with the projection
+// hint applied, starting with the given meta columns.
+func (hints Hints) columns(meta []string, column func(string) string) (string, error) {
+	if len(hints.Fields) == 0 {
+		return "*", nil
+	}
+	cols := make([]string, 0, len(meta)+len(hints.Fields))
+	seen := map[string]bool{}
+	for _, f := range meta {
+		seen[f] = true
+		cols = append(cols, column(f))
+	}
+	for _, f := range hints.Fields {
+		if !identRe.MatchString(f) {
+			return "", fmt.Errorf("sqlite3: invalid field name in hints: %q", f)
+		}
+		if !seen[f] {
+			seen[f] = true
+			cols = append(cols, column(f))
+		}
+	}
+	return strings.Join(cols, ","), nil
+}
@@ -0,0 +1,69 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHints(t *testing.T) {
+	Convey("Hints should round trip through the context", t, func() {
+		So(HintsFromContext(context.Background()), ShouldResemble, Hints{})
+		ctx := NewHintsContext(context.Background(), Hints{NotIndexed: true})
+		So(HintsFromContext(ctx), ShouldResemble, Hints{NotIndexed: true})
+	})
+
+	Convey("Statements should honor hints", t, func() {
+		h := NewHandler(nil, DB_TABLE)
+		q := schema.Query{schema.Equal{Field: "f2", Value: 1}}
+
+		s, err := buildSelect(h, q, nil, 2, 10, Hints{IndexedBy: "idx_f2"})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "SELECT * FROM "+DB_TABLE+" INDEXED BY idx_f2 WHERE f2 IS 1 LIMIT 10 OFFSET 10;")
+
+		s, err = buildSelect(h, q, nil, 2, 10, Hints{NotIndexed: true, Limit: 5})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "SELECT * FROM "+DB_TABLE+" NOT INDEXED WHERE f2 IS 1 LIMIT 5 OFFSET 10;")
+
+		s, err = buildDelete(h, q, Hints{IndexedBy: "idx_f2"})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "DELETE FROM "+DB_TABLE+" INDEXED BY idx_f2 WHERE f2 IS 1;")
+
+		_, err = buildSelect(h, q, nil, 1, 10, Hints{IndexedBy: "idx; DROP TABLE x"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Select statements should only read the projected fields", t, func() {
+		h := NewHandler(nil, DB_TABLE)
+		q := schema.Query{schema.Equal{Field: "f2", Value: 1}}
+
+		s, err := buildSelect(h, q, nil, 1, 10, Hints{Fields: []string{"f1", "id", "f1"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "SELECT id,etag,updated,created,f1 FROM "+DB_TABLE+" WHERE f2 IS 1 LIMIT 10 OFFSET 0;")
+
+		_, err = buildSelect(h, q, nil, 1, 10, Hints{Fields: []string{"f1,(SELECT 1)"}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Find should return the projected fields", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		ctx := NewHintsContext(context.Background(), Hints{Fields: []string{"f1"}})
+		result, err := h.Find(ctx, resource.NewLookup(), 1, 10)
+		So(err, ShouldBeNil)
+		So(len(result.Items), ShouldEqual, 1)
+		So(result.Items[0].ID, ShouldEqual, i1.ID)
+		So(result.Items[0].Payload["f1"], ShouldEqual, "foo")
+		_, found := result.Items[0].Payload["f2"]
+		So(found, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,210 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+const (
+	// HistoryVersionColumn is the column of the history table numbering the
+	// versions of an item, from 1.
+	HistoryVersionColumn = "_version"
+	// HistoryArchivedColumn is the column of the history table holding the
+	// time a version was replaced or deleted.
+	HistoryArchivedColumn = "_archived"
+)
+
+// Version is a past version of an item, as recorded in the history table.
+type Version struct {
+	// Number is the version number, from 1 for the oldest version.
+	Number int
+	// Archived is the time the version was replaced or deleted.
+	Archived time.Time
+	// Item is the item as it was stored, with its etag and updated time.
+	Item *resource.Item
+}
+
+// WithHistory makes Update, Delete and Clear copy the rows they replace or
+// remove into the <table>_history table, created with CreateHistoryTable,
+// so past versions of an item can be listed with History and brought back
+// with Restore.
+func WithHistory() Option {
+	return func(h *Handler) {
+		h.history = true
+	}
+}
+
+// historyTable returns the name of the handler's history table.
+func historyTable(h *Handler) string {
+	return h.tableName + "_history"
+}
+
+// CreateHistoryTable creates the handler's history table if it does not exist
+// yet, with the columns of the handler's table followed by the version number
+// and archive time. The table must be created again, or altered the same way,
+// when the columns of the handler's table change.
+func (h *Handler) CreateHistoryTable(ctx context.Context) error {
+	t := historyTable(h)
+	for _, s := range []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` AS SELECT *, 0 AS `%s`, '' AS `%s` FROM `%s` WHERE 0;",
+			t, HistoryVersionColumn, HistoryArchivedColumn, h.tableName),
+		fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS `%s_version` ON `%s` (`id`,`%s`);", t, t, HistoryVersionColumn),
+	} {
+		if _, err := h.session.ExecContext(ctx, s); err != nil {
+			log.WithFields(log.Fields{
+				"table": t,
+				"error": err,
+			}).Warn("Error creating history table.")
+			return err
+		}
+	}
+	return nil
+}
+
+// getHistoryInsert returns a statement copying the rows matching the WHERE
+// clause into the history table, each as the next version of its item.
+func getHistoryInsert(h *Handler, where string, archived time.Time) string {
+	t := historyTable(h)
+	return fmt.Sprintf("INSERT INTO %s SELECT %s.*, (SELECT COALESCE(MAX(%s),0)+1 FROM %s WHERE %s.id = %s.id), %s FROM %s WHERE %s;",
+		t, h.tableName, HistoryVersionColumn, t, t, h.tableName, h.timeFormat.literal(archived), h.tableName, where)
+}
+
+// archive copies the rows matching the WHERE clause into the history table,
+// if the handler keeps one.
+func (h *Handler) archive(ctx context.Context, db execer, where string) error {
+	if !h.history {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, h.annotate(ctx, getHistoryInsert(h, where, h.clock.Now()))); err != nil {
+		log.WithField("error", err).Warn("Error archiving item versions.")
+		return err
+	}
+	return nil
+}
+
+// archiveItem archives the stored version of an item about to be replaced or
+// removed. If the write matches the etag, so does the archive, so that a
+// version is only archived when the write happens.
+func (h *Handler) archiveItem(ctx context.Context, db execer, i *resource.Item, matchETag bool) error {
+	if !h.history {
+		return nil
+	}
+	lit, err := h.idLiteral(i.ID)
+	if err != nil {
+		return resource.ErrNotFound
+	}
+	where := "id = " + lit
+	if matchETag && h.verifiesETags() {
+		etag, _ := valueToString(i.ETag)
+		where += " AND " + h.column("etag") + " = " + etag
+	}
+	return h.archive(ctx, db, h.liveWhere(where))
+}
+
+// History returns the past versions of an item, oldest first.
+func (h *Handler) History(ctx context.Context, id interface{}) ([]Version, error) {
+	lit, err := h.idLiteral(id)
+	if err != nil {
+		return nil, resource.ErrNotFound
+	}
+	q := fmt.Sprintf("SELECT * FROM %s WHERE id = %s ORDER BY %s;", historyTable(h), lit, HistoryVersionColumn)
+	list, err := runSelect(ctx, h, h.readConn(), q, 1)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]Version, 0, len(list.Items))
+	for _, item := range list.Items {
+		v := Version{Item: item}
+		switch n := item.Payload[HistoryVersionColumn].(type) {
+		case int64:
+			v.Number = int(n)
+		}
+		if archived := item.Payload[HistoryArchivedColumn]; archived != nil {
+			if v.Archived, err = h.timeFormat.parse(archived); err != nil {
+				log.WithField("error", err).Warn("Error parsing archive time.")
+				return nil, err
+			}
+		}
+		delete(item.Payload, HistoryVersionColumn)
+		delete(item.Payload, HistoryArchivedColumn)
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// Restore replaces an item with one of its past versions, or stores it again
+// if it was deleted. The version keeps its etag, and gets a new updated time.
+// The replaced item is archived as a new version first. Restore returns
+// resource.ErrNotFound if the item has no such version.
+func (h *Handler) Restore(ctx context.Context, id interface{}, version int) error {
+	lit, err := h.idLiteral(id)
+	if err != nil {
+		return resource.ErrNotFound
+	}
+	if err = h.ops.begin(); err != nil {
+		return err
+	}
+	defer h.ops.end()
+
+	cols, err := columnTypes(ctx, h.conn(), h.tableName)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cols))
+	for _, c := range sortedColumns(cols) {
+		names = append(names, "`"+c+"`")
+	}
+	list := strings.Join(names, ",")
+
+	txPtr, err := h.beginTx(ctx)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting restore transaction.")
+		return ctxErr(ctx, err)
+	}
+	if err = h.restoreVersion(ctx, txPtr, id, lit, list, version); err != nil {
+		txPtr.Rollback()
+		return err
+	}
+	if err = txPtr.Commit(); err != nil {
+		return ctxErr(ctx, err)
+	}
+	h.cacheETag(id, "")
+	return nil
+}
+
+// restoreVersion runs Restore in the transaction, with the list of the
+// table's columns.
+func (h *Handler) restoreVersion(ctx context.Context, txPtr txConn, id interface{}, lit, list string, version int) error {
+	where := "id = " + lit
+	if err := h.archive(ctx, txPtr, where); err != nil {
+		return ctxErr(ctx, err)
+	}
+	stmts := []string{
+		fmt.Sprintf("DELETE FROM %s WHERE %s;", h.tableName, where),
+		fmt.Sprintf("INSERT INTO %s(%s) SELECT %s FROM %s WHERE %s AND %s = %d;",
+			h.tableName, list, list, historyTable(h), where, HistoryVersionColumn, version),
+		fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s;", h.tableName, h.column("updated"), h.timeFormat.literal(h.clock.Now()), where),
+	}
+	for n, s := range stmts {
+		result, err := txPtr.ExecContext(ctx, h.annotate(ctx, s))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"id":    id,
+				"error": err,
+			}).Warn("Error restoring item version.")
+			return ctxErr(ctx, err)
+		}
+		if n == 1 {
+			if ra, err := result.RowsAffected(); err != nil || ra == 0 {
+				return resource.ErrNotFound
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHistory(t *testing.T) {
+	Convey("Given a handler keeping history", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		hh := NewHandler(h.session, DB_TABLE, WithHistory())
+		h.session.Exec("DROP TABLE `" + historyTable(hh) + "`;")
+		So(hh.CreateHistoryTable(context.Background()), ShouldBeNil)
+
+		i1, _ := item("foo", 1)
+		So(hh.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+		i2, _ := item("bar", 2)
+		i2.ID = i1.ID
+		i2.Payload["id"] = i1.ID
+		So(hh.Update(context.Background(), i2, i1), ShouldBeNil)
+
+		Convey("Update and Delete should archive the replaced versions", func() {
+			So(hh.Delete(context.Background(), i2), ShouldBeNil)
+			versions, err := hh.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 2)
+			So(versions[0].Number, ShouldEqual, 1)
+			So(versions[0].Item.ETag, ShouldEqual, i1.ETag)
+			So(versions[0].Item.Payload["f1"], ShouldEqual, "foo")
+			So(versions[0].Item.Payload, ShouldNotContainKey, HistoryVersionColumn)
+			So(versions[0].Archived.IsZero(), ShouldBeFalse)
+			So(versions[1].Number, ShouldEqual, 2)
+			So(versions[1].Item.ETag, ShouldEqual, i2.ETag)
+		})
+
+		Convey("A conflicting write should not be archived", func() {
+			So(hh.Delete(context.Background(), i1), ShouldEqual, resource.ErrConflict)
+			versions, err := hh.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 1)
+		})
+
+		Convey("History should read the versions archived in a shared transaction", func() {
+			tx, err := h.session.BeginTx(context.Background(), nil)
+			So(err, ShouldBeNil)
+			th := hh.WithTx(tx)
+			So(th.Delete(context.Background(), i2), ShouldBeNil)
+			versions, err := th.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 2)
+			So(tx.Rollback(), ShouldBeNil)
+			versions, err = hh.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 1)
+		})
+
+		Convey("Clear should archive the removed items", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "bar"}})
+			n, err := hh.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			versions, err := hh.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 2)
+		})
+
+		Convey("Restore should bring a past version back", func() {
+			So(hh.Restore(context.Background(), i1.ID, 1), ShouldBeNil)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "id", Value: i1.ID}})
+			list, err := hh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].Payload["f1"], ShouldEqual, "foo")
+			So(list.Items[0].ETag, ShouldEqual, i1.ETag)
+
+			versions, err := hh.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 2)
+			So(versions[1].Item.Payload["f1"], ShouldEqual, "bar")
+
+			So(hh.Restore(context.Background(), i1.ID, 9), ShouldEqual, resource.ErrNotFound)
+		})
+	})
+}
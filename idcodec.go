@@ -0,0 +1,87 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rs/rest-layer/schema"
+)
+
+// IDCodec converts item IDs to the value stored in the id column and back.
+// Every statement referencing an id (inserts, updates, deletes, etag checks
+// and filters on the id field) goes through the handler's codec.
+type IDCodec interface {
+	// Encode returns the column value for an item ID.
+	Encode(id interface{}) (interface{}, error)
+	// Decode returns the item ID for a column value.
+	Decode(v interface{}) (interface{}, error)
+}
+
+// DefaultIDCodec stores IDs as they are given. Text columns are read back as
+// strings, integer columns as int64.
+var DefaultIDCodec IDCodec = rawIDCodec{}
+
+// IntIDCodec stores IDs in an INTEGER column. IDs may be given as any integer
+// type or a decimal string, and are always returned as int64.
+var IntIDCodec IDCodec = intIDCodec{}
+
+type rawIDCodec struct{}
+
+func (rawIDCodec) Encode(id interface{}) (interface{}, error) { return id, nil }
+func (rawIDCodec) Decode(v interface{}) (interface{}, error)  { return v, nil }
+
+type intIDCodec struct{}
+
+func (intIDCodec) Encode(id interface{}) (interface{}, error) {
+	switch t := id.(type) {
+	case int:
+		return int64(t), nil
+	case int32:
+		return int64(t), nil
+	case int64:
+		return t, nil
+	case float64:
+		if t == float64(int64(t)) {
+			return int64(t), nil
+		}
+	case string:
+		i, err := strconv.ParseInt(t, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid integer id: %v", id)
+}
+
+func (c intIDCodec) Decode(v interface{}) (interface{}, error) {
+	return c.Encode(v)
+}
+
+// WithIDCodec sets the codec used to store item IDs.
+func WithIDCodec(c IDCodec) Option {
+	return func(h *Handler) {
+		h.idCodec = c
+	}
+}
+
+// idLiteral returns the SQL literal for an item ID.
+func (h *Handler) idLiteral(id interface{}) (string, error) {
+	v, err := h.idCodec.Encode(id)
+	if err != nil {
+		return "", err
+	}
+	return valueToString(v)
+}
+
+// encodeIDs encodes the values of a filter on the id field.
+func (h *Handler) encodeIDs(values []schema.Value) ([]schema.Value, error) {
+	out := make([]schema.Value, len(values))
+	for i, v := range values {
+		e, err := h.idCodec.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = e
+	}
+	return out, nil
+}
@@ -0,0 +1,71 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIDCodec(t *testing.T) {
+	Convey("IntIDCodec should accept integer ids in any form", t, func() {
+		for _, id := range []interface{}{10, int64(10), 10.0, "10"} {
+			v, err := IntIDCodec.Encode(id)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, int64(10))
+		}
+		_, err := IntIDCodec.Encode("abc")
+		So(err, ShouldNotBeNil)
+		_, err = IntIDCodec.Encode(10.5)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Filters on id should be encoded", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "id", Value: "10"}}, WithIDCodec(IntIDCodec))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "id IS 10")
+
+		s, err = callGetQuery(schema.Query{schema.In{Field: "id", Values: []schema.Value{"10", 11}}}, WithIDCodec(IntIDCodec))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "id IN (10,11)")
+
+		// other fields are left alone
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "10"}}, WithIDCodec(IntIDCodec))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 = '10'")
+
+		_, err = callGetQuery(schema.Query{schema.Equal{Field: "id", Value: "abc"}}, WithIDCodec(IntIDCodec))
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+	})
+
+	Convey("Given a handler on a table with integer ids", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE `inttable`;")
+		_, err = h.session.Exec("CREATE TABLE `inttable` (`id` INTEGER PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`f1` VARCHAR(128),`f2` INTEGER);")
+		So(err, ShouldBeNil)
+		ih := NewHandler(h.session, "inttable", WithIDCodec(IntIDCodec))
+		it, _ := item("foo", 1)
+		it.ID = "42"
+		it.Payload["id"] = "42"
+		So(ih.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+
+		Convey("Find should return decoded ids", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "id", Value: "42"}})
+			result, err := ih.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(result.Items[0].ID, ShouldEqual, int64(42))
+			So(result.Items[0].Payload["id"], ShouldEqual, int64(42))
+		})
+
+		Convey("Delete should find the item by its encoded id", func() {
+			So(ih.Delete(context.Background(), it), ShouldBeNil)
+			So(ih.Delete(context.Background(), it), ShouldEqual, resource.ErrNotFound)
+		})
+	})
+}
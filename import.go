@@ -0,0 +1,288 @@
+package sqlite3
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// DefaultImportBatch is the number of items Import inserts per transaction
+// when WithImportBatch isn't given.
+const DefaultImportBatch = 5000
+
+// WithImportBatch sets the number of items Import inserts per transaction.
+func WithImportBatch(n int) Option {
+	return func(h *Handler) {
+		h.importBatch = n
+	}
+}
+
+// ImportError is the error of an Import record that can't be read or loaded.
+type ImportError struct {
+	// Record is the number of the record in the input, starting at 1. The
+	// header of a CSV input is record 0.
+	Record int
+	Err    error
+}
+
+// Error returns the record number and the error.
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("sqlite3: import record %d: %v", e.Record, e.Err)
+}
+
+// Unwrap returns the wrapped error.
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// Import loads the items read from r, in the format written by Export, and
+// returns the number of items inserted. It is much faster than creating the
+// items through the API: items are inserted in transactions of
+// WithImportBatch items, and change hooks are called once per transaction.
+//
+// Items without an id get a new UUID, and items without an _etag field the
+// MD5 etag of their payload (or the etag of WithETagFunc). Items without an
+// _updated field are stamped with the handler's clock. CSV fields are read as
+// strings, except for the fields of the handler's schema (see WithSchema)
+// that are numbers, booleans, times, dicts or arrays. With a schema, records
+// are validated against it, fields missing from a record get their default,
+// and a record missing a required field is rejected.
+//
+// Import stops at the first invalid record or failed transaction, returning
+// an *ImportError for the former; the batches committed before stay in the
+// table.
+func (h *Handler) Import(ctx context.Context, r io.Reader, format ExportFormat) (n int, err error) {
+	var read func() (map[string]interface{}, error)
+	switch format {
+	case ExportCSV:
+		cr := csv.NewReader(r)
+		var header []string
+		if header, err = cr.Read(); err != nil {
+			if err == io.EOF {
+				return 0, nil
+			}
+			return 0, &ImportError{Record: 0, Err: err}
+		}
+		read = func() (map[string]interface{}, error) {
+			record, err := cr.Read()
+			if err != nil {
+				return nil, err
+			}
+			row := make(map[string]interface{}, len(record))
+			for i, v := range record {
+				if i >= len(header) || v == "" {
+					continue
+				}
+				if row[header[i]], err = h.parseCSVValue(header[i], v); err != nil {
+					return nil, fmt.Errorf("field %s: %v", header[i], err)
+				}
+			}
+			return row, nil
+		}
+	case ExportJSONLines:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		read = func() (map[string]interface{}, error) {
+			var row map[string]interface{}
+			if err := dec.Decode(&row); err != nil {
+				return nil, err
+			}
+			for f, v := range row {
+				row[f] = jsonNumbers(v)
+			}
+			return row, nil
+		}
+	default:
+		return 0, fmt.Errorf("sqlite3: invalid import format: %q", format)
+	}
+
+	start := time.Now()
+	if err = h.ops.begin(); err != nil {
+		return 0, err
+	}
+	defer h.ops.end()
+	ctx, cancel := h.withBudget(ctx, OpImport)
+	defer cancel()
+	defer func() {
+		h.observe(OpImport, start, n, err)
+	}()
+
+	size := h.importBatch
+	if size <= 0 {
+		size = DefaultImportBatch
+	}
+	batch := make([]*resource.Item, 0, size)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := h.insertItems(ctx, batch); err != nil {
+			return err
+		}
+		h.emitItems(OpInsert, batch)
+		n += len(batch)
+		batch = make([]*resource.Item, 0, size)
+		return nil
+	}
+	for record := 1; ; record++ {
+		row, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, &ImportError{Record: record, Err: err}
+		}
+		i, err := h.importItem(row)
+		if err != nil {
+			return n, &ImportError{Record: record, Err: err}
+		}
+		if batch = append(batch, i); len(batch) == size {
+			if err = flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, flush()
+}
+
+// importItem returns the item of an imported record.
+func (h *Handler) importItem(row map[string]interface{}) (*resource.Item, error) {
+	etag, _ := row[ExportETagField].(string)
+	var updated time.Time
+	if u, found := row[ExportUpdatedField]; found {
+		var err error
+		if updated, err = parseImportTime(u); err != nil {
+			return nil, fmt.Errorf("field %s: %v", ExportUpdatedField, err)
+		}
+	}
+	delete(row, ExportETagField)
+	delete(row, ExportUpdatedField)
+	if c, ok := row["created"].(string); ok {
+		t, err := parseImportTime(c)
+		if err != nil {
+			return nil, fmt.Errorf("field created: %v", err)
+		}
+		row["created"] = t
+	}
+	if row["id"] == nil {
+		row["id"] = uuid.New()
+	}
+	if err := h.validateImport(row); err != nil {
+		return nil, err
+	}
+	i, err := resource.NewItem(row)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" && h.etagFunc == nil {
+		i.ETag = etag
+	}
+	// zero times are stamped by insertItems
+	i.Updated = updated
+	return i, nil
+}
+
+// validateImport validates an imported record against the handler's schema,
+// if any.
+func (h *Handler) validateImport(row map[string]interface{}) error {
+	if h.schema == nil {
+		return nil
+	}
+	for f := range row {
+		if _, found := h.schema[f]; !found {
+			return fmt.Errorf("field %s: not in the schema", f)
+		}
+	}
+	for name, f := range h.schema {
+		v, found := row[name]
+		if !found {
+			if f.Default != nil {
+				row[name] = f.Default
+			} else if f.Required {
+				return fmt.Errorf("field %s: required", name)
+			}
+			continue
+		}
+		if f.Validator == nil {
+			continue
+		}
+		v, err := f.Validator.Validate(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", name, err)
+		}
+		row[name] = v
+	}
+	return nil
+}
+
+// parseCSVValue converts the CSV text of a field to the type of the field in
+// the handler's schema, reversing the conversions of Export.
+func (h *Handler) parseCSVValue(field, v string) (interface{}, error) {
+	if field == ExportUpdatedField {
+		return parseImportTime(v)
+	}
+	f, found := h.schema[field]
+	if !found {
+		return v, nil
+	}
+	switch f.Validator.(type) {
+	case *schema.Integer:
+		return strconv.Atoi(v)
+	case *schema.Float:
+		return strconv.ParseFloat(v, 64)
+	case *schema.Bool:
+		return strconv.ParseBool(v)
+	case *schema.Time:
+		return parseImportTime(v)
+	case *schema.Dict, *schema.Array:
+		var j interface{}
+		if err := json.Unmarshal([]byte(v), &j); err != nil {
+			return nil, err
+		}
+		return j, nil
+	}
+	return v, nil
+}
+
+// parseImportTime parses an RFC 3339 time.
+func parseImportTime(v interface{}) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time: %v", v)
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// jsonNumbers converts the json.Number values of a decoded JSON value to
+// int64 when they are integers, and float64 otherwise.
+func jsonNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonNumbers(e)
+		}
+	case []interface{}:
+		for k, e := range v {
+			v[k] = jsonNumbers(e)
+		}
+	}
+	return v
+}
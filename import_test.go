@@ -0,0 +1,99 @@
+package sqlite3
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestImport(t *testing.T) {
+	Convey("Given an empty table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+		count := func() int {
+			var n int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE).Scan(&n), ShouldBeNil)
+			return n
+		}
+
+		Convey("Import should load an export", func() {
+			a, _ := item("a", 1)
+			b, _ := item("b", 2)
+			So(h.Insert(ctx, []*resource.Item{a, b}), ShouldBeNil)
+			for _, format := range []ExportFormat{ExportCSV, ExportJSONLines} {
+				var buf bytes.Buffer
+				_, err := h.Export(ctx, &buf, format, resource.NewLookup())
+				So(err, ShouldBeNil)
+				_, err = h.session.Exec("DELETE FROM " + DB_TABLE)
+				So(err, ShouldBeNil)
+				ih := NewHandler(h.session, DB_TABLE, WithImportBatch(1), WithSchema(schema.Schema{
+					"id":      schema.IDField,
+					"created": schema.Field{Validator: &schema.Time{}},
+					"f1":      schema.Field{Validator: &schema.String{}},
+					"f2":      schema.Field{Validator: &schema.Integer{}},
+				}))
+				n, err := ih.Import(ctx, &buf, format)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+				l := resource.NewLookup()
+				l.SetSort("f2", nil)
+				list, err := h.Find(ctx, l, 1, -1)
+				So(err, ShouldBeNil)
+				So(len(list.Items), ShouldEqual, 2)
+				So(list.Items[0].ID, ShouldEqual, a.ID)
+				So(list.Items[0].ETag, ShouldEqual, a.ETag)
+				So(list.Items[0].Payload["f2"], ShouldEqual, 1)
+				So(list.Items[1].Payload["f1"], ShouldEqual, "b")
+			}
+		})
+
+		Convey("Import should generate ids and etags", func() {
+			n, err := h.Import(ctx, strings.NewReader("{\"f1\":\"x\",\"f2\":1}\n{\"f1\":\"y\",\"f2\":2}\n"), ExportJSONLines)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			list, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 2)
+			So(list.Items[0].ID, ShouldNotBeEmpty)
+			So(list.Items[0].ETag, ShouldNotBeEmpty)
+			So(list.Items[0].ID, ShouldNotEqual, list.Items[1].ID)
+		})
+
+		Convey("Import should reject records not matching the schema", func() {
+			sh := NewHandler(h.session, DB_TABLE, WithImportBatch(1), WithSchema(schema.Schema{
+				"id": schema.IDField,
+				"f1": schema.Field{Required: true, Validator: &schema.String{}},
+				"f2": schema.Field{Validator: &schema.Integer{}},
+			}))
+			n, err := sh.Import(ctx, strings.NewReader("f1,f2\nx,1\n,2\ny,3\n"), ExportCSV)
+			So(n, ShouldEqual, 1)
+			var ie *ImportError
+			So(errors.As(err, &ie), ShouldBeTrue)
+			So(ie.Record, ShouldEqual, 2)
+			So(count(), ShouldEqual, 1)
+
+			_, err = sh.Import(ctx, strings.NewReader("f1,f3\nx,1\n"), ExportCSV)
+			So(errors.As(err, &ie), ShouldBeTrue)
+			So(ie.Record, ShouldEqual, 1)
+
+			_, err = sh.Import(ctx, strings.NewReader("f1,f2\nx,one\n"), ExportCSV)
+			So(errors.As(err, &ie), ShouldBeTrue)
+		})
+
+		Convey("Import should reject unknown formats", func() {
+			_, err := h.Import(ctx, strings.NewReader(""), "xml")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// IndexOptions configures EnsureIndexes.
+type IndexOptions struct {
+	// Unique lists the fields whose index is unique.
+	Unique []string
+	// DropStale drops the indexes EnsureIndexes created earlier for fields
+	// that are no longer filterable or sortable. Other indexes are kept.
+	DropStale bool
+	// DryRun only returns the planned statements, without running them.
+	DryRun bool
+}
+
+// indexPrefix returns the prefix of the names of the indexes managed by
+// EnsureIndexes.
+func indexPrefix(h *Handler) string {
+	return h.tableName + "_idx_"
+}
+
+// EnsureIndexes creates an index for each Filterable or Sortable field of the
+// schema that doesn't have one yet, on its column or, for fields stored as
+// JSON, on the json_extract expression filters and sorts use. The id, already
+// the primary key, is skipped. Like Migrate, the statements are run in a
+// single transaction and returned, in the order they are run. An index is not
+// rebuilt if a field changes from or to unique: drop it first.
+func (h *Handler) EnsureIndexes(ctx context.Context, s schema.Schema, o IndexOptions) ([]string, error) {
+	wanted, err := indexStatements(h, s, o.Unique)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := indexNames(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	stmts := []string{}
+	if o.DropStale {
+		for _, idx := range sortedColumns(existing) {
+			if _, found := wanted[idx]; !found && strings.HasPrefix(idx, indexPrefix(h)) {
+				stmts = append(stmts, fmt.Sprintf("DROP INDEX IF EXISTS `%s`;", idx))
+			}
+		}
+	}
+	for _, idx := range sortedColumns(wanted) {
+		if _, found := existing[idx]; !found {
+			stmts = append(stmts, wanted[idx])
+		}
+	}
+	for _, stmt := range stmts {
+		log.WithFields(log.Fields{
+			"table":  h.tableName,
+			"dryrun": o.DryRun,
+		}).Info("Indexes: " + stmt)
+	}
+	if o.DryRun || len(stmts) == 0 {
+		return stmts, nil
+	}
+
+	txPtr, err := h.session.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting index transaction.")
+		return nil, ctxErr(ctx, err)
+	}
+	for _, stmt := range stmts {
+		if _, err = txPtr.ExecContext(ctx, stmt); err != nil {
+			txPtr.Rollback()
+			log.WithFields(log.Fields{
+				"table": h.tableName,
+				"error": err,
+			}).Warn("Error updating indexes.")
+			return nil, ctxErr(ctx, err)
+		}
+	}
+	return stmts, ctxErr(ctx, txPtr.Commit())
+}
+
+// indexStatements returns the statements creating the indexes of the
+// filterable and sortable fields of a schema, by index name.
+func indexStatements(h *Handler, s schema.Schema, uniqueFields []string) (map[string]string, error) {
+	if h.optionErr != nil {
+		return nil, h.optionErr
+	}
+	unique := map[string]bool{}
+	for _, f := range uniqueFields {
+		unique[f] = true
+	}
+	stmts := map[string]string{}
+	for name, f := range s {
+		if name == "id" || !(f.Filterable || f.Sortable || unique[name]) {
+			continue
+		}
+		if !identRe.MatchString(name) {
+			return nil, fmt.Errorf("sqlite3: invalid indexed field: %q", name)
+		}
+		kind := "INDEX"
+		if unique[name] {
+			kind = "UNIQUE INDEX"
+		}
+		stmts[indexPrefix(h)+name] = fmt.Sprintf("CREATE %s IF NOT EXISTS `%s%s` ON `%s`(%s);",
+			kind, indexPrefix(h), name, h.tableName, h.compareRef(name))
+	}
+	return stmts, nil
+}
+
+// indexNames returns the indexes of the handler's table, their names mapped
+// to their SQL.
+func indexNames(ctx context.Context, h *Handler) (map[string]string, error) {
+	rows, err := h.session.QueryContext(ctx, "SELECT name, COALESCE(sql, '') FROM sqlite_master WHERE type = 'index' AND tbl_name = ?;", h.tableName)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": h.tableName,
+			"error": err,
+		}).Warn("Error listing indexes.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+	indexes := map[string]string{}
+	for rows.Next() {
+		var name, sql string
+		if err = rows.Scan(&name, &sql); err != nil {
+			return nil, err
+		}
+		indexes[name] = sql
+	}
+	return indexes, ctxErr(ctx, rows.Err())
+}
@@ -0,0 +1,73 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnsureIndexes(t *testing.T) {
+	s := schema.Schema{
+		"id":      schema.IDField,
+		"updated": schema.Field{Filterable: true, Sortable: true},
+		"f1":      schema.Field{Filterable: true, Validator: &schema.String{}},
+		"f2":      schema.Field{Validator: &schema.Integer{}},
+	}
+
+	Convey("Given a table without indexes", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("Filterable and sortable fields should be indexed once", func() {
+			stmts, err := h.EnsureIndexes(ctx, s, IndexOptions{Unique: []string{"f2"}})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldResemble, []string{
+				"CREATE INDEX IF NOT EXISTS `testtable_idx_f1` ON `testtable`(f1);",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `testtable_idx_f2` ON `testtable`(f2);",
+				"CREATE INDEX IF NOT EXISTS `testtable_idx_updated` ON `testtable`(updated);",
+			})
+			indexes, err := indexNames(ctx, h)
+			So(err, ShouldBeNil)
+			So(indexes, ShouldContainKey, "testtable_idx_f1")
+
+			stmts, err = h.EnsureIndexes(ctx, s, IndexOptions{Unique: []string{"f2"}})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldBeEmpty)
+		})
+
+		Convey("Stale indexes should only be dropped when asked to", func() {
+			_, err := h.EnsureIndexes(ctx, s, IndexOptions{})
+			So(err, ShouldBeNil)
+			_, err = h.session.Exec("CREATE INDEX testtable_manual ON testtable(f2);")
+			So(err, ShouldBeNil)
+			less := schema.Schema{"f1": s["f1"]}
+
+			stmts, err := h.EnsureIndexes(ctx, less, IndexOptions{DryRun: true})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldBeEmpty)
+
+			stmts, err = h.EnsureIndexes(ctx, less, IndexOptions{DropStale: true})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldResemble, []string{"DROP INDEX IF EXISTS `testtable_idx_updated`;"})
+			indexes, err := indexNames(ctx, h)
+			So(err, ShouldBeNil)
+			So(indexes, ShouldContainKey, "testtable_manual")
+			So(indexes, ShouldNotContainKey, "testtable_idx_updated")
+		})
+
+		Convey("Fields stored as JSON should be indexed on their expression", func() {
+			jh := NewHandler(nil, DB_TABLE, WithStorageMode(StorageJSON))
+			jh.session = h.session
+			stmts, err := jh.EnsureIndexes(ctx, schema.Schema{"f1": s["f1"]}, IndexOptions{DryRun: true})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldResemble, []string{"CREATE INDEX IF NOT EXISTS `testtable_idx_f1` ON `testtable`(json_extract(payload,'$.f1'));"})
+		})
+	})
+}
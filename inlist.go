@@ -0,0 +1,188 @@
+package sqlite3
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// inTable is a membership expression whose values were loaded into a
+// temporary table. It is only produced by spillInLists and only understood by
+// translateQuery.
+type inTable struct {
+	Field string
+	Table string
+	Not   bool
+	// Null is set when the list had nil values, which aren't loaded.
+	Null bool
+}
+
+// Match is required by schema.Expression, in-memory matching is not supported.
+func (e inTable) Match(payload map[string]interface{}) bool {
+	return false
+}
+
+// needsSpill reports whether the query contains a membership list larger than
+// the handler's threshold.
+func (h *Handler) needsSpill(q schema.Query) bool {
+	if h.inListThreshold <= 0 {
+		return false
+	}
+	for _, exp := range q {
+		switch t := exp.(type) {
+		case schema.And:
+			if h.needsSpill(schema.Query(t)) {
+				return true
+			}
+		case schema.Or:
+			if h.needsSpill(schema.Query(t)) {
+				return true
+			}
+		case schema.In:
+			if len(t.Values) > h.inListThreshold {
+				return true
+			}
+		case schema.NotIn:
+			if len(t.Values) > h.inListThreshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spillInLists returns a copy of the query where membership lists larger than
+// threshold are replaced by inTable expressions. The values are
+// inserted into temporary tables created on tx, whose names are returned.
+// Temporary tables are private to the connection and are dropped when the
+// transaction is rolled back.
+func spillInLists(ctx context.Context, h *Handler, tx txConn, q schema.Query, threshold int) (schema.Query, []string, error) {
+	tables := []string{}
+	var spill func(q schema.Query) (schema.Query, error)
+	spill = func(q schema.Query) (schema.Query, error) {
+		out := make(schema.Query, 0, len(q))
+		for _, exp := range q {
+			switch t := exp.(type) {
+			case schema.And:
+				sub, err := spill(schema.Query(t))
+				if err != nil {
+					return nil, err
+				}
+				exp = schema.And(sub)
+			case schema.Or:
+				sub, err := spill(schema.Query(t))
+				if err != nil {
+					return nil, err
+				}
+				exp = schema.Or(sub)
+			case schema.In:
+				if len(t.Values) > threshold {
+					values, err := spillValues(h, t.Field, t.Values)
+					if err != nil {
+						return nil, err
+					}
+					values, null := splitNull(values)
+					name, err := createInTable(ctx, tx, len(tables), values)
+					if err != nil {
+						return nil, err
+					}
+					tables = append(tables, name)
+					exp = inTable{Field: t.Field, Table: name, Null: null}
+				}
+			case schema.NotIn:
+				if len(t.Values) > threshold {
+					values, err := spillValues(h, t.Field, t.Values)
+					if err != nil {
+						return nil, err
+					}
+					values, null := splitNull(values)
+					name, err := createInTable(ctx, tx, len(tables), values)
+					if err != nil {
+						return nil, err
+					}
+					tables = append(tables, name)
+					exp = inTable{Field: t.Field, Table: name, Not: true, Null: null}
+				}
+			}
+			out = append(out, exp)
+		}
+		return out, nil
+	}
+	out, err := spill(q)
+	return out, tables, err
+}
+
+// spillValues converts the values of a membership list on a field to the
+// values stored, as translateQuery does for the lists it writes: ids through
+// the ID codec, times to the handler's TimeFormat and json.Number values to
+// numbers.
+func spillValues(h *Handler, field string, values []schema.Value) ([]schema.Value, error) {
+	var err error
+	if field == "id" {
+		if values, err = h.encodeIDs(values); err != nil {
+			return nil, resource.ErrNotImplemented
+		}
+	}
+	if values, err = timeValues(h, field, values); err != nil {
+		return nil, err
+	}
+	out := make([]schema.Value, len(values))
+	for i, v := range values {
+		switch t := v.(type) {
+		case json.Number:
+			if v, err = numberValue(t); err != nil {
+				return nil, err
+			}
+		case uint64:
+			// database/sql only binds uint64 values fitting an int64, larger
+			// ones are REAL in SQLite
+			if t > math.MaxInt64 {
+				v = float64(t)
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// createInTable creates a temporary table holding the values and returns its
+// qualified name.
+func createInTable(ctx context.Context, tx txConn, n int, values []schema.Value) (string, error) {
+	name := fmt.Sprintf("temp._in_%d", n)
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE _in_%d (value);", n))
+	if err != nil {
+		log.WithField("error", err).Warn("Error creating temporary table for In list.")
+		return "", err
+	}
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO "+name+" VALUES (?);")
+	if err != nil {
+		log.WithField("error", err).Warn("Error preparing temporary table insert.")
+		return "", err
+	}
+	defer stmt.Close()
+	for _, v := range values {
+		if _, err = stmt.ExecContext(ctx, v); err != nil {
+			log.WithField("error", err).Warn("Error loading In list value.")
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// dropSpills drops the temporary tables created by spillInLists, so they don't
+// outlive a committed transaction.
+func dropSpills(ctx context.Context, tx txConn, tables []string) error {
+	for _, t := range tables {
+		if _, err := tx.ExecContext(ctx, "DROP TABLE "+t+";"); err != nil {
+			log.WithField("error", err).Warn("Error dropping temporary table.")
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package sqlite3
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInLists(t *testing.T) {
+	Convey("Spilled In lists should be translated to sub-selects", t, func() {
+		s, err := callGetQuery(schema.Query{inTable{Field: "f1", Table: "temp._in_0"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IN (SELECT value FROM temp._in_0)")
+
+		s, err = callGetQuery(schema.Query{inTable{Field: "f1", Table: "temp._in_0", Not: true}}, WithNullMatching(true))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 NOT IN (SELECT value FROM temp._in_0) OR f1 IS NULL)")
+	})
+
+	Convey("Given a handler with a low In list threshold", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		th := NewHandler(h.session, DB_TABLE, WithInListThreshold(10))
+
+		values := []schema.Value{"foo"}
+		for i := 0; i < 1000; i++ {
+			values = append(values, fmt.Sprintf("v%d", i))
+		}
+
+		Convey("needsSpill should only report lists above the threshold", func() {
+			So(th.needsSpill(schema.Query{schema.In{Field: "f1", Values: values[:10]}}), ShouldBeFalse)
+			So(th.needsSpill(schema.Query{schema.Or{schema.In{Field: "f1", Values: values}}}), ShouldBeTrue)
+			So(NewHandler(nil, DB_TABLE, WithInListThreshold(0)).needsSpill(schema.Query{schema.In{Field: "f1", Values: values}}), ShouldBeFalse)
+		})
+
+		Convey("Find should match large In lists", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.In{Field: "f1", Values: values}})
+			result, err := th.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(result.Items[0].ID, ShouldEqual, i1.ID)
+		})
+
+		Convey("Find should match large In lists of converted values", func() {
+			updated := []schema.Value{i2.Updated}
+			for i := 0; i < 20; i++ {
+				updated = append(updated, i2.Updated.Add(time.Duration(i+1)*time.Second))
+			}
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.In{Field: "updated", Values: updated}})
+			result, err := th.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(result.Items[0].ID, ShouldEqual, i2.ID)
+		})
+
+		Convey("Find should match large In lists of json.Number values", func() {
+			numbers := []schema.Value{}
+			for i := 2; i < 30; i++ {
+				numbers = append(numbers, json.Number(fmt.Sprint(i)))
+			}
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.In{Field: "f2", Values: numbers}})
+			result, err := th.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(result.Items[0].ID, ShouldEqual, i2.ID)
+		})
+
+		Convey("Clear should match large NotIn lists", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.NotIn{Field: "f1", Values: values}})
+			n, err := th.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+
+			Convey("and leave no temporary table behind", func() {
+				n, err := th.Clear(context.Background(), l)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+			})
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package sqlite3
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// InsertOrGet inserts the item unless an item with the same values for all of
+// the conflict fields already exists, in which case the existing item is
+// returned. The conflict fields must be covered by a unique index or primary
+// key. The returned bool reports whether the item was inserted. Both steps run
+// in one transaction, so concurrent callers registering the same item get the
+// same result.
+func (h *Handler) InsertOrGet(ctx context.Context, item *resource.Item, conflictFields []string) (*resource.Item, bool, error) {
+	if len(conflictFields) == 0 {
+		return nil, false, fmt.Errorf("sqlite3: InsertOrGet requires conflict fields")
+	}
+	start := time.Now()
+	var existing *resource.Item
+	err := h.retryBusy(ctx, OpInsert, func() (err error) {
+		existing, err = h.insertOrGet(ctx, item, conflictFields)
+		return err
+	})
+	if err != nil || existing != nil {
+		h.observe(OpInsert, start, 0, err)
+		return existing, false, err
+	}
+	h.observe(OpInsert, start, 1, nil)
+	h.emitItems(OpInsert, []*resource.Item{item})
+	return item, true, nil
+}
+
+// insertOrGet runs InsertOrGet. It returns the existing item if the item
+// wasn't inserted.
+func (h *Handler) insertOrGet(ctx context.Context, item *resource.Item, conflictFields []string) (*resource.Item, error) {
+	if err := h.ops.begin(); err != nil {
+		return nil, err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpInsert)
+	defer cancel()
+
+	var existing *resource.Item
+	err := h.insertRows(ctx, []*resource.Item{item}, true, func(tx txConn, inserted int) error {
+		if inserted == 1 {
+			return nil
+		}
+		sel, err := conflictSelect(h, item, conflictFields)
+		if err != nil {
+			return err
+		}
+		list, err := runSelect(ctx, h, tx, sel, 1)
+		if err != nil {
+			return err
+		}
+		if len(list.Items) == 0 {
+			// the insert was ignored for another reason than a conflict on
+			// the requested live fields, e.g. a conflicting id or a soft
+			// deleted row.
+			return resource.ErrConflict
+		}
+		existing = list.Items[0]
+		return nil
+	})
+	return existing, err
+}
+
+// conflictSelect returns the statement selecting the live item with the same
+// values as item for the conflict fields.
+func conflictSelect(h *Handler, item *resource.Item, conflictFields []string) (string, error) {
+	where := ""
+	for _, f := range conflictFields {
+		var v string
+		var err error
+		if f == "id" {
+			v, err = h.idLiteral(item.Payload[f])
+		} else {
+			v, err = valueToString(item.Payload[f])
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"key":   f,
+				"error": err,
+			}).Warn("Error converting conflict field value to string.")
+			return "", resource.ErrNotImplemented
+		}
+		where += h.fieldRef(f) + " IS " + v + " AND "
+	}
+	// remove the last " AND "
+	return "SELECT * FROM " + h.tableName + " WHERE " + h.liveWhere(where[:len(where)-5]) + " LIMIT 1;", nil
+}
@@ -0,0 +1,100 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInsertOrGet(t *testing.T) {
+	Convey("Given a table with a unique f1 column", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		_, err = h.session.Exec("CREATE UNIQUE INDEX `testtable_f1` ON `" + DB_TABLE + "` (`f1`);")
+		So(err, ShouldBeNil)
+
+		Convey("InsertOrGet should insert a new item", func() {
+			got, created, err := h.InsertOrGet(context.Background(), i1, []string{"f1"})
+			So(err, ShouldBeNil)
+			So(created, ShouldBeTrue)
+			So(got, ShouldEqual, i1)
+
+			Convey("and return the existing item on conflict", func() {
+				dup, _ := item("foo", 5)
+				got, created, err := h.InsertOrGet(context.Background(), dup, []string{"f1"})
+				So(err, ShouldBeNil)
+				So(created, ShouldBeFalse)
+				So(got.ID, ShouldEqual, i1.ID)
+				So(got.Payload["f2"], ShouldEqual, 1)
+			})
+
+			Convey("and return ErrConflict when another constraint conflicts", func() {
+				dup, _ := item("other", 7)
+				dup.Payload["id"] = i1.ID
+				_, _, err := h.InsertOrGet(context.Background(), dup, []string{"f1"})
+				So(err, ShouldEqual, resource.ErrConflict)
+			})
+		})
+
+		Convey("InsertOrGet should apply the ETagFunc", func() {
+			eh := NewHandler(h.session, DB_TABLE, WithETagFunc(MD5ETag))
+			it, _ := item("foo", 1)
+			it.ETag = "untrusted"
+			_, created, err := eh.InsertOrGet(context.Background(), it, []string{"f1"})
+			So(err, ShouldBeNil)
+			So(created, ShouldBeTrue)
+			expected, err := MD5ETag(it.Payload)
+			So(err, ShouldBeNil)
+			So(it.ETag, ShouldEqual, expected)
+		})
+
+		Convey("InsertOrGet should run in the shared transaction", func() {
+			tx, err := h.session.Begin()
+			So(err, ShouldBeNil)
+			it, _ := item("foo", 1)
+			_, created, err := h.WithTx(tx).InsertOrGet(context.Background(), it, []string{"f1"})
+			So(err, ShouldBeNil)
+			So(created, ShouldBeTrue)
+			So(tx.Rollback(), ShouldBeNil)
+			var n int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE).Scan(&n), ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("InsertOrGet should require conflict fields", func() {
+			_, _, err := h.InsertOrGet(context.Background(), i1, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a soft deleting handler with a unique f1 column", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE insertorget;")
+		sh := NewHandler(h.session, "insertorget", WithSoftDelete())
+		So(sh.EnsureTable(context.Background(), schema.Schema{
+			"id": schema.IDField,
+			"f1": schema.Field{Validator: &schema.String{}},
+			"f2": schema.Field{Validator: &schema.Integer{}},
+		}), ShouldBeNil)
+		_, err = h.session.Exec("CREATE UNIQUE INDEX insertorget_f1 ON insertorget (f1);")
+		So(err, ShouldBeNil)
+		deleted, _ := item("foo", 1)
+		So(sh.Insert(context.Background(), []*resource.Item{deleted}), ShouldBeNil)
+		So(sh.Delete(context.Background(), deleted), ShouldBeNil)
+
+		Convey("InsertOrGet should not return deleted items", func() {
+			it, _ := item("foo", 2)
+			got, _, err := sh.InsertOrGet(context.Background(), it, []string{"f1"})
+			So(err, ShouldEqual, resource.ErrConflict)
+			So(got, ShouldBeNil)
+		})
+	})
+}
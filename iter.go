@@ -0,0 +1,174 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// ItemIterator reads the items of a select statement one row at a time, so
+// large results don't have to be held in memory at once. It must be closed.
+type ItemIterator struct {
+	ctx  context.Context
+	h    *Handler
+	rows *sql.Rows
+	// q is the statement of the rows
+	q    string
+	cols []string
+	// blobs marks the columns declared BLOB
+	blobs []bool
+	item  *resource.Item
+	err   error
+	// n counts the items read
+	n int
+	// done releases what the iteration holds, if set
+	done func(n int, err error)
+}
+
+// newItemIterator returns an iterator over the rows of a query result.
+func newItemIterator(ctx context.Context, h *Handler, rows *sql.Rows, q string) (*ItemIterator, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		log.WithField("error", err).Warn("Error getting columns.")
+		return nil, h.storageErr(ctx, ErrScan, OpFind, q, err)
+	}
+	return &ItemIterator{ctx: ctx, h: h, rows: rows, q: q, cols: cols, blobs: blobColumns(rows, len(cols))}, nil
+}
+
+// Next reads the next item, and reports whether there was one. It returns
+// false at the end of the result or on error, see Err.
+func (it *ItemIterator) Next() bool {
+	for it.err == nil && it.rows.Next() {
+		row, err := scanRow(it.h, it.rows, it.cols, it.blobs)
+		if err != nil {
+			it.err = it.h.storageErr(it.ctx, ErrScan, OpFind, it.q, err)
+			return false
+		}
+		id := row["id"]
+		item, err := newItem(it.h, row)
+		if err != nil {
+			if it.h.rowErrors == RowErrorSkip {
+				atomic.AddInt64(it.h.skipped, 1)
+				log.WithFields(log.Fields{
+					"id":    id,
+					"error": err,
+				}).Warn("Skipping a row that can't be converted to an Item.")
+				continue
+			}
+			log.WithField("error", err).Warn("Error creating an Item from a row.")
+			it.err = it.h.storageErr(it.ctx, ErrScan, OpFind, it.q, err)
+			return false
+		}
+		it.item = item
+		it.n++
+		return true
+	}
+	if it.err == nil {
+		if err := it.rows.Err(); err != nil {
+			log.WithField("error", err).Warn("Error during row iteration.")
+			it.err = it.h.storageErr(it.ctx, ErrExec, OpFind, it.q, err)
+		}
+	}
+	it.item = nil
+	return false
+}
+
+// Item returns the item read by the last call to Next.
+func (it *ItemIterator) Item() *resource.Item {
+	return it.item
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *ItemIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration and releases its resources. It can be called
+// more than once.
+func (it *ItemIterator) Close() error {
+	err := it.rows.Close()
+	if it.done != nil {
+		it.done(it.n, it.err)
+		it.done = nil
+	}
+	return err
+}
+
+// scanRow reads the current row of a result as a map of column values, with
+// byte arrays converted to strings, but in the BLOB columns marked in blobs.
+func scanRow(h *Handler, rows *sql.Rows, cols []string, blobs []bool) (map[string]interface{}, error) {
+	rowMap := make(map[string]interface{})       // col:val map for a row
+	rowVals := make([]interface{}, len(cols))    // values for a row
+	rowValPtrs := make([]interface{}, len(cols)) // pointers to row values used by Scan
+
+	// create the pointers to the row value elements
+	for i := range cols {
+		rowValPtrs[i] = &rowVals[i]
+	}
+
+	// scan into the pointer slice (and set the values)
+	if err := rows.Scan(rowValPtrs...); err != nil {
+		log.WithField("error", err).Warn("Error scanning a row.")
+		return nil, err
+	}
+
+	// convert byte arrays to strings
+	for i, v := range rowVals {
+		if v == nil && h.nullMode == NullExplicit {
+			continue
+		}
+		if b, ok := v.([]byte); ok {
+			if blobs[i] {
+				v = h.blobValue(b)
+			} else {
+				v = string(b)
+			}
+		}
+		rowMap[cols[i]] = v
+	}
+	return rowMap, nil
+}
+
+// FindIter is like Find, but returns an iterator reading the items as they
+// are needed instead of a list, for large results. The total isn't counted.
+// The iterator holds a connection of the pool, and must be closed.
+func (h *Handler) FindIter(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*ItemIterator, error) {
+	start := time.Now()
+	if err := h.ops.begin(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := h.withBudget(ctx, OpFind)
+	release := func(n int, err error) {
+		cancel()
+		h.ops.end()
+		h.observe(OpFind, start, n, err)
+	}
+
+	p, err := h.planFind(ctx, lookup, page, perPage)
+	if err != nil {
+		release(0, err)
+		return nil, err
+	}
+	rows, err := p.db.QueryContext(ctx, h.annotate(ctx, p.q))
+	if err == nil {
+		var it *ItemIterator
+		if it, err = newItemIterator(ctx, h, rows, p.q); err == nil {
+			it.done = func(n int, err error) {
+				p.close()
+				release(n, err)
+			}
+			return it, nil
+		}
+	}
+	log.WithField("error", err).Warn("Error querying the DB.")
+	err = h.storageErr(ctx, ErrExec, OpFind, p.q, err)
+	p.close()
+	release(0, err)
+	return nil, err
+}
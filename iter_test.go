@@ -0,0 +1,53 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindIter(t *testing.T) {
+	Convey("Given a handler with stored items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		c := &recordingCollector{}
+		ih := NewHandler(h.session, DB_TABLE, WithCollector(c))
+		ctx := context.Background()
+		i1, _ := item("foo", 1)
+		i2, _ := item("bar", 2)
+		i3, _ := item("baz", 3)
+		So(ih.Insert(ctx, []*resource.Item{i1, i2, i3}), ShouldBeNil)
+
+		Convey("The iterator should read the items of the lookup in order", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: 1}})
+			l.SetSort("-f2", nil)
+			it, err := ih.FindIter(ctx, l, 1, 10)
+			So(err, ShouldBeNil)
+			ids := []interface{}{}
+			for it.Next() {
+				ids = append(ids, it.Item().ID)
+			}
+			So(it.Err(), ShouldBeNil)
+			So(it.Item(), ShouldBeNil)
+			So(it.Close(), ShouldBeNil)
+			So(it.Close(), ShouldBeNil)
+			So(ids, ShouldResemble, []interface{}{i3.ID, i2.ID})
+			So(c.obs[len(c.obs)-1], ShouldResemble, observation{DB_TABLE, OpFind, 2, nil})
+		})
+
+		Convey("Invalid lookups should fail before iterating", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1; DROP", Value: 1}})
+			_, err := ih.FindIter(ctx, l, 1, 10)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
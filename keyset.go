@@ -0,0 +1,154 @@
+package sqlite3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// ErrInvalidCursor is returned by Find when the cursor of a keyset paginated
+// request can't be decoded or doesn't match the sort of the request.
+var ErrInvalidCursor = errors.New("sqlite3: invalid cursor")
+
+// literalRe matches the SQL literals produced by valueToString, the only ones
+// accepted in cursors.
+var literalRe = regexp.MustCompile(`^(NULL|-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?|'([^']|'')*'|X'[0-9a-f]*')$`)
+
+type cursorKey struct{}
+
+// WithKeysetPagination makes Find paginate the requests carrying a cursor,
+// attached with NewCursorContext, from the position of the cursor in the sort
+// order instead of by offset, so deep pages cost as much as the first one when
+// the sort fields are indexed. The id is added to the sort of such requests
+// to make it total, and their page number is ignored. The sort fields should
+// not be NULL, as NULL values can't be compared.
+func WithKeysetPagination(enabled bool) Option {
+	return func(h *Handler) {
+		h.keyset = enabled
+	}
+}
+
+// NewCursorContext returns a copy of ctx carrying a cursor returned by
+// Cursor. An empty cursor selects the first page.
+func NewCursorContext(ctx context.Context, cursor string) context.Context {
+	return context.WithValue(ctx, cursorKey{}, cursor)
+}
+
+// CursorFromContext returns the cursor attached to ctx, if any.
+func CursorFromContext(ctx context.Context) (string, bool) {
+	c, ok := ctx.Value(cursorKey{}).(string)
+	return c, ok
+}
+
+// Cursor returns an opaque cursor holding the position of an item in the
+// given sort order, typically the last item of a page. A request carrying the
+// cursor gets the items following it.
+func (h *Handler) Cursor(i *resource.Item, sort []string) (string, error) {
+	fields := keysetSort(sort)
+	lits := make([]string, len(fields))
+	for n, f := range fields {
+		var err error
+		switch f = strings.TrimPrefix(f, "-"); f {
+		case "id":
+			lits[n], err = h.idLiteral(i.ID)
+		case "updated":
+			lits[n] = h.timeFormat.literal(i.Updated)
+		default:
+			lits[n], err = h.valueLiteral(i.Payload[f])
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	b, err := json.Marshal(lits)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// keysetSort returns a sort completed with the id, in the direction of the
+// last field, so no two items have the same position.
+func keysetSort(sort []string) []string {
+	for _, f := range sort {
+		if f == "id" || f == "-id" {
+			return sort
+		}
+	}
+	if len(sort) > 0 && strings.HasPrefix(sort[len(sort)-1], "-") {
+		return append(append([]string{}, sort...), "-id")
+	}
+	return append(append([]string{}, sort...), "id")
+}
+
+// keysetBound is the predicate selecting the items after a cursor. It is only
+// produced by keysetFilter and only understood by translateQuery.
+type keysetBound struct {
+	// Expr is the SQL expression of the predicate.
+	Expr string
+}
+
+// Match is required by schema.Expression, in-memory matching is not supported.
+func (e keysetBound) Match(payload map[string]interface{}) bool {
+	return false
+}
+
+// keysetFilter returns the filter and sort of the page following a cursor.
+func (h *Handler) keysetFilter(filter schema.Query, sort []string, cursor string) (schema.Query, []string, error) {
+	sort = keysetSort(sort)
+	if cursor == "" {
+		return filter, sort, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	var lits []string
+	if err = json.Unmarshal(b, &lits); err != nil || len(lits) != len(sort) {
+		return nil, nil, ErrInvalidCursor
+	}
+	for _, l := range lits {
+		if !literalRe.MatchString(l) {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+
+	refs := make([]string, len(sort))
+	ops := make([]string, len(sort))
+	same := true
+	for n, f := range sort {
+		ops[n] = ">"
+		if strings.HasPrefix(f, "-") {
+			f, ops[n] = f[1:], "<"
+		}
+		if err = h.checkField(f, "sort"); err != nil {
+			return nil, nil, err
+		}
+		refs[n] = h.fieldRef(f)
+		same = same && ops[n] == ops[0]
+	}
+	var expr string
+	if same {
+		// a single row value comparison, which can use a matching index
+		expr = "(" + strings.Join(refs, ",") + ") " + ops[0] + " (" + strings.Join(lits, ",") + ")"
+	} else {
+		terms := make([]string, len(sort))
+		for n := range sort {
+			conds := []string{}
+			for k := 0; k < n; k++ {
+				conds = append(conds, refs[k]+" = "+lits[k])
+			}
+			conds = append(conds, refs[n]+" "+ops[n]+" "+lits[n])
+			terms[n] = "(" + strings.Join(conds, " AND ") + ")"
+		}
+		expr = "(" + strings.Join(terms, " OR ") + ")"
+	}
+	return append(append(schema.Query{}, filter...), keysetBound{Expr: expr}), sort, nil
+}
@@ -0,0 +1,92 @@
+package sqlite3
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKeysetPagination(t *testing.T) {
+	Convey("Cursors should translate to a range predicate", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithKeysetPagination(true))
+		i, _ := item("foo", 1)
+		i.ID = "abc"
+		c, err := h.Cursor(i, []string{"f1", "f2"})
+		So(err, ShouldBeNil)
+		f, sort, err := h.keysetFilter(nil, []string{"f1", "f2"}, c)
+		So(err, ShouldBeNil)
+		So(sort, ShouldResemble, []string{"f1", "f2", "id"})
+		s, err := translateQuery(h, f)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1,f2,id) > ('foo',1,'abc')")
+
+		c, err = h.Cursor(i, []string{"f1", "-f2"})
+		So(err, ShouldBeNil)
+		f, _, err = h.keysetFilter(nil, []string{"f1", "-f2"}, c)
+		So(err, ShouldBeNil)
+		s, err = translateQuery(h, f)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "((f1 > 'foo') OR (f1 = 'foo' AND f2 < 1) OR (f1 = 'foo' AND f2 = 1 AND id < 'abc'))")
+	})
+
+	Convey("Cursors which weren't made by Cursor should be rejected", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithKeysetPagination(true))
+		for _, c := range []string{"!", base64.RawURLEncoding.EncodeToString([]byte(`["1"]`)),
+			base64.RawURLEncoding.EncodeToString([]byte(`["1","1) OR (1"]`))} {
+			_, _, err := h.keysetFilter(nil, []string{"f2"}, c)
+			So(err, ShouldEqual, ErrInvalidCursor)
+		}
+	})
+
+	Convey("Given a handler with keyset pagination", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		kh := NewHandler(h.session, DB_TABLE, WithKeysetPagination(true))
+		items := []*resource.Item{}
+		for n, f1 := range []string{"a", "b", "a", "c", "b"} {
+			i, _ := item(f1, n)
+			items = append(items, i)
+		}
+		So(kh.Insert(context.Background(), items), ShouldBeNil)
+
+		Convey("Following cursors should read every item once in order", func() {
+			for _, sort := range [][]string{{"f1"}, {"-f1", "f2"}} {
+				l := resource.NewLookup()
+				l.SetSort(strings.Join(sort, ","), nil)
+				seen := map[interface{}]bool{}
+				cursor := ""
+				for pages := 0; pages < 5; pages++ {
+					list, err := kh.Find(NewCursorContext(context.Background(), cursor), l, 7, 2)
+					So(err, ShouldBeNil)
+					if len(list.Items) == 0 {
+						break
+					}
+					for _, i := range list.Items {
+						So(seen[i.ID], ShouldBeFalse)
+						seen[i.ID] = true
+					}
+					cursor, err = kh.Cursor(list.Items[len(list.Items)-1], l.Sort())
+					So(err, ShouldBeNil)
+				}
+				So(len(seen), ShouldEqual, 5)
+			}
+		})
+
+		Convey("Filters should still apply", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "a"}})
+			list, err := kh.Find(NewCursorContext(context.Background(), ""), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 2)
+		})
+	})
+}
@@ -1,148 +1,493 @@
 package sqlite3
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/rs/rest-layer/resource"
 	"github.com/rs/rest-layer/schema"
-	"time"
+	"math"
 	"strings"
+	"time"
 )
 
 // getQuery returns the WHERE clause when given a Lookup
-func getQuery(l *resource.Lookup) (string, error) {
-	return translateQuery(l.Filter())
+func getQuery(h *Handler, l *resource.Lookup) (string, error) {
+	return translateQuery(h, l.Filter())
 }
 
 // getSort returns the ORDER BY clause when given a Lookup
-func getSort(l *resource.Lookup) string {
-	return translateSort(l.Sort())
+func getSort(h *Handler, l *resource.Lookup) (string, error) {
+	return translateSort(h, l.Sort())
 }
 
-// translateQuery constructs the string representation of the WHERE clause of a SQL query
-func translateQuery(q schema.Query) (string, error) {
-	var str string
-	for _, exp := range q {
+// translateQuery constructs the string representation of the WHERE clause of a SQL query.
+// Top level expressions are combined with AND. The expression tree is walked
+// with an explicit stack rather than recursion, so arbitrarily deep or wide
+// filters are translated in linear time.
+func translateQuery(h *Handler, q schema.Query) (string, error) {
+	if h.optionErr != nil {
+		return "", h.optionErr
+	}
+	var b strings.Builder
+	// stack holds the work left to do, in reverse order: either an expression
+	// to translate or a literal token to write.
+	stack := make([]interface{}, 0, 2*len(q))
+	pushGroup := func(exps []schema.Expression, sep string) {
+		for i := len(exps) - 1; i >= 0; i-- {
+			stack = append(stack, exps[i])
+			if i > 0 {
+				stack = append(stack, sep)
+			}
+		}
+	}
+	pushGroup(q, " AND ")
+	for len(stack) > 0 {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if tok, ok := item.(string); ok {
+			b.WriteString(tok)
+			continue
+		}
+		exp, err := encodeIDFilter(h, item.(schema.Expression))
+		if err != nil {
+			return "", err
+		}
+		if exp, err = encodeTimeFilter(h, exp); err != nil {
+			return "", err
+		}
 		switch t := exp.(type) {
 		case schema.And:
-			var s string
-			for _, subExp := range t {
-				sb, err := translateQuery(schema.Query{subExp})
-				if err != nil {
-					return "", err
-				}
-				s += sb + " AND "
+			if len(t) == 0 {
+				// an empty conjunction is always true
+				b.WriteString("(1)")
+				continue
 			}
-			// remove the last " AND "
-			str += "(" + s[:len(s)-5] + ")"
+			b.WriteString("(")
+			stack = append(stack, ")")
+			pushGroup(t, " AND ")
 		case schema.Or:
-			var s string
-			for _, subExp := range t {
-				sb, err := translateQuery(schema.Query{subExp})
-				if err != nil {
-					return "", err
-				}
-				s += sb + " OR "
+			if len(t) == 0 {
+				// an empty disjunction is always false
+				b.WriteString("(0)")
+				continue
 			}
-			// remove the last " OR "
-			str += "(" + s[:len(s)-4] + ")"
-		case schema.In:
-			v, err := valuesToString(t.Values)
-			if err != nil {
-				return "", resource.ErrNotImplemented
-			}
-			str += t.Field + " IN (" + v + ")"
-		case schema.NotIn:
-			v, err := valuesToString(t.Values)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			b.WriteString("(")
+			stack = append(stack, ")")
+			pushGroup(t, " OR ")
+		default:
+			if err := h.checkExpressionField(exp); err != nil {
+				return "", err
 			}
-			str += t.Field + " NOT IN (" + v + ")"
-		case schema.Equal:
-			v, err := valueToString(t.Value)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			if err := writeExpression(h, &b, exp); err != nil {
+				return "", err
 			}
-			switch t.Value.(type) {
-			case string:
-				v = strings.Replace(v, "*", "%", -1)
-				v = strings.Replace(v, "_", "\\_", -1)
-				str += t.Field + " LIKE " + v + " ESCAPE '\\'"
-			default:
-				str += t.Field + " IS " + v
+		}
+	}
+	return b.String(), nil
+}
+
+// writeExpression writes the SQL for a single non-logical expression.
+func writeExpression(h *Handler, b *strings.Builder, exp schema.Expression) error {
+	if q, ok := h.textSearch(exp); ok {
+		return writeTextSearch(h, b, q)
+	}
+	switch t := exp.(type) {
+	case schema.In:
+		f := h.compareRef(t.Field)
+		values, null := splitNull(t.Values)
+		v, err := valuesToString(values)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		// NULL is never IN a list, it must be matched on its own
+		switch {
+		case !null:
+			b.WriteString(f + " IN (" + v + ")")
+		case len(values) == 0:
+			b.WriteString(f + " IS NULL")
+		default:
+			b.WriteString("(" + f + " IN (" + v + ") OR " + f + " IS NULL)")
+		}
+	case schema.NotIn:
+		f := h.compareRef(t.Field)
+		values, null := splitNull(t.Values)
+		v, err := valuesToString(values)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		// a NULL in the list would make NOT IN match nothing
+		switch {
+		case null && len(values) == 0:
+			b.WriteString(f + " IS NOT NULL")
+		case null:
+			b.WriteString("(" + f + " NOT IN (" + v + ") AND " + f + " IS NOT NULL)")
+		case h.nullMatching:
+			b.WriteString("(" + f + " NOT IN (" + v + ") OR " + f + " IS NULL)")
+		default:
+			b.WriteString(f + " NOT IN (" + v + ")")
+		}
+	case inTable:
+		f := h.compareRef(t.Field)
+		sub := f + " IN (SELECT value FROM " + t.Table + ")"
+		switch {
+		case t.Not && t.Null:
+			sub = "(" + f + " NOT IN (SELECT value FROM " + t.Table + ") AND " + f + " IS NOT NULL)"
+		case t.Not && h.nullMatching:
+			sub = "(" + f + " NOT IN (SELECT value FROM " + t.Table + ") OR " + f + " IS NULL)"
+		case t.Not:
+			sub = f + " NOT IN (SELECT value FROM " + t.Table + ")"
+		case t.Null:
+			sub = "(" + sub + " OR " + f + " IS NULL)"
+		}
+		b.WriteString(sub)
+	case feedBound:
+		b.WriteString(h.feedKeyRef() + " " + t.Op + " " + t.Key)
+	case keysetBound:
+		b.WriteString(t.Expr)
+	case schema.Equal:
+		f := h.fieldRef(t.Field)
+		v, err := valueToString(t.Value)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		switch t.Value.(type) {
+		case string:
+			if isMetaColumn(t.Field) {
+				// etags are compared as is, they are not patterns, with IS
+				// like NotEqual uses IS NOT, so NULL is matched consistently
+				b.WriteString(f + " IS " + v)
+				break
 			}
-		case schema.NotEqual:
-			v, err := valueToString(t.Value)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			if h.equality == EqualityExact || h.collatedEquality(t.Field, t.Value.(string)) {
+				if h.foldFields[t.Field] {
+					v, _ = valueToString(foldCase(t.Value.(string)))
+					f = "lower(" + f + ")"
+				} else {
+					f += h.collate(t.Field)
+				}
+				b.WriteString(f + " = " + v)
+				break
 			}
-			switch t.Value.(type) {
-			case string:
-				v = strings.Replace(v, "*", "%", -1)
-				v = strings.Replace(v, "_", "\\_", -1)
-				str += t.Field + " NOT LIKE " + v + " ESCAPE '\\'"
-			default:
-				str += t.Field + " IS NOT " + v
+			if h.foldFields[t.Field] {
+				v, _ = valueToString(foldPattern(t.Value.(string)))
+				b.WriteString("lower(" + f + ") GLOB " + v)
+				break
 			}
-		case schema.GreaterThan:
-			v, err := valueToString(t.Value)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			v, _ = valueToString(likePattern(t.Value.(string)))
+			b.WriteString(f + " LIKE " + v + " ESCAPE '\\'")
+		default:
+			b.WriteString(f + " IS " + v)
+		}
+	case schema.NotEqual:
+		f := h.fieldRef(t.Field)
+		v, err := valueToString(t.Value)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		switch t.Value.(type) {
+		case string:
+			if isMetaColumn(t.Field) {
+				b.WriteString(f + " IS NOT " + v)
+				break
 			}
-			str += t.Field + " > " + v
-		case schema.GreaterOrEqual:
-			v, err := valueToString(t.Value)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			if h.equality == EqualityExact || h.collatedEquality(t.Field, t.Value.(string)) {
+				if h.foldFields[t.Field] {
+					v, _ = valueToString(foldCase(t.Value.(string)))
+					f = "lower(" + h.fieldRef(t.Field) + ")"
+				} else {
+					f += h.collate(t.Field)
+				}
+				if h.nullMatching {
+					b.WriteString("(" + f + " <> " + v + " OR " + h.fieldRef(t.Field) + " IS NULL)")
+				} else {
+					b.WriteString(f + " <> " + v)
+				}
+				break
 			}
-			str += t.Field + " >= " + v
-		case schema.LowerThan:
-			v, err := valueToString(t.Value)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			if h.foldFields[t.Field] {
+				v, _ = valueToString(foldPattern(t.Value.(string)))
+				if h.nullMatching {
+					b.WriteString("(lower(" + f + ") NOT GLOB " + v + " OR " + f + " IS NULL)")
+				} else {
+					b.WriteString("lower(" + f + ") NOT GLOB " + v)
+				}
+				break
 			}
-			str += t.Field + " < " + v
-		case schema.LowerOrEqual:
-			v, err := valueToString(t.Value)
-			if err != nil {
-				return "", resource.ErrNotImplemented
+			v, _ = valueToString(likePattern(t.Value.(string)))
+			if h.nullMatching {
+				b.WriteString("(" + f + " NOT LIKE " + v + " ESCAPE '\\' OR " + f + " IS NULL)")
+			} else {
+				b.WriteString(f + " NOT LIKE " + v + " ESCAPE '\\'")
 			}
-			str += t.Field + " <= " + v
 		default:
-			return "", resource.ErrNotImplemented
+			b.WriteString(f + " IS NOT " + v)
 		}
+	case schema.GreaterThan:
+		f := h.compareRef(t.Field)
+		v, err := orderLiteral(t.Value)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		b.WriteString(f + " > " + v)
+	case schema.GreaterOrEqual:
+		f := h.compareRef(t.Field)
+		v, err := orderLiteral(t.Value)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		b.WriteString(f + " >= " + v)
+	case schema.LowerThan:
+		f := h.compareRef(t.Field)
+		v, err := orderLiteral(t.Value)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		b.WriteString(f + " < " + v)
+	case schema.LowerOrEqual:
+		f := h.compareRef(t.Field)
+		v, err := orderLiteral(t.Value)
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		b.WriteString(f + " <= " + v)
+	case schema.Exist:
+		f := h.fieldRef(t.Field)
+		b.WriteString(f + " IS NOT NULL")
+	case schema.NotExist:
+		f := h.fieldRef(t.Field)
+		b.WriteString(f + " IS NULL")
+	case Wildcard:
+		f := h.fieldRef(t.Field)
+		if h.foldFields[t.Field] {
+			v, _ := valueToString(foldPattern(t.Value))
+			b.WriteString("lower(" + f + ") GLOB " + v)
+			break
+		}
+		v, _ := valueToString(likePattern(t.Value))
+		b.WriteString(f + " LIKE " + v + " ESCAPE '\\'")
+	case schema.Regex:
+		f := h.fieldRef(t.Field)
+		v, err := valueToString(t.Value.String())
+		if err != nil {
+			return resource.ErrNotImplemented
+		}
+		b.WriteString(f + " REGEXP " + v)
+	default:
+		return resource.ErrNotImplemented
 	}
-	return str, nil
+	return nil
+}
+
+// encodeIDFilter passes the values of comparisons on the id field through the
+// handler's ID codec.
+func encodeIDFilter(h *Handler, exp schema.Expression) (schema.Expression, error) {
+	var err error
+	switch t := exp.(type) {
+	case schema.Equal:
+		if t.Field == "id" {
+			t.Value, err = h.idCodec.Encode(t.Value)
+		}
+		exp = t
+	case schema.NotEqual:
+		if t.Field == "id" {
+			t.Value, err = h.idCodec.Encode(t.Value)
+		}
+		exp = t
+	case schema.In:
+		if t.Field == "id" {
+			t.Values, err = h.encodeIDs(t.Values)
+		}
+		exp = t
+	case schema.NotIn:
+		if t.Field == "id" {
+			t.Values, err = h.encodeIDs(t.Values)
+		}
+		exp = t
+	}
+	if err != nil {
+		return nil, resource.ErrNotImplemented
+	}
+	return exp, nil
+}
+
+// isTimeColumn reports whether a field is stored as a timestamp written by
+// the handler, in its TimeFormat.
+func isTimeColumn(field string) bool {
+	return field == "updated" || field == "created"
+}
+
+// timeValue converts a filter value on a field to its stored value, so times
+// are compared with the stored timestamps. Times are converted to the
+// handler's TimeFormat, in which time.Time payload values are stored too.
+// Strings filtering the updated and created fields are parsed as RFC 3339 or
+// in the stored format first; other values are returned as is.
+func timeValue(h *Handler, field string, v schema.Value) (schema.Value, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return h.timeFormat.value(t), nil
+	case string:
+		if !isTimeColumn(field) {
+			break
+		}
+		tv, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			if tv, err = h.timeFormat.parse(t); err != nil {
+				return nil, resource.ErrNotImplemented
+			}
+		}
+		return h.timeFormat.value(tv), nil
+	}
+	return v, nil
+}
+
+// encodeTimeFilter converts the values of comparisons with timeValue.
+// Timestamps are stored in UTC in a format whose order is their chronological
+// order, so range filters such as updated > t select the items changed since
+// t.
+func encodeTimeFilter(h *Handler, exp schema.Expression) (schema.Expression, error) {
+	var err error
+	switch t := exp.(type) {
+	case schema.Equal:
+		t.Value, err = timeValue(h, t.Field, t.Value)
+		exp = t
+	case schema.NotEqual:
+		t.Value, err = timeValue(h, t.Field, t.Value)
+		exp = t
+	case schema.GreaterThan:
+		t.Value, err = timeValue(h, t.Field, t.Value)
+		exp = t
+	case schema.GreaterOrEqual:
+		t.Value, err = timeValue(h, t.Field, t.Value)
+		exp = t
+	case schema.LowerThan:
+		t.Value, err = timeValue(h, t.Field, t.Value)
+		exp = t
+	case schema.LowerOrEqual:
+		t.Value, err = timeValue(h, t.Field, t.Value)
+		exp = t
+	case schema.In:
+		t.Values, err = timeValues(h, t.Field, t.Values)
+		exp = t
+	case schema.NotIn:
+		t.Values, err = timeValues(h, t.Field, t.Values)
+		exp = t
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+// timeValues converts a list of filter values with timeValue.
+func timeValues(h *Handler, field string, l []schema.Value) ([]schema.Value, error) {
+	out := make([]schema.Value, len(l))
+	for i, v := range l {
+		tv, err := timeValue(h, field, v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = tv
+	}
+	return out, nil
 }
 
 // translateSort constructs the string representation of the ORDER BY clause of a SQL query
-func translateSort(l []string) string {
+func translateSort(h *Handler, l []string) (string, error) {
 	var str string
 	if len(l) == 0 {
-		return "id"
+		return "id", nil
+	}
+	if h.optionErr != nil {
+		return "", h.optionErr
 	}
 	for _, s := range l {
+		desc := false
 		if string([]rune(s)[0]) == "-" {
-			str += s[1:] + " DESC"
-		} else {
-			str += s
+			desc = true
+			s = s[1:]
+		}
+		if err := h.checkField(s, "sort"); err != nil {
+			return "", err
+		}
+		o := h.sorts[s]
+		if o.Collation == "" {
+			o.Collation = h.collations[s]
+		}
+		f := h.fieldRef(s)
+		switch o.Nulls {
+		case NullsFirst:
+			str += f + " IS NULL DESC,"
+		case NullsLast:
+			str += f + " IS NULL,"
+		}
+		str += f
+		if o.Collation != "" {
+			if !identRe.MatchString(o.Collation) {
+				return "", fmt.Errorf("sqlite3: invalid collation for %s: %q", s, o.Collation)
+			}
+			str += " COLLATE " + o.Collation
+		}
+		if desc {
+			str += " DESC"
 		}
 		str += ","
 	}
-	return str[:len(str)-1]
+	return str[:len(str)-1], nil
+}
+
+// splitNull returns the values of a list other than nil, and whether it had
+// nil values.
+func splitNull(values []schema.Value) ([]schema.Value, bool) {
+	rest := make([]schema.Value, 0, len(values))
+	null := false
+	for _, v := range values {
+		if v == nil {
+			null = true
+		} else {
+			rest = append(rest, v)
+		}
+	}
+	return rest, null
+}
+
+// numberValue converts a json.Number, as decoded by a json.Decoder using
+// UseNumber, to an int64, or a float64 if it isn't an integer. Anything else
+// than a number is rejected, so it can't be written in a statement.
+func numberValue(n json.Number) (schema.Value, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	f, err := n.Float64()
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, resource.ErrNotImplemented
+	}
+	return f, nil
+}
+
+// orderLiteral converts the Value of an ordering comparison, which can't be
+// nil: nothing is greater or lower than NULL.
+func orderLiteral(v schema.Value) (string, error) {
+	if v == nil {
+		return "", resource.ErrNotImplemented
+	}
+	return valueToString(v)
 }
 
 // valuesToString combines a list of Values into a single comma separated string
 func valuesToString(v []schema.Value) (string, error) {
-	var str string
-	for _, v := range v {
+	var b strings.Builder
+	for i, v := range v {
 		s, err := valueToString(v)
 		if err != nil {
 			return "", err
 		}
-		str += fmt.Sprintf("%s,", s)
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(s)
 	}
-	return str[:len(str)-1], nil
+	return b.String(), nil
 }
 
 // valueToString converts a Value into a type-specific string
@@ -151,16 +496,38 @@ func valueToString(v schema.Value) (string, error) {
 	var i interface{} = v
 
 	switch i.(type) {
+	case nil:
+		str += "NULL"
 	case int:
 		str += fmt.Sprintf("%v", i)
+	case int64:
+		str += fmt.Sprintf("%v", i)
+	case int8, int16, int32, uint, uint8, uint16, uint32, uint64:
+		str += fmt.Sprintf("%d", i)
 	case float64:
 		str += fmt.Sprintf("%v", i)
-	case bool:
+	case float32:
 		str += fmt.Sprintf("%v", i)
+	case json.Number:
+		n, err := numberValue(i.(json.Number))
+		if err != nil {
+			return "", err
+		}
+		return valueToString(n)
+	case bool:
+		// TRUE and FALSE keywords are only understood by SQLite 3.23+
+		if i.(bool) {
+			str += "1"
+		} else {
+			str += "0"
+		}
 	case string:
-		str += fmt.Sprintf("'%v'", i)
+		// single quotes are escaped by doubling them
+		str += "'" + strings.Replace(i.(string), "'", "''", -1) + "'"
 	case time.Time:
-		str += fmt.Sprintf("'%v'", i)
+		str += "'" + formatTime(i.(time.Time)) + "'"
+	case []byte:
+		str += fmt.Sprintf("X'%x'", i)
 	default:
 		return "", resource.ErrNotImplemented
 	}
@@ -1,23 +1,29 @@
 package sqlite3
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/net/context"
 
 	"github.com/rs/rest-layer/resource"
 	"github.com/rs/rest-layer/schema"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-func callGetQuery(q schema.Query) (string, error) {
+func callGetQuery(q schema.Query, opts ...Option) (string, error) {
 	l := resource.NewLookup()
 	l.AddQuery(q)
-	return getQuery(l)
+	return getQuery(NewHandler(nil, DB_TABLE, opts...), l)
 }
 
-func callGetSort(s string, v schema.Validator) string {
+func callGetSort(s string, v schema.Validator, opts ...Option) (string, error) {
 	l := resource.NewLookup()
 	l.SetSort(s, v)
-	return getSort(l)
+	return getSort(NewHandler(nil, DB_TABLE, opts...), l)
 }
 
 func callGetDelete(h *Handler, q schema.Query) (string, error) {
@@ -32,30 +38,71 @@ func TestLookups(t *testing.T) {
 		// equality and type handling
 		s, err := callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo"}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "f1 LIKE 'foo' ESCAPE '\\'")
+		So(s, ShouldEqual, "f1 = 'foo'")
+
+		// strings are compared exactly
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo*bar%"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 = 'foo*bar%'")
 
 		// _ is not interpreted as a single character wildcard
-		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo_bar"}})
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo_bar"}}, WithEqualityMode(EqualityPattern))
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f1 LIKE 'foo\\_bar' ESCAPE '\\'")
 
 		// * is interpreted as a multicharacter wildcard
-		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo*bar"}})
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo*bar"}}, WithEqualityMode(EqualityPattern))
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f1 LIKE 'foo%bar' ESCAPE '\\'")
 
+		// literal %, _ and \ are escaped
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "100%_off\\*"}}, WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 LIKE '100\\%\\_off\\\\%' ESCAPE '\\'")
+
 		s, err = callGetQuery(schema.Query{schema.Equal{Field: "id", Value: 10}})
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "id IS 10")
 
 		s, err = callGetQuery(schema.Query{schema.Equal{Field: "id", Value: true}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "id IS true")
+		So(s, ShouldEqual, "id IS 1")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "id", Value: false}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "id IS 0")
 
 		s, err = callGetQuery(schema.Query{schema.Equal{Field: "id", Value: 10.01}})
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "id IS 10.01")
 
+		// nil is translated to NULL
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: nil}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IS NULL")
+
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: nil}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IS NOT NULL")
+
+		// existence is the presence of a value in the column
+		s, err = callGetQuery(schema.Query{schema.Exist{Field: "f1"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IS NOT NULL")
+
+		s, err = callGetQuery(schema.Query{schema.NotExist{Field: "f1"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IS NULL")
+
+		// dotted fields read a path of a JSON column
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "address.city", Value: "Paris"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "json_extract(address,'$.city') = 'Paris'")
+
+		s, err = callGetQuery(schema.Query{schema.GreaterThan{Field: "a.b.c", Value: 1}}, WithStorageMode(StorageJSON))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "json_extract(payload,'$.a.b.c') > 1")
+
 		var l = []string{"a", "b"}
 		_, err = callGetQuery(schema.Query{schema.Equal{Field: "id", Value: l}})
 		So(err, ShouldEqual, resource.ErrNotImplemented)
@@ -63,18 +110,36 @@ func TestLookups(t *testing.T) {
 		// inequality
 		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo"}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "f1 NOT LIKE 'foo' ESCAPE '\\'")
+		So(s, ShouldEqual, "f1 <> 'foo'")
 
 		// _ is not interpreted as a single character wildcard
-		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo_bar"}})
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo_bar"}}, WithEqualityMode(EqualityPattern))
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f1 NOT LIKE 'foo\\_bar' ESCAPE '\\'")
 
 		// * is interpreted as a multicharacter wildcard
-		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo*bar"}})
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo*bar"}}, WithEqualityMode(EqualityPattern))
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f1 NOT LIKE 'foo%bar' ESCAPE '\\'")
 
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "50%"}}, WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 NOT LIKE '50\\%' ESCAPE '\\'")
+
+		// negations match NULL columns when null matching is enabled
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo"}}, WithNullMatching(true))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 <> 'foo' OR f1 IS NULL)")
+
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f1", Value: "foo"}}, WithNullMatching(true), WithEqualityMode(EqualityPattern))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 NOT LIKE 'foo' ESCAPE '\\' OR f1 IS NULL)")
+
+		// IS NOT already matches NULL columns
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "f2", Value: 1}}, WithNullMatching(true))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f2 IS NOT 1")
+
 		s, err = callGetQuery(schema.Query{schema.GreaterThan{Field: "f1", Value: 1}})
 		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f1 > 1")
@@ -100,13 +165,17 @@ func TestLookups(t *testing.T) {
 		So(err, ShouldEqual, nil)
 		So(s, ShouldEqual, "id NOT IN ('a','b')")
 
+		s, err = callGetQuery(schema.Query{schema.NotIn{Field: "id", Values: []schema.Value{"a", "b"}}}, WithNullMatching(true))
+		So(err, ShouldEqual, nil)
+		So(s, ShouldEqual, "(id NOT IN ('a','b') OR id IS NULL)")
+
 		// simple logical operators
 		s, err = callGetQuery(schema.Query{schema.And{schema.Equal{Field: "id", Value: 10}, schema.Equal{Field: "f1", Value: "foo"}}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "(id IS 10 AND f1 LIKE 'foo' ESCAPE '\\')")
+		So(s, ShouldEqual, "(id IS 10 AND f1 = 'foo')")
 		s, err = callGetQuery(schema.Query{schema.Or{schema.Equal{Field: "id", Value: 10}, schema.Equal{Field: "f1", Value: "foo"}}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "(id IS 10 OR f1 LIKE 'foo' ESCAPE '\\')")
+		So(s, ShouldEqual, "(id IS 10 OR f1 = 'foo')")
 
 		// compound logical operators
 		s, err = callGetQuery(schema.Query{
@@ -117,7 +186,7 @@ func TestLookups(t *testing.T) {
 					schema.Equal{Field: "id", Value: 10},
 					schema.Equal{Field: "f1", Value: "foo"}}}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "(id IS 10 AND f1 LIKE 'foo' ESCAPE '\\' AND (id IS 10 OR f1 LIKE 'foo' ESCAPE '\\'))")
+		So(s, ShouldEqual, "(id IS 10 AND f1 = 'foo' AND (id IS 10 OR f1 = 'foo'))")
 
 		s, err = callGetQuery(schema.Query{
 			schema.Or{
@@ -127,26 +196,246 @@ func TestLookups(t *testing.T) {
 					schema.Equal{Field: "id", Value: 10},
 					schema.Equal{Field: "f1", Value: "foo"}}}})
 		So(err, ShouldBeNil)
-		So(s, ShouldEqual, "(id IS 10 OR f1 LIKE 'foo' ESCAPE '\\' OR (id IS 10 AND f1 LIKE 'foo' ESCAPE '\\'))")
+		So(s, ShouldEqual, "(id IS 10 OR f1 = 'foo' OR (id IS 10 AND f1 = 'foo'))")
+	})
+
+	Convey("String values should be quoted safely", t, func() {
+		s, err := valueToString("O'Brien")
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "'O''Brien'")
+
+		s, err = valueToString("''")
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "''''''")
+
+		s, err = valueToString("a\\b")
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "'a\\b'")
+
+		s, err = valueToString("100%")
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "'100%'")
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "O'Brien"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 = 'O''Brien'")
+
+		s, err = callGetQuery(schema.Query{schema.In{Field: "f1", Values: []schema.Value{"O'Brien", "it's"}}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IN ('O''Brien','it''s')")
+	})
+
+	Convey("Numeric values of any type should be written as literals", t, func() {
+		for _, c := range []struct {
+			v schema.Value
+			s string
+		}{
+			{int8(-8), "-8"}, {int16(16), "16"}, {int32(32), "32"}, {int64(1 << 40), "1099511627776"},
+			{uint(1), "1"}, {uint8(8), "8"}, {uint16(16), "16"}, {uint32(32), "32"}, {uint64(1 << 63), "9223372036854775808"},
+			{float32(0.5), "0.5"}, {json.Number("42"), "42"}, {json.Number("-1.5"), "-1.5"},
+		} {
+			s, err := valueToString(c.v)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, c.s)
+		}
+
+		_, err := valueToString(json.Number("1; DROP TABLE x"))
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+
+		s, err := callGetQuery(schema.Query{schema.In{Field: "f2", Values: []schema.Value{json.Number("1"), uint64(2), int64(3)}}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f2 IN (1,2,3)")
+	})
+
+	Convey("Meta columns should be filtered by value and timestamp", t, func() {
+		ts := time.Date(2016, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+		s, err := callGetQuery(schema.Query{schema.GreaterThan{Field: "updated", Value: ts}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "updated > '2016-01-02 02:04:05 +0000 UTC'")
+
+		s, err = callGetQuery(schema.Query{schema.LowerOrEqual{Field: "created", Value: "2016-01-02T03:04:05.5+01:00"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "created <= '2016-01-02 02:04:05.5 +0000 UTC'")
+
+		s, err = callGetQuery(schema.Query{schema.In{Field: "updated", Values: []schema.Value{ts}}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "updated IN ('2016-01-02 02:04:05 +0000 UTC')")
+
+		_, err = callGetQuery(schema.Query{schema.GreaterThan{Field: "updated", Value: "yesterday"}})
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "etag", Value: "a_b*"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "etag IS 'a_b*'")
+
+		s, err = callGetQuery(schema.Query{schema.NotEqual{Field: "etag", Value: "a"}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "etag IS NOT 'a'")
+
+		s, err = callGetSort("-updated", schema.Schema{"updated": schema.Field{Sortable: true}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "updated DESC")
+	})
+
+	Convey("Equal and NotEqual on meta columns should split rows with a NULL value", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		_, err = h.session.Exec("UPDATE "+DB_TABLE+" SET etag = NULL WHERE id = ?", i1.ID)
+		So(err, ShouldBeNil)
+		count := func(exp schema.Expression) int {
+			where, err := translateQuery(h, schema.Query{exp})
+			So(err, ShouldBeNil)
+			var n int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM "+DB_TABLE+" WHERE "+where).Scan(&n), ShouldBeNil)
+			return n
+		}
+		So(count(schema.Equal{Field: "etag", Value: i2.ETag}), ShouldEqual, 1)
+		So(count(schema.NotEqual{Field: "etag", Value: i2.ETag}), ShouldEqual, 1)
+		So(count(schema.Equal{Field: "etag", Value: "other"}), ShouldEqual, 0)
+		So(count(schema.NotEqual{Field: "etag", Value: "other"}), ShouldEqual, 2)
+	})
+
+	Convey("Given items updated at different times", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		since := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		old, _ := item("old", 1)
+		old.Updated = since.Add(-time.Second)
+		recent, _ := item("recent", 2)
+		recent.Updated = since.Add(500 * time.Millisecond)
+		later, _ := item("later", 3)
+		later.Updated = since.Add(time.Hour)
+		So(h.Insert(context.Background(), []*resource.Item{old, recent, later}), ShouldBeNil)
+
+		Convey("An updated filter should only find the items changed since", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "updated", Value: since}})
+			l.SetSort("-updated", schema.Schema{"updated": schema.Field{Sortable: true}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 2)
+			So(list.Items[0].ID, ShouldEqual, later.ID)
+			So(list.Items[1].ID, ShouldEqual, recent.ID)
+		})
+
+		Convey("An etag filter should find the item by its exact etag", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "etag", Value: old.ETag}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].ID, ShouldEqual, old.ID)
+		})
 	})
 
 	Convey("Sorts should do the right thing", t, func() {
 		var s string
+		var err error
 		v := schema.Schema{"id": schema.IDField, "f": schema.Field{Sortable: true}}
 
-		s = callGetSort("", v)
+		s, err = callGetSort("", v)
+		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "id")
 
-		s = callGetSort("id", v)
+		s, err = callGetSort("id", v)
+		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "id")
 
-		s = callGetSort("f", v)
+		s, err = callGetSort("f", v)
+		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f")
 
-		s = callGetSort("-f", v)
+		s, err = callGetSort("-f", v)
+		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f DESC")
 
-		s = callGetSort("f,-f", v)
+		s, err = callGetSort("f,-f", v)
+		So(err, ShouldBeNil)
 		So(s, ShouldEqual, "f,f DESC")
 	})
 }
+
+// orQuery returns a filter matching f2 against n values in a single Or.
+func orQuery(n int) schema.Query {
+	or := make(schema.Or, n)
+	for i := range or {
+		or[i] = schema.Equal{Field: "f2", Value: i}
+	}
+	return schema.Query{or}
+}
+
+func TestLargeQueries(t *testing.T) {
+	Convey("Top level expressions should be combined with AND", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "f2", Value: 1}, schema.GreaterThan{Field: "f3", Value: 2}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f2 IS 1 AND f3 > 2")
+	})
+
+	Convey("Empty logical operators should be constant", t, func() {
+		s, err := callGetQuery(schema.Query{schema.And{}, schema.Or{}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(1) AND (0)")
+	})
+
+	Convey("A 1,000 clause OR should be translated", t, func() {
+		s, err := callGetQuery(orQuery(1000))
+		So(err, ShouldBeNil)
+		clauses := make([]string, 1000)
+		for i := range clauses {
+			clauses[i] = fmt.Sprintf("f2 IS %d", i)
+		}
+		So(s, ShouldEqual, "("+strings.Join(clauses, " OR ")+")")
+	})
+
+	Convey("Deeply nested filters should be translated", t, func() {
+		var exp schema.Expression = schema.Equal{Field: "f2", Value: 0}
+		for i := 0; i < 10000; i++ {
+			if i%2 == 0 {
+				exp = schema.And{exp}
+			} else {
+				exp = schema.Or{exp}
+			}
+		}
+		s, err := callGetQuery(schema.Query{exp})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, strings.Repeat("(", 10000)+"f2 IS 0"+strings.Repeat(")", 10000))
+	})
+
+	Convey("Unsupported nested expressions should fail the translation", t, func() {
+		_, err := callGetQuery(schema.Query{schema.Or{schema.Equal{Field: "f2", Value: 0}, schema.Equal{Field: "f2", Value: struct{}{}}}})
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+	})
+}
+
+func BenchmarkTranslateQueryWideOr(b *testing.B) {
+	h := NewHandler(nil, DB_TABLE)
+	q := orQuery(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := translateQuery(h, q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTranslateQueryDeep(b *testing.B) {
+	h := NewHandler(nil, DB_TABLE)
+	var exp schema.Expression = schema.Equal{Field: "f2", Value: 0}
+	for i := 0; i < 1000; i++ {
+		exp = schema.And{exp, schema.Equal{Field: "f3", Value: i}}
+	}
+	q := schema.Query{exp}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := translateQuery(h, q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MaterializedView keeps a real table filled with the result of an expensive
+// query (joins, aggregates), so a handler can serve Find from the copy. The
+// query must return the id, etag, updated and created columns expected by the
+// handler.
+//
+// The copy is refreshed explicitly, on a schedule with RefreshEvery, or lazily
+// by Find after MarkStale was called, e.g. from a change hook on the source
+// tables. Resources bound to the view handler should be read-only, writes go
+// to the copy and are lost on the next refresh.
+type MaterializedView struct {
+	session *sql.DB
+	table   string
+	query   string
+
+	mu        sync.Mutex
+	stale     bool
+	refreshed time.Time
+}
+
+// NewMaterializedView creates a materialized view of the query stored in
+// table. The table is created by the first refresh.
+func NewMaterializedView(s *sql.DB, table, query string) *MaterializedView {
+	return &MaterializedView{
+		session: s,
+		table:   table,
+		query:   query,
+		stale:   true,
+	}
+}
+
+// Handler returns a handler serving the materialized copy. Find refreshes the
+// copy first if it is stale.
+func (v *MaterializedView) Handler(opts ...Option) *Handler {
+	h := NewHandler(v.session, v.table, opts...)
+	h.view = v
+	return h
+}
+
+// Refresh replaces the content of the copy with the current result of the
+// query, in a single transaction.
+func (v *MaterializedView) Refresh(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	txPtr, err := v.session.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting refresh transaction.")
+		return ctxErr(ctx, err)
+	}
+	stmts := []string{
+		"CREATE TABLE IF NOT EXISTS " + v.table + " AS SELECT * FROM (" + v.query + ") WHERE 0;",
+		"DELETE FROM " + v.table + ";",
+		"INSERT INTO " + v.table + " " + v.query + ";",
+	}
+	for _, s := range stmts {
+		if _, err = txPtr.ExecContext(ctx, s); err != nil {
+			txPtr.Rollback()
+			log.WithFields(log.Fields{
+				"table": v.table,
+				"error": err,
+			}).Warn("Error refreshing materialized view.")
+			return ctxErr(ctx, err)
+		}
+	}
+	if err = txPtr.Commit(); err != nil {
+		return err
+	}
+	v.stale = false
+	v.refreshed = time.Now()
+	return nil
+}
+
+// MarkStale flags the copy as outdated, so the next Find refreshes it.
+func (v *MaterializedView) MarkStale() {
+	v.mu.Lock()
+	v.stale = true
+	v.mu.Unlock()
+}
+
+// Refreshed returns the time of the last successful refresh.
+func (v *MaterializedView) Refreshed() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.refreshed
+}
+
+// RefreshIfStale refreshes the copy if it was marked stale.
+func (v *MaterializedView) RefreshIfStale(ctx context.Context) error {
+	v.mu.Lock()
+	stale := v.stale
+	v.mu.Unlock()
+	if !stale {
+		return nil
+	}
+	return v.Refresh(ctx)
+}
+
+// RefreshEvery refreshes the copy at the given interval until the returned
+// function is called. Refresh errors are logged.
+func (v *MaterializedView) RefreshEvery(d time.Duration) (stop func()) {
+	done := make(chan struct{})
+	t := time.NewTicker(d)
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := v.Refresh(context.Background()); err != nil {
+					log.WithField("error", err).Warn("Error in scheduled materialized view refresh.")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,44 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaterializedView(t *testing.T) {
+	Convey("Given a materialized view of the test table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE `viewtable`;")
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		v := NewMaterializedView(h.session, "viewtable", "SELECT * FROM "+DB_TABLE+" WHERE f2 > 0")
+		vh := v.Handler()
+
+		Convey("Find should refresh a stale view", func() {
+			result, err := vh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(v.Refreshed().IsZero(), ShouldBeFalse)
+
+			Convey("and serve the copy until it is marked stale", func() {
+				So(h.Insert(context.Background(), []*resource.Item{i2}), ShouldBeNil)
+				result, err := vh.Find(context.Background(), resource.NewLookup(), 1, 10)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, 1)
+
+				v.MarkStale()
+				result, err = vh.Find(context.Background(), resource.NewLookup(), 1, 10)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, 2)
+			})
+		})
+	})
+}
@@ -0,0 +1,40 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/schema"
+)
+
+// memoryDBs numbers the in-memory databases, so each one is distinct.
+var memoryDBs int64
+
+// NewMemoryHandler opens a new in-memory database, creates the table of the
+// schema with EnsureTable, and returns a handler on it, for tests and
+// ephemeral APIs. The connections of the pool share the database through
+// SQLite's shared cache; it is dropped with the last connection, when the
+// pool, returned by DB, is closed or if it keeps no idle connections.
+// Each call opens a distinct database: use DB to create handlers on other
+// tables of the same database.
+func NewMemoryHandler(table string, s schema.Schema, opts ...Option) (*Handler, error) {
+	n := atomic.AddInt64(&memoryDBs, 1)
+	db, err := sql.Open(DriverName, fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", n))
+	if err != nil {
+		return nil, err
+	}
+	h := NewHandler(db, table, opts...)
+	if err = h.EnsureTable(context.Background(), s); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// DB returns the database of the handler.
+func (h *Handler) DB() *sql.DB {
+	return h.session
+}
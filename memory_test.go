@@ -0,0 +1,46 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemoryHandler(t *testing.T) {
+	s := schema.Schema{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"f1":      schema.Field{Validator: &schema.String{}},
+		"f2":      schema.Field{Validator: &schema.Integer{}},
+	}
+
+	Convey("Given an in-memory handler", t, func() {
+		h, err := NewMemoryHandler(DB_TABLE, s)
+		So(err, ShouldBeNil)
+		defer h.DB().Close()
+		i1, _ := item("foo", 1)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		Convey("Items should be found on any connection of the pool", func() {
+			h.DB().SetMaxOpenConns(4)
+			for i := 0; i < 4; i++ {
+				list, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+				So(err, ShouldBeNil)
+				So(list.Items, ShouldHaveLength, 1)
+			}
+		})
+
+		Convey("Other in-memory handlers should have their own database", func() {
+			other, err := NewMemoryHandler(DB_TABLE, s)
+			So(err, ShouldBeNil)
+			defer other.DB().Close()
+			list, err := other.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+const (
+	// MetaTable is the name of the table describing resources and their fields.
+	// SQLite has no COMMENT support, so this table stands in for it.
+	MetaTable = "_meta"
+
+	// META_UP_DDL creates the metadata table.
+	META_UP_DDL = "CREATE TABLE IF NOT EXISTS `" + MetaTable + "` (`resource` VARCHAR(128) NOT NULL,`field` VARCHAR(128) NOT NULL,`description` TEXT,`required` INTEGER,`filterable` INTEGER,`sortable` INTEGER,`version` INTEGER,PRIMARY KEY (`resource`,`field`));"
+)
+
+// MetaEntry describes a resource field recorded in the metadata table. The
+// resource itself is recorded with an empty Field.
+type MetaEntry struct {
+	Resource    string
+	Field       string
+	Description string
+	Required    bool
+	Filterable  bool
+	Sortable    bool
+	Version     int
+}
+
+// CreateMetaTable creates the metadata table if it does not exist yet.
+func CreateMetaTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, META_UP_DDL)
+	if err != nil {
+		log.WithField("error", err).Warn("Error creating meta table.")
+	}
+	return ctxErr(ctx, err)
+}
+
+// WriteMeta replaces the metadata recorded for the handler's table with a
+// description of the provided schema at the given version.
+func (h *Handler) WriteMeta(ctx context.Context, version int, s schema.Schema, description string) error {
+	if err := CreateMetaTable(ctx, h.session); err != nil {
+		return err
+	}
+
+	txPtr, err := h.session.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting meta transaction.")
+		return ctxErr(ctx, err)
+	}
+
+	_, err = txPtr.ExecContext(ctx, "DELETE FROM "+MetaTable+" WHERE resource = ?", h.tableName)
+	if err != nil {
+		txPtr.Rollback()
+		log.WithField("error", err).Warn("Error removing old meta entries.")
+		return err
+	}
+
+	entries := []MetaEntry{{Resource: h.tableName, Description: description, Version: version}}
+	entries = append(entries, metaEntries(h.tableName, version, s)...)
+	for _, e := range entries {
+		_, err = txPtr.ExecContext(ctx, "INSERT INTO "+MetaTable+"(resource,field,description,required,filterable,sortable,version) VALUES(?,?,?,?,?,?,?)",
+			e.Resource, e.Field, e.Description, e.Required, e.Filterable, e.Sortable, e.Version)
+		if err != nil {
+			txPtr.Rollback()
+			log.WithFields(log.Fields{
+				"field": e.Field,
+				"error": err,
+			}).Warn("Error writing meta entry.")
+			return err
+		}
+	}
+	return txPtr.Commit()
+}
+
+// Meta returns the metadata recorded for the handler's table.
+func (h *Handler) Meta(ctx context.Context) ([]MetaEntry, error) {
+	return ReadMeta(ctx, h.session, h.tableName)
+}
+
+// ReadMeta returns the metadata recorded for a resource, or for all resources
+// if resource is empty. Entries are ordered by resource then field.
+func ReadMeta(ctx context.Context, db *sql.DB, resource string) ([]MetaEntry, error) {
+	q := "SELECT resource,field,description,required,filterable,sortable,version FROM " + MetaTable
+	args := []interface{}{}
+	if resource != "" {
+		q += " WHERE resource = ?"
+		args = append(args, resource)
+	}
+	q += " ORDER BY resource,field;"
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		log.WithField("error", err).Warn("Error querying meta table.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	entries := []MetaEntry{}
+	for rows.Next() {
+		var e MetaEntry
+		var desc sql.NullString
+		err = rows.Scan(&e.Resource, &e.Field, &desc, &e.Required, &e.Filterable, &e.Sortable, &e.Version)
+		if err != nil {
+			log.WithField("error", err).Warn("Error scanning meta entry.")
+			return nil, err
+		}
+		e.Description = desc.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// metaEntries describes each field of a schema, ordered by field name.
+func metaEntries(resource string, version int, s schema.Schema) []MetaEntry {
+	names := make([]string, 0, len(s))
+	for n := range s {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	entries := make([]MetaEntry, 0, len(names))
+	for _, n := range names {
+		f := s[n]
+		entries = append(entries, MetaEntry{
+			Resource:    resource,
+			Field:       n,
+			Description: f.Description,
+			Required:    f.Required,
+			Filterable:  f.Filterable,
+			Sortable:    f.Sortable,
+			Version:     version,
+		})
+	}
+	return entries
+}
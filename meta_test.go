@@ -0,0 +1,60 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMeta(t *testing.T) {
+	Convey("Given a handler and a schema", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE `" + MetaTable + "`;")
+		s := schema.Schema{
+			"id": schema.IDField,
+			"f1": schema.Field{Description: "first field", Filterable: true, Sortable: true},
+			"f2": schema.Field{Required: true},
+		}
+
+		Convey("WriteMeta should record the resource and its fields", func() {
+			err := h.WriteMeta(context.Background(), 1, s, "test resource")
+			So(err, ShouldBeNil)
+
+			entries, err := h.Meta(context.Background())
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 4)
+			So(entries[0], ShouldResemble, MetaEntry{Resource: DB_TABLE, Description: "test resource", Version: 1})
+			So(entries[1].Field, ShouldEqual, "f1")
+			So(entries[1].Description, ShouldEqual, "first field")
+			So(entries[1].Filterable, ShouldBeTrue)
+			So(entries[1].Sortable, ShouldBeTrue)
+			So(entries[2].Field, ShouldEqual, "f2")
+			So(entries[2].Required, ShouldBeTrue)
+			So(entries[3].Field, ShouldEqual, "id")
+
+			Convey("Writing a new version should replace the old entries", func() {
+				delete(s, "f2")
+				err := h.WriteMeta(context.Background(), 2, s, "test resource")
+				So(err, ShouldBeNil)
+
+				entries, err := ReadMeta(context.Background(), h.session, "")
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 3)
+				for _, e := range entries {
+					So(e.Version, ShouldEqual, 2)
+				}
+			})
+
+			Convey("Meta should stop when the context is done", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				_, err := h.Meta(ctx)
+				So(err, ShouldEqual, context.Canceled)
+			})
+		})
+	})
+}
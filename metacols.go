@@ -0,0 +1,81 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetaCollision sets how payload fields named like the etag and updated meta
+// columns are stored. Without a rule, such fields would be written twice in
+// the same statement.
+//
+// The created field is not a meta column: it is part of the payload and
+// stored in its own column.
+type MetaCollision int
+
+const (
+	// MetaIgnore drops colliding payload fields on write, the meta columns
+	// win. rest-layer's schema.UpdatedField holds the same value as
+	// Item.Updated, so ignoring it loses nothing. This is the default.
+	MetaIgnore MetaCollision = iota
+	// MetaReject fails writes of items with colliding payload fields.
+	MetaReject
+	// MetaNamespace stores colliding payload fields in columns prefixed with
+	// MetaNamespacePrefix (e.g. _etag), and reads them back under their own
+	// name. The table must have the prefixed columns.
+	MetaNamespace
+)
+
+// MetaNamespacePrefix prefixes the columns of namespaced payload fields.
+const MetaNamespacePrefix = "_"
+
+// metaColumns are the columns written from the item rather than its payload.
+var metaColumns = []string{"etag", "updated"}
+
+// WithMetaCollision sets how payload fields colliding with meta columns are
+// stored.
+func WithMetaCollision(m MetaCollision) Option {
+	return func(h *Handler) {
+		h.metaCollision = m
+	}
+}
+
+// isMetaColumn reports whether a payload field collides with a meta column.
+func isMetaColumn(field string) bool {
+	for _, c := range metaColumns {
+		if field == c {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadColumn returns the column storing a payload field. skip is true when
+// the field must not be written.
+func (h *Handler) payloadColumn(field string) (col string, skip bool, err error) {
+	if !isMetaColumn(field) {
+		return h.column(field), false, nil
+	}
+	switch h.metaCollision {
+	case MetaReject:
+		return "", false, fmt.Errorf("sqlite3: payload field %q collides with the %s meta column", field, field)
+	case MetaNamespace:
+		return MetaNamespacePrefix + field, false, nil
+	}
+	return "", true, nil
+}
+
+// restoreNamespaced moves the values of namespaced payload fields of a row
+// back under their own name. The meta columns must have been removed from the
+// row first.
+func (h *Handler) restoreNamespaced(row map[string]interface{}) {
+	if h.metaCollision != MetaNamespace {
+		return
+	}
+	for k, v := range row {
+		if f := strings.TrimPrefix(k, MetaNamespacePrefix); f != k && isMetaColumn(f) {
+			delete(row, k)
+			row[f] = v
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetaCollision(t *testing.T) {
+	it, _ := resource.NewItem(map[string]interface{}{"id": "1", "etag": "mine", "f1": "foo"})
+	it.ETag = "e"
+	upd, _ := valueToString(it.Updated)
+
+	Convey("Colliding fields should be ignored by default", t, func() {
+		h := NewHandler(nil, DB_TABLE)
+		s, err := getInsert(h, it)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "INSERT INTO testtable(etag,updated,f1,id) VALUES('e',"+upd+",'foo','1');")
+	})
+
+	Convey("Colliding fields should be rejected when asked to", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithMetaCollision(MetaReject))
+		_, err := getInsert(h, it)
+		So(err, ShouldNotBeNil)
+		_, err = getUpdate(h, it, it)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Colliding fields should be namespaced when asked to", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		_, err = h.session.Exec("ALTER TABLE " + DB_TABLE + " ADD COLUMN `_etag` VARCHAR(128);")
+		So(err, ShouldBeNil)
+
+		nh := NewHandler(h.session, DB_TABLE, WithMetaCollision(MetaNamespace))
+		s, err := getInsert(nh, it)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "INSERT INTO testtable(etag,updated,_etag,f1,id) VALUES('e',"+upd+",'mine','foo','1');")
+
+		i, _ := item("foo", 1)
+		i.Payload["etag"] = "mine"
+		So(nh.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "id", Value: i.ID}})
+		result, err := nh.Find(context.Background(), l, 1, 1)
+		So(err, ShouldBeNil)
+		So(result.Items[0].ETag, ShouldEqual, i.ETag)
+		So(result.Items[0].Payload["etag"], ShouldEqual, "mine")
+		_, found := result.Items[0].Payload["_etag"]
+		So(found, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,36 @@
+package sqlite3
+
+import (
+	"time"
+)
+
+// Collector receives measures of the handler operations, to export them to a
+// monitoring system. See PrometheusCollector. Collectors are called
+// synchronously and must be safe for concurrent use.
+type Collector interface {
+	// Observe records an operation on a table that took d and returned or
+	// changed rows items. err is the error returned by the operation, if any.
+	Observe(table string, op Operation, d time.Duration, rows int, err error)
+	// Retry records the retry of a transaction of an operation.
+	Retry(table string, op Operation)
+}
+
+// WithCollector sets the collector receiving the measures of the handler
+// operations.
+func WithCollector(c Collector) Option {
+	return func(h *Handler) {
+		h.collector = c
+	}
+}
+
+// observe reports an operation started at start to the collector. Failed
+// operations are reported with no rows.
+func (h *Handler) observe(op Operation, start time.Time, rows int, err error) {
+	if h.collector == nil {
+		return
+	}
+	if err != nil {
+		rows = 0
+	}
+	h.collector.Observe(h.tableName, op, time.Since(start), rows, err)
+}
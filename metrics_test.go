@@ -0,0 +1,80 @@
+package sqlite3
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// observation is an operation recorded by recordingCollector.
+type observation struct {
+	table string
+	op    Operation
+	rows  int
+	err   error
+}
+
+type recordingCollector struct {
+	mu      sync.Mutex
+	obs     []observation
+	retries int
+}
+
+func (c *recordingCollector) Observe(table string, op Operation, d time.Duration, rows int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.obs = append(c.obs, observation{table, op, rows, err})
+}
+
+func (c *recordingCollector) Retry(table string, op Operation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retries++
+}
+
+func TestCollector(t *testing.T) {
+	Convey("The Prometheus collector should be a Prometheus collector", t, func() {
+		var c prometheus.Collector = NewPrometheusCollector("sqlite3")
+		So(c, ShouldNotBeNil)
+	})
+
+	Convey("Given a handler with a collector", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		c := &recordingCollector{}
+		mh := NewHandler(h.session, DB_TABLE, WithCollector(c))
+		ctx := context.Background()
+
+		Convey("Operations should be observed with their rows and errors", func() {
+			i1, _ := item("foo", 1)
+			i2, _ := item("bar", 2)
+			So(mh.Insert(ctx, []*resource.Item{i1, i2}), ShouldBeNil)
+			_, err := mh.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(mh.Delete(ctx, i1), ShouldBeNil)
+			So(mh.Delete(ctx, i1), ShouldEqual, resource.ErrNotFound)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "bar"}})
+			_, err = mh.Clear(ctx, l)
+			So(err, ShouldBeNil)
+
+			So(c.obs, ShouldResemble, []observation{
+				{DB_TABLE, OpInsert, 2, nil},
+				{DB_TABLE, OpFind, 2, nil},
+				{DB_TABLE, OpDelete, 1, nil},
+				{DB_TABLE, OpDelete, 0, resource.ErrNotFound},
+				{DB_TABLE, OpClear, 1, nil},
+			})
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package sqlite3
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// MigrateOptions configures Migrate.
+type MigrateOptions struct {
+	// DryRun only returns the planned statements, without running them.
+	DryRun bool
+}
+
+// Migrate brings the handler's table up to date with the schema: the table is
+// created if it doesn't exist, and a column is added for each schema field
+// the table lacks, with the type TableDDL would give it, as well as the extra
+// column of hybrid storage. With StorageJSON, only the table is created.
+// Columns are never dropped or altered, use CheckSchema to find the other
+// differences. The statements are run in a single transaction and returned,
+// in the order they are run.
+func (h *Handler) Migrate(ctx context.Context, s schema.Schema, o MigrateOptions) ([]string, error) {
+	if err := h.ops.begin(); err != nil {
+		return nil, err
+	}
+	defer h.ops.end()
+
+	cols, err := columnTypes(ctx, h.conn(), h.tableName)
+	if err != nil {
+		return nil, err
+	}
+	stmts := []string{}
+	if len(cols) == 0 {
+		stmts = append(stmts, tableDDL(h, s))
+	} else if h.storage != StorageJSON {
+		missing := map[string]string{}
+		for name, f := range s {
+			col, skip, err := h.payloadColumn(name)
+			if skip || err != nil {
+				continue
+			}
+			if _, found := cols[col]; !found {
+				missing[col] = h.columnType(f)
+			}
+		}
+		if _, found := cols[ExtraColumn]; !found && h.storage == StorageHybrid {
+			missing[ExtraColumn] = "TEXT"
+		}
+		for _, col := range sortedColumns(missing) {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s;", h.tableName, col, missing[col]))
+		}
+	}
+	for _, stmt := range stmts {
+		log.WithFields(log.Fields{
+			"table":  h.tableName,
+			"dryrun": o.DryRun,
+		}).Info("Migration: " + stmt)
+	}
+	if o.DryRun || len(stmts) == 0 {
+		return stmts, nil
+	}
+
+	txPtr, err := h.beginTx(ctx)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting migration transaction.")
+		return nil, ctxErr(ctx, err)
+	}
+	for _, stmt := range stmts {
+		if _, err = txPtr.ExecContext(ctx, stmt); err != nil {
+			txPtr.Rollback()
+			log.WithFields(log.Fields{
+				"table": h.tableName,
+				"error": err,
+			}).Warn("Error running migration.")
+			return nil, ctxErr(ctx, err)
+		}
+	}
+	return stmts, ctxErr(ctx, txPtr.Commit())
+}
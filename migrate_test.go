@@ -0,0 +1,66 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMigrate(t *testing.T) {
+	s := schema.Schema{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"f1":      schema.Field{Validator: &schema.String{}},
+		"f2":      schema.Field{Validator: &schema.Integer{}},
+		"f3":      schema.Field{Validator: &schema.Bool{}},
+		"f4":      schema.Field{Validator: &schema.String{MaxLen: 20}},
+	}
+
+	Convey("Given a table lacking schema fields", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		planned := []string{
+			"ALTER TABLE `testtable` ADD COLUMN `f3` INTEGER;",
+			"ALTER TABLE `testtable` ADD COLUMN `f4` VARCHAR(20);",
+		}
+
+		Convey("A dry run should only plan the new columns", func() {
+			stmts, err := h.Migrate(context.Background(), s, MigrateOptions{DryRun: true})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldResemble, planned)
+			drifts, err := h.CheckSchema(context.Background(), s)
+			So(err, ShouldBeNil)
+			So(len(drifts), ShouldEqual, 2)
+		})
+
+		Convey("Migrate should add the new columns", func() {
+			stmts, err := h.Migrate(context.Background(), s, MigrateOptions{})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldResemble, planned)
+			drifts, err := h.CheckSchema(context.Background(), s)
+			So(err, ShouldBeNil)
+			So(drifts, ShouldBeEmpty)
+
+			stmts, err = h.Migrate(context.Background(), s, MigrateOptions{})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldBeEmpty)
+		})
+
+		Convey("Migrate should create a missing table", func() {
+			h.session.Exec("DROP TABLE `migrated`;")
+			mh := NewHandler(h.session, "migrated")
+			stmts, err := mh.Migrate(context.Background(), s, MigrateOptions{})
+			So(err, ShouldBeNil)
+			So(stmts, ShouldResemble, []string{TableDDL("migrated", s)})
+			drifts, err := mh.CheckSchema(context.Background(), s)
+			So(err, ShouldBeNil)
+			So(drifts, ShouldBeEmpty)
+		})
+	})
+}
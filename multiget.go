@@ -0,0 +1,42 @@
+package sqlite3
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// MultiGet retrieves the items with the given ids with a single statement,
+// instead of a Find per id when rest-layer embeds references. The items are
+// returned in the order of ids, with a nil item for ids which aren't found.
+func (h *Handler) MultiGet(ctx context.Context, ids []interface{}) ([]*resource.Item, error) {
+	items := make([]*resource.Item, len(ids))
+	if len(ids) == 0 {
+		return items, nil
+	}
+	values := make([]schema.Value, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.In{Field: "id", Values: values}})
+	list, err := h.Find(ctx, l, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// ids are matched through their literal, so they compare as stored
+	found := make(map[string]*resource.Item, len(list.Items))
+	for _, item := range list.Items {
+		if lit, err := h.idLiteral(item.ID); err == nil {
+			found[lit] = item
+		}
+	}
+	for i, id := range ids {
+		if lit, err := h.idLiteral(id); err == nil {
+			items[i] = found[lit]
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,40 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMultiGet(t *testing.T) {
+	Convey("Given a handler with stored items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		var _ resource.MultiGetter = h
+		i1, _ := item("foo", 1)
+		i2, _ := item("bar", 2)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+
+		Convey("MultiGet should return the items in the order of the ids", func() {
+			items, err := h.MultiGet(context.Background(), []interface{}{i2.ID, "missing", i1.ID, i2.ID})
+			So(err, ShouldBeNil)
+			So(items, ShouldHaveLength, 4)
+			So(items[0].ID, ShouldEqual, i2.ID)
+			So(items[1], ShouldBeNil)
+			So(items[2].ID, ShouldEqual, i1.ID)
+			So(items[3].ID, ShouldEqual, i2.ID)
+		})
+
+		Convey("MultiGet without ids should return no items", func() {
+			items, err := h.MultiGet(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(items, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNulls(t *testing.T) {
+	Convey("nil list values should be matched with IS NULL", t, func() {
+		s, err := callGetQuery(schema.Query{schema.In{Field: "f1", Values: []schema.Value{"a", nil}}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 IN ('a') OR f1 IS NULL)")
+
+		s, err = callGetQuery(schema.Query{schema.In{Field: "f1", Values: []schema.Value{nil}}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IS NULL")
+
+		s, err = callGetQuery(schema.Query{schema.NotIn{Field: "f1", Values: []schema.Value{"a", nil}}}, WithNullMatching(true))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 NOT IN ('a') AND f1 IS NOT NULL)")
+
+		s, err = callGetQuery(schema.Query{schema.NotIn{Field: "f1", Values: []schema.Value{nil}}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 IS NOT NULL")
+
+		s, err = callGetQuery(schema.Query{inTable{Field: "f1", Table: "temp._in_0", Null: true}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "(f1 IN (SELECT value FROM temp._in_0) OR f1 IS NULL)")
+	})
+
+	Convey("Ordering comparisons with nil should not be supported", t, func() {
+		_, err := callGetQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: nil}})
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+		_, err = callGetQuery(schema.Query{schema.LowerOrEqual{Field: "f2", Value: nil}})
+		So(err, ShouldEqual, resource.ErrNotImplemented)
+	})
+
+	Convey("Given stored items with NULL columns", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		_, err = h.session.Exec("UPDATE "+DB_TABLE+" SET updated = NULL, created = NULL, f1 = NULL WHERE id = ?", i1.ID)
+		So(err, ShouldBeNil)
+
+		Convey("Find should read them without error", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: nil}})
+			list, err := h.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, i1.ID)
+			So(list.Items[0].Updated.IsZero(), ShouldBeTrue)
+			So(list.Items[0].Payload["f1"], ShouldBeNil)
+		})
+
+		Convey("NullExplicit handlers should omit the NULL fields", func() {
+			nh := NewHandler(h.session, DB_TABLE, WithNullMode(NullExplicit))
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.In{Field: "f1", Values: []schema.Value{nil}}})
+			list, err := nh.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			_, ok := list.Items[0].Payload["f1"]
+			So(ok, ShouldBeFalse)
+			_, ok = list.Items[0].Payload["created"]
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"os"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// Resource describes a table created by Open.
+type Resource struct {
+	Table       string
+	Schema      schema.Schema
+	Description string
+	// Indexes configures the indexes created with EnsureIndexes.
+	Indexes IndexOptions
+}
+
+// Bootstrap describes the initial content of a database created by Open.
+type Bootstrap struct {
+	// Pragmas are applied before the tables are created. Pragmas stored in
+	// the database file (e.g. journal_mode=WAL) persist; per-connection
+	// pragmas (e.g. foreign_keys) must also be set on the handlers with
+	// WithPragma.
+	Pragmas []Pragma
+	// Resources are the tables to create, in order: referenced tables must
+	// come before the tables referencing them.
+	Resources []Resource
+	// Version is the schema version recorded in the meta table.
+	Version int
+	// Driver is the name of the driver to open the database with, e.g. one
+	// registered with RegisterDriver. Empty means DriverName.
+	Driver string
+}
+
+// Open opens the SQLite database at path. If the file does not exist, it is
+// created and bootstrapped: the pragmas are applied, the resource tables are
+// created with EnsureTable and their indexes with EnsureIndexes, and their
+// schema recorded in the meta table at the bootstrap version. If bootstrapping
+// fails, the new file is removed so the next call
+// starts over. Existing files are opened as they are.
+func Open(ctx context.Context, path string, b Bootstrap) (*sql.DB, error) {
+	_, err := os.Stat(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := sql.Open(driverName(b.Driver), path)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return db, nil
+	}
+	if err = bootstrap(ctx, db, b); err != nil {
+		db.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return db, nil
+}
+
+// bootstrap applies the bootstrap to a new database.
+func bootstrap(ctx context.Context, db *sql.DB, b Bootstrap) error {
+	c, err := db.Conn(ctx)
+	if err != nil {
+		log.WithField("error", err).Warn("Error opening connection.")
+		return err
+	}
+	// the connection goes back to the pool with the pragmas applied
+	err = applyPragmas(ctx, c, b.Pragmas)
+	c.Close()
+	if err != nil {
+		return err
+	}
+	for _, r := range b.Resources {
+		h := NewHandler(db, r.Table)
+		if err = h.EnsureTable(ctx, r.Schema); err != nil {
+			return err
+		}
+		if _, err = h.EnsureIndexes(ctx, r.Schema, r.Indexes); err != nil {
+			return err
+		}
+		if err = h.WriteMeta(ctx, b.Version, r.Schema, r.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadOnlyOptions configures a pool opened by OpenReadOnly.
+type ReadOnlyOptions struct {
+	// MaxConns bounds the number of connections of the pool, so analytical
+	// queries can't use up the resources of the process. 0 means 1.
+	MaxConns int
+	// Immutable tells SQLite the file can't change while it is open, which
+	// disables locking and change detection. It is only safe on files no
+	// other process or connection writes to, e.g. a copy or backup of the
+	// live database: reading a file changing underneath returns incorrect
+	// results or errors.
+	Immutable bool
+	// Driver is the name of the driver to open the database with, e.g. one
+	// registered with RegisterDriver. Empty means DriverName.
+	Driver string
+}
+
+// OpenReadOnly opens a separate, read-only pool on the SQLite database at
+// path, for heavy analytical queries and exports. Running them on their own
+// pool keeps big scans from holding the connections serving API requests.
+// Handlers created on the returned pool can be used for Find; their writes
+// fail.
+func OpenReadOnly(path string, o ReadOnlyOptions) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	dsn := "file:" + path + "?mode=ro"
+	if o.Immutable {
+		dsn += "&immutable=1"
+	}
+	db, err := sql.Open(driverName(o.Driver), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if o.MaxConns <= 0 {
+		o.MaxConns = 1
+	}
+	db.SetMaxOpenConns(o.MaxConns)
+	db.SetMaxIdleConns(o.MaxConns)
+	return db, nil
+}
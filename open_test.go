@@ -0,0 +1,121 @@
+package sqlite3
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOpen(t *testing.T) {
+	const file = "./open_test.db"
+	users := schema.Schema{
+		"id":   schema.IDField,
+		"name": schema.Field{Validator: &schema.String{MaxLen: 150}},
+		"mail": schema.Field{Validator: &schema.String{MaxLen: 150}, Filterable: true},
+	}
+	b := Bootstrap{
+		Pragmas:   []Pragma{{Name: "journal_mode", Value: "WAL"}},
+		Resources: []Resource{{Table: "users", Schema: users, Description: "Users", Indexes: IndexOptions{Unique: []string{"mail"}}}},
+		Version:   3,
+	}
+
+	Convey("Given no database file", t, func() {
+		os.Remove(file)
+		Reset(func() {
+			os.Remove(file)
+			os.Remove(file + "-wal")
+			os.Remove(file + "-shm")
+		})
+
+		Convey("Open should create and bootstrap it", func() {
+			db, err := Open(context.Background(), file, b)
+			So(err, ShouldBeNil)
+			defer db.Close()
+
+			var mode string
+			So(db.QueryRow("PRAGMA journal_mode;").Scan(&mode), ShouldBeNil)
+			So(mode, ShouldEqual, "wal")
+
+			h := NewHandler(db, "users")
+			it, _ := resource.NewItem(map[string]interface{}{"id": "1", "created": formatTime(time.Now()), "name": "jane"})
+			So(h.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+
+			meta, err := h.Meta(context.Background())
+			So(err, ShouldBeNil)
+			So(len(meta), ShouldBeGreaterThan, 0)
+			So(meta[0].Version, ShouldEqual, 3)
+
+			// read with PRAGMA index_list
+			idx, err := tableIndexes(context.Background(), db, "users")
+			So(err, ShouldBeNil)
+			So(idx, ShouldContain, map[string]interface{}{"name": "users_idx_mail", "unique": true})
+
+			Convey("and open it as it is afterwards", func() {
+				db.Close()
+				b := b
+				b.Resources = []Resource{{Table: "other", Schema: users}}
+				db, err := Open(context.Background(), file, b)
+				So(err, ShouldBeNil)
+				defer db.Close()
+				result, err := NewHandler(db, "users").Find(context.Background(), resource.NewLookup(), 1, 10)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, 1)
+				_, err = db.Exec("SELECT * FROM other;")
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("Open should remove the file when the bootstrap fails", func() {
+			b := b
+			b.Pragmas = []Pragma{{Name: "journal_mode", Value: "WAL; DROP"}}
+			_, err := Open(context.Background(), file, b)
+			So(err, ShouldNotBeNil)
+			_, err = os.Stat(file)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	Convey("Given the test database", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		Convey("A read-only pool should read but not write", func() {
+			db, err := OpenReadOnly(DB_FILE, ReadOnlyOptions{MaxConns: 2})
+			So(err, ShouldBeNil)
+			defer db.Close()
+			So(db.Stats().MaxOpenConnections, ShouldEqual, 2)
+
+			rh := NewHandler(db, DB_TABLE)
+			result, err := rh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(rh.Insert(context.Background(), []*resource.Item{i2}), ShouldNotBeNil)
+		})
+
+		Convey("An immutable pool should read", func() {
+			db, err := OpenReadOnly(DB_FILE, ReadOnlyOptions{Immutable: true})
+			So(err, ShouldBeNil)
+			defer db.Close()
+			result, err := NewHandler(db, DB_TABLE).Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+		})
+
+		Convey("A missing file should not be created", func() {
+			_, err := OpenReadOnly("./missing.db", ReadOnlyOptions{})
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package sqlite3
+
+// Option configures optional Handler behavior. Options are passed to
+// NewHandler.
+type Option func(*Handler)
+
+// DefaultNullMatching is the null matching behavior of handlers created without
+// the WithNullMatching option.
+var DefaultNullMatching = false
+
+// DefaultCountTotal is the total counting behavior of handlers created without
+// the WithCountTotal option.
+var DefaultCountTotal = true
+
+// DefaultInListThreshold is the In list size above which handlers load the
+// values into a temporary table.
+const DefaultInListThreshold = 500
+
+// NullMode controls how the handler distinguishes a field absent from a
+// payload from a field explicitly set to null. Zero values (0, "", false) are
+// always stored as such.
+type NullMode int
+
+const (
+	// NullIgnore leaves the columns of fields absent from an updated item
+	// untouched, and returns NULL columns as nil payload values. This is the
+	// default.
+	NullIgnore NullMode = iota
+	// NullExplicit sets the columns of fields removed from an item to NULL on
+	// Update, and omits NULL columns from the payloads returned by Find, so a
+	// PATCH clearing a field reads back as an absent field.
+	NullExplicit
+)
+
+// WithNullMode sets how absent and null payload fields are stored and read.
+func WithNullMode(m NullMode) Option {
+	return func(h *Handler) {
+		h.nullMode = m
+	}
+}
+
+// WithNullMatching sets whether negated filters (NotEqual, NotIn) also match
+// rows where the field is NULL. SQL comparisons against NULL are never true,
+// so without it a filter like {"f": {"$ne": "foo"}} silently excludes rows
+// where f is not set.
+func WithNullMatching(enabled bool) Option {
+	return func(h *Handler) {
+		h.nullMatching = enabled
+	}
+}
+
+// WithInListThreshold sets the number of values above which In and NotIn
+// filters are loaded into a temporary table instead of being written as a
+// literal list in the statement. A threshold of 0 disables temporary tables.
+func WithInListThreshold(n int) Option {
+	return func(h *Handler) {
+		h.inListThreshold = n
+	}
+}
+
+// WithCountTotal sets whether Find runs a COUNT query so ItemList.Total is the
+// number of items matching the lookup, as rest-layer expects for pagination.
+// Disabled, Total is the number of items of the returned page, which saves a
+// query on large tables. The count is skipped when the page is the last one.
+func WithCountTotal(enabled bool) Option {
+	return func(h *Handler) {
+		h.countTotal = enabled
+	}
+}
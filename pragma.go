@@ -0,0 +1,104 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// pragmaValueRe matches the PRAGMA values accepted by WithPragma: keywords
+// and integers.
+var pragmaValueRe = regexp.MustCompile("^-?[A-Za-z0-9_]+$")
+
+// Pragma is a PRAGMA setting, e.g. {"journal_mode", "WAL"}.
+type Pragma struct {
+	Name  string
+	Value string
+}
+
+// execer runs statements on a *sql.DB, *sql.Conn or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// WithPragma adds a PRAGMA (e.g. "synchronous", "NORMAL") applied to each
+// connection of the pool when the handler is created, and again by Warmup.
+// Pragmas are applied in the order they were given.
+//
+// Connections opened by the pool later don't get them: size the pool with
+// SetMaxOpenConns and an equal SetMaxIdleConns so it keeps the configured
+// connections. NewHandler can't fail, so it only logs errors; Warmup returns
+// them.
+func WithPragma(name, value string) Option {
+	return func(h *Handler) {
+		h.pragmas = append(h.pragmas, Pragma{Name: name, Value: value})
+	}
+}
+
+// WithJournalMode sets the journal mode, e.g. "WAL". The mode is stored in
+// the database file.
+func WithJournalMode(mode string) Option {
+	return WithPragma("journal_mode", mode)
+}
+
+// WithSynchronous sets the synchronous level, e.g. "NORMAL".
+func WithSynchronous(level string) Option {
+	return WithPragma("synchronous", level)
+}
+
+// WithForeignKeys enables or disables the enforcement of foreign keys, which
+// reference fields and the ErrInvalidReference error rely on.
+func WithForeignKeys(enabled bool) Option {
+	if enabled {
+		return WithPragma("foreign_keys", "ON")
+	}
+	return WithPragma("foreign_keys", "OFF")
+}
+
+// WithBusyTimeout sets how long a statement waits for a lock held by another
+// connection before failing with SQLITE_BUSY.
+func WithBusyTimeout(d time.Duration) Option {
+	return WithPragma("busy_timeout", strconv.FormatInt(int64(d/time.Millisecond), 10))
+}
+
+// WithCacheSize sets the page cache size: a number of pages if positive, or
+// of KiB if negative.
+func WithCacheSize(n int) Option {
+	return WithPragma("cache_size", strconv.Itoa(n))
+}
+
+// WithMmapSize sets the maximum number of bytes of the database file mapped
+// in memory.
+func WithMmapSize(n int64) Option {
+	return WithPragma("mmap_size", strconv.FormatInt(n, 10))
+}
+
+// configurePool applies the handler's pragmas to the pool connections.
+func (h *Handler) configurePool(ctx context.Context) error {
+	return h.eachConn(ctx, func(c *sql.Conn) error {
+		return applyPragmas(ctx, c, h.pragmas)
+	})
+}
+
+// applyPragmas runs PRAGMA statements on db.
+func applyPragmas(ctx context.Context, db execer, pragmas []Pragma) error {
+	for _, p := range pragmas {
+		if !identRe.MatchString(p.Name) || !pragmaValueRe.MatchString(p.Value) {
+			return fmt.Errorf("sqlite3: invalid pragma: %s=%s", p.Name, p.Value)
+		}
+		if _, err := db.ExecContext(ctx, "PRAGMA "+p.Name+"="+p.Value+";"); err != nil {
+			log.WithFields(log.Fields{
+				"pragma": p.Name,
+				"error":  err,
+			}).Warn("Error applying pragma.")
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPragmaOptions(t *testing.T) {
+	Convey("Given a pool of a single connection", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.SetMaxOpenConns(1)
+
+		Convey("The pragmas should be applied when the handler is created", func() {
+			NewHandler(h.session, DB_TABLE,
+				WithForeignKeys(true),
+				WithSynchronous("NORMAL"),
+				WithBusyTimeout(2*time.Second),
+				WithCacheSize(-2000),
+				WithMmapSize(1<<20))
+			var fk, sync, timeout, cache int
+			So(h.session.QueryRow("PRAGMA foreign_keys;").Scan(&fk), ShouldBeNil)
+			So(fk, ShouldEqual, 1)
+			So(h.session.QueryRow("PRAGMA synchronous;").Scan(&sync), ShouldBeNil)
+			So(sync, ShouldEqual, 1)
+			So(h.session.QueryRow("PRAGMA busy_timeout;").Scan(&timeout), ShouldBeNil)
+			So(timeout, ShouldEqual, 2000)
+			So(h.session.QueryRow("PRAGMA cache_size;").Scan(&cache), ShouldBeNil)
+			So(cache, ShouldEqual, -2000)
+		})
+
+		Convey("Typed options should add pragmas", func() {
+			ph := NewHandler(nil, DB_TABLE, WithJournalMode("WAL"), WithForeignKeys(false), WithMmapSize(0))
+			So(ph.pragmas, ShouldResemble, []Pragma{
+				{Name: "journal_mode", Value: "WAL"},
+				{Name: "foreign_keys", Value: "OFF"},
+				{Name: "mmap_size", Value: "0"},
+			})
+		})
+	})
+}
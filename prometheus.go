@@ -0,0 +1,89 @@
+package sqlite3
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector is a Collector exporting the measures of the handler
+// operations as Prometheus metrics, labeled by table and operation:
+//
+//	<namespace>_operation_duration_seconds  histogram of operation latencies
+//	<namespace>_operation_rows              histogram of rows returned or changed
+//	<namespace>_operation_errors_total      counter of failed operations
+//	<namespace>_transaction_retries_total   counter of retried transactions
+//
+// It is a prometheus.Collector, to be registered once and shared by the
+// handlers:
+//
+//	c := sqlite3.NewPrometheusCollector("sqlite3")
+//	prometheus.MustRegister(c)
+//	h := sqlite3.NewHandler(db, "users", sqlite3.WithCollector(c))
+type PrometheusCollector struct {
+	duration *prometheus.HistogramVec
+	rows     *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+}
+
+// NewPrometheusCollector creates a collector whose metrics are prefixed by
+// namespace.
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	labels := []string{"table", "op"}
+	return &PrometheusCollector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the storage operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		rows: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_rows",
+			Help:      "Rows returned or changed by the storage operations.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operation_errors_total",
+			Help:      "Storage operations that returned an error.",
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transaction_retries_total",
+			Help:      "Retried storage transactions.",
+		}, labels),
+	}
+}
+
+// Observe implements Collector.
+func (c *PrometheusCollector) Observe(table string, op Operation, d time.Duration, rows int, err error) {
+	c.duration.WithLabelValues(table, string(op)).Observe(d.Seconds())
+	if err != nil {
+		c.errors.WithLabelValues(table, string(op)).Inc()
+		return
+	}
+	c.rows.WithLabelValues(table, string(op)).Observe(float64(rows))
+}
+
+// Retry implements Collector.
+func (c *PrometheusCollector) Retry(table string, op Operation) {
+	c.retries.WithLabelValues(table, string(op)).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (c *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.duration.Describe(ch)
+	c.rows.Describe(ch)
+	c.errors.Describe(ch)
+	c.retries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	c.duration.Collect(ch)
+	c.rows.Collect(ch)
+	c.errors.Collect(ch)
+	c.retries.Collect(ch)
+}
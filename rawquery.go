@@ -0,0 +1,121 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// QueryItems runs "SELECT * FROM <table> <fragment>" and returns the rows as
+// items, for admin features the filter DSL can't express. The fragment holds
+// the clauses following the table name (WHERE, ORDER BY, LIMIT...) and may use
+// ? placeholders bound to args. It must be a single statement, and runs on a
+// connection in query_only mode, so it can't modify the database. Like Find,
+// it leaves out soft deleted rows: the fragment applies to the live rows of
+// the table, under the table's name.
+//
+// The fragment is written into the statement as is: never build it from
+// client input, pass such values through args.
+func (h *Handler) QueryItems(ctx context.Context, fragment string, args ...interface{}) ([]*resource.Item, error) {
+	if statementTail(fragment) != "" {
+		return nil, fmt.Errorf("sqlite3: QueryItems fragment must be a single statement")
+	}
+	if err := h.ops.begin(); err != nil {
+		return nil, err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpFind)
+	defer cancel()
+
+	c, err := h.session.Conn(ctx)
+	if err != nil {
+		log.WithField("error", err).Warn("Error opening connection.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer c.Close()
+	if _, err = c.ExecContext(ctx, "PRAGMA query_only=ON;"); err != nil {
+		log.WithField("error", err).Warn("Error enabling query only mode.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer func() {
+		// the connection goes back to the pool, it must be writable again
+		// even if ctx is done
+		if _, err := c.ExecContext(context.Background(), "PRAGMA query_only=OFF;"); err != nil {
+			log.WithField("error", err).Warn("Error disabling query only mode.")
+		}
+	}()
+
+	src := h.readSource()
+	if h.softDelete {
+		src = "(SELECT * FROM " + src + " WHERE " + h.liveWhere("") + ") AS " + h.tableName
+	}
+	q := "SELECT * FROM " + src + " " + fragment
+	list, err := runSelect(ctx, h, c, q, 1, args...)
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// statementTail returns the text following the first statement of q, as
+// SQLite's prepare leaves it: what follows the first semicolon outside string
+// literals, quoted identifiers and comments, unless it is only whitespace,
+// comments and semicolons.
+func statementTail(q string) string {
+	end := -1
+	for i := 0; i < len(q) && end < 0; i++ {
+		switch c := q[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			close := c
+			if c == '[' {
+				close = ']'
+			}
+			if j := strings.IndexByte(q[i+1:], close); j >= 0 {
+				i += j + 1
+			} else {
+				// unterminated, prepare fails
+				return ""
+			}
+		case c == ';':
+			end = i + 1
+		default:
+			i = skipComment(q, i)
+		}
+	}
+	if end < 0 {
+		return ""
+	}
+	for i := end; i < len(q); i++ {
+		if c := q[i]; c != ';' && c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			if j := skipComment(q, i); j != i {
+				i = j
+				continue
+			}
+			return q[i:]
+		}
+	}
+	return ""
+}
+
+// skipComment returns the index of the last byte of the comment starting at
+// i in q, or i if there is none there.
+func skipComment(q string, i int) int {
+	switch {
+	case strings.HasPrefix(q[i:], "--"):
+		if j := strings.IndexByte(q[i:], '\n'); j >= 0 {
+			return i + j
+		}
+		return len(q) - 1
+	case strings.HasPrefix(q[i:], "/*"):
+		if j := strings.Index(q[i+2:], "*/"); j >= 0 {
+			return i + 2 + j + 1
+		}
+		return len(q) - 1
+	}
+	return i
+}
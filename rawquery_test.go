@@ -0,0 +1,86 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryItems(t *testing.T) {
+	Convey("Given stored items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+
+		Convey("QueryItems should return the matching items", func() {
+			items, err := h.QueryItems(context.Background(), "WHERE f2 * 10 > ? ORDER BY f1", 15)
+			So(err, ShouldBeNil)
+			So(len(items), ShouldEqual, 1)
+			So(items[0].ID, ShouldEqual, i2.ID)
+			So(items[0].ETag, ShouldEqual, i2.ETag)
+		})
+
+		Convey("QueryItems should refuse several statements", func() {
+			_, err := h.QueryItems(context.Background(), "WHERE f1 = ?; DELETE FROM testtable", "foo")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("QueryItems should accept semicolons in literals and comments", func() {
+			items, err := h.QueryItems(context.Background(), "WHERE f1 <> 'a;b' /* ; */ ORDER BY f1;")
+			So(err, ShouldBeNil)
+			So(len(items), ShouldEqual, 2)
+		})
+
+		Convey("Connections should be writable after QueryItems", func() {
+			for n := 0; n < 3; n++ {
+				_, err := h.QueryItems(context.Background(), "WHERE f2 > ?", 0)
+				So(err, ShouldBeNil)
+			}
+			So(h.Delete(context.Background(), i1), ShouldBeNil)
+			So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a soft deleting handler", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE rawquery;")
+		sh := NewHandler(h.session, "rawquery", WithSoftDelete())
+		So(sh.EnsureTable(context.Background(), schema.Schema{
+			"id": schema.IDField,
+			"f1": schema.Field{Validator: &schema.String{}},
+			"f2": schema.Field{Validator: &schema.Integer{}},
+		}), ShouldBeNil)
+		a, _ := item("foo", 1)
+		b, _ := item("bar", 2)
+		So(sh.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+		So(sh.Delete(context.Background(), a), ShouldBeNil)
+
+		Convey("QueryItems should leave out deleted items", func() {
+			items, err := sh.QueryItems(context.Background(), "WHERE rawquery.f2 > ?", 0)
+			So(err, ShouldBeNil)
+			So(len(items), ShouldEqual, 1)
+			So(items[0].ID, ShouldEqual, b.ID)
+			So(items[0].Payload, ShouldNotContainKey, DeletedColumn)
+		})
+	})
+}
+
+func TestStatementTail(t *testing.T) {
+	Convey("statementTail should return what follows the first statement", t, func() {
+		So(statementTail("WHERE f1 = 1"), ShouldEqual, "")
+		So(statementTail("WHERE f1 = 1; "), ShouldEqual, "")
+		So(statementTail("WHERE f1 = 1; -- done\n;"), ShouldEqual, "")
+		So(statementTail("WHERE f1 = ';' AND `a;` = \"b;\" AND [c;] = 1 /* ; */"), ShouldEqual, "")
+		So(statementTail("WHERE f1 = 'it''s;' -- ;\n"), ShouldEqual, "")
+		So(statementTail("WHERE f1 = 1; DELETE FROM t"), ShouldEqual, "DELETE FROM t")
+		So(statementTail("WHERE f1 = 1;/* x */DELETE FROM t"), ShouldEqual, "DELETE FROM t")
+	})
+}
@@ -0,0 +1,31 @@
+package sqlite3
+
+import (
+	"database/sql"
+)
+
+// WithReader routes the reads of Find, FindIter, MultiGet and History to a
+// separate pool, typically a read-only one opened with OpenReadOnly or a
+// replica, while writes, and the reads they depend on such as etag checks,
+// stay on the handler's pool. Reads on a separate pool of the same database in
+// WAL mode see the committed writes; reads on a replica see them once
+// replicated. The reader's pragmas are not set by the handler.
+func WithReader(db *sql.DB) Option {
+	return func(h *Handler) {
+		h.readDB = db
+	}
+}
+
+// NewSplitHandler creates a handler reading from reader and writing to
+// writer, see WithReader.
+func NewSplitHandler(reader, writer *sql.DB, tableName string, opts ...Option) *Handler {
+	return NewHandler(writer, tableName, append([]Option{WithReader(reader)}, opts...)...)
+}
+
+// reader returns the pool serving the handler's reads.
+func (h *Handler) reader() *sql.DB {
+	if h.readDB != nil {
+		return h.readDB
+	}
+	return h.session
+}
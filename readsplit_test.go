@@ -0,0 +1,61 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadSplit(t *testing.T) {
+	Convey("Given a handler with a read-only reader on the same database", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		reader, err := sql.Open(DriverName, fmt.Sprintf("file:%s?mode=ro", DB_FILE))
+		So(err, ShouldBeNil)
+		defer reader.Close()
+		sh := NewSplitHandler(reader, h.session, DB_TABLE, WithInListThreshold(1))
+		So(sh.DB(), ShouldEqual, h.session)
+
+		Convey("Writes should go to the writer and be read from the reader", func() {
+			So(sh.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+			list, err := sh.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 2)
+
+			// spilled In lists use temporary tables, allowed on read-only pools
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.In{Field: "id", Values: []schema.Value{i1.ID, i2.ID}}})
+			list, err = sh.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 2)
+
+			n, _ := item("bar", 3)
+			n.ID, n.Payload["id"] = i1.ID, i1.ID
+			So(sh.Update(context.Background(), n, i1), ShouldBeNil)
+			So(sh.Delete(context.Background(), n), ShouldBeNil)
+		})
+
+		Convey("Finds should not use the writer", func() {
+			mem, err := NewMemoryHandler(DB_TABLE, schema.Schema{"f1": schema.Field{Validator: &schema.String{}}})
+			So(err, ShouldBeNil)
+			defer mem.DB().Close()
+			i, _ := resource.NewItem(map[string]interface{}{"id": "elsewhere", "f1": "foo"})
+			So(mem.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+
+			rh := NewHandler(h.session, DB_TABLE, WithReader(mem.DB()))
+			list, err := rh.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].ID, ShouldEqual, "elsewhere")
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package sqlite3
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// DriverName is the name of the database/sql driver registered by the
+// package: the go-sqlite3 driver (or modernc.org/sqlite when built with the
+// modernc tag) with a REGEXP function backed by Go's regexp package, which
+// $regex filters require. Open and OpenReadOnly use it; pools
+// opened on the plain "sqlite3" driver fail on regex filters with a "no such
+// function: REGEXP" error.
+const DriverName = "sqlite3_rest"
+
+// maxCachedRegexps bounds the number of compiled patterns kept by the REGEXP
+// function.
+const maxCachedRegexps = 256
+
+var regexpCache = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+// regexpMatch implements "v REGEXP pattern". NULL values never match.
+func regexpMatch(pattern string, v interface{}) (bool, error) {
+	var s string
+	switch t := v.(type) {
+	case nil:
+		return false, nil
+	case string:
+		s = t
+	case []byte:
+		// the driver passes NULL as a nil byte slice
+		if t == nil {
+			return false, nil
+		}
+		s = string(t)
+	default:
+		s = fmt.Sprint(t)
+	}
+
+	regexpCache.Lock()
+	re, found := regexpCache.m[pattern]
+	regexpCache.Unlock()
+	if !found {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, err
+		}
+		regexpCache.Lock()
+		if len(regexpCache.m) >= maxCachedRegexps {
+			regexpCache.m = map[string]*regexp.Regexp{}
+		}
+		regexpCache.m[pattern] = re
+		regexpCache.Unlock()
+	}
+	return re.MatchString(s), nil
+}
@@ -0,0 +1,50 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegex(t *testing.T) {
+	Convey("Regex filters should be translated to REGEXP", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Regex{Field: "f1", Value: regexp.MustCompile("^it's")}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 REGEXP '^it''s'")
+	})
+
+	Convey("Given stored items on the package driver", t, func() {
+		db, err := sql.Open(DriverName, DB_FILE)
+		So(err, ShouldBeNil)
+		h := NewHandler(db, DB_TABLE)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+
+		Convey("Find should match the items with a regex", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Regex{Field: "f1", Value: regexp.MustCompile("^b.r$")}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, i2.ID)
+		})
+
+		Convey("NULL columns should not match", func() {
+			_, err := h.session.Exec("UPDATE " + DB_TABLE + " SET f1 = NULL")
+			So(err, ShouldBeNil)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Regex{Field: "f1", Value: regexp.MustCompile(".*")}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package sqlite3
+
+import (
+	"database/sql"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// WithIdempotentInsert makes Insert succeed when an item already exists with
+// the same id and etag, as when a request is replayed after a network error.
+// The existing row is left untouched. An item existing with another etag
+// fails the Insert with resource.ErrConflict. Without etags (ETagNone), a
+// replay can't be told from a conflict, so the Insert fails as usual.
+func WithIdempotentInsert(enabled bool) Option {
+	return func(h *Handler) {
+		h.idempotentInsert = enabled
+	}
+}
+
+// replayed checks an insert of i that failed with err against the stored
+// row of the same id. It returns nil if the insert is a replay of the stored
+// item, resource.ErrConflict if another item has the id, and err otherwise.
+func (h *Handler) replayed(ctx context.Context, db rowQuerier, i *resource.Item, err error) error {
+	if !h.idempotentInsert || h.etagMode == ETagNone || !isUniqueErr(err) {
+		return err
+	}
+	lit, lerr := h.idLiteral(i.ID)
+	if lerr != nil {
+		return err
+	}
+	var etag, updated sql.NullString
+	row := db.QueryRowContext(ctx, h.annotate(ctx, "SELECT "+h.column("etag")+","+h.column("updated")+" FROM "+h.tableName+" WHERE id = "+lit+";"))
+	switch serr := row.Scan(&etag, &updated); {
+	case serr == sql.ErrNoRows:
+		// the violated constraint is not on the id
+		return err
+	case serr != nil:
+		return serr
+	case etag.String != i.ETag:
+		return h.conflict(i.ID, etag.String, updated)
+	}
+	return nil
+}
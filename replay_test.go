@@ -0,0 +1,42 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdempotentInsert(t *testing.T) {
+	Convey("Given an item inserted by a handler with idempotent inserts", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		rh := NewHandler(h.session, DB_TABLE, WithIdempotentInsert(true))
+		it, _ := item("foo", 1)
+		So(rh.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+
+		Convey("A replayed insert should succeed", func() {
+			other, _ := item("bar", 2)
+			So(rh.Insert(context.Background(), []*resource.Item{it, other}), ShouldBeNil)
+			list, err := rh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 2)
+		})
+
+		Convey("An insert of another item with the same id should conflict", func() {
+			other, _ := item("bar", 2)
+			other.ID = it.ID
+			other.Payload["id"] = it.ID
+			So(rh.Insert(context.Background(), []*resource.Item{other}), ShouldEqual, resource.ErrConflict)
+		})
+
+		Convey("A replayed insert should conflict without the option", func() {
+			So(h.Insert(context.Background(), []*resource.Item{it}), ShouldEqual, resource.ErrConflict)
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// RetryPolicy sets how operations failing because the database is locked by
+// another connection (SQLITE_BUSY or SQLITE_LOCKED) are retried. Operations
+// are retried as a whole, from the start of their transaction.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts of an operation, the first one
+	// included. 1 or less disables retries.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It doubles on each retry,
+	// up to MaxBackoff if set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each wait that is random, between 0 and 1,
+	// so writers failing together don't retry together.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is the retry policy of handlers created without the
+// WithRetryPolicy option.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 6,
+	Backoff:     10 * time.Millisecond,
+	MaxBackoff:  time.Second,
+	Jitter:      0.2,
+}
+
+// WithRetryPolicy sets how operations failing on a locked database are
+// retried before the error is returned.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(h *Handler) {
+		h.retry = p
+	}
+}
+
+// wait returns the wait before the retry following the given attempt,
+// counted from 1.
+func (p RetryPolicy) wait(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// txConn runs the statements of a transaction: a *sql.Tx or an immediateTx.
+type txConn interface {
+	execer
+	querier
+	rowQuerier
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	Commit() error
+	Rollback() error
+}
+
+// immediateTx is a transaction started with BEGIN IMMEDIATE on a connection of
+// the pool. It takes the write lock up front, so it can't fail halfway through
+// when another connection holds it: either it starts, or it fails with
+// SQLITE_BUSY before anything was done.
+type immediateTx struct {
+	*sql.Conn
+}
+
+// beginImmediate starts an immediate transaction.
+func (h *Handler) beginImmediate(ctx context.Context) (*immediateTx, error) {
+	c, err := h.session.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.ExecContext(ctx, "BEGIN IMMEDIATE;"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &immediateTx{c}, nil
+}
+
+// Commit commits the transaction and releases the connection. The transaction
+// is rolled back if the commit fails.
+func (t *immediateTx) Commit() error {
+	if _, err := t.ExecContext(context.Background(), "COMMIT;"); err != nil {
+		t.Rollback()
+		return err
+	}
+	return t.Close()
+}
+
+// Rollback rolls the transaction back and releases the connection.
+func (t *immediateTx) Rollback() error {
+	_, err := t.ExecContext(context.Background(), "ROLLBACK;")
+	t.Close()
+	return err
+}
+
+// isBusy reports whether an error is caused by a lock held by another
+// connection.
+func isBusy(err error) bool {
+	code, ok := errorCode(err)
+	// the primary result code is the low byte of the extended one
+	return ok && (code&0xff == codeBusy || code&0xff == codeLocked)
+}
+
+// retryBusy runs fn, and runs it again as the retry policy allows while it
+// fails because the database is locked. Retries are reported to the
+// collector.
+func (h *Handler) retryBusy(ctx context.Context, op Operation, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= h.retry.MaxAttempts || !isBusy(err) {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"table":   h.tableName,
+			"attempt": attempt,
+			"error":   err,
+		}).Info("Retrying a busy operation.")
+		select {
+		case <-time.After(h.retry.wait(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if h.collector != nil {
+			h.collector.Retry(h.tableName, op)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBusyRetry(t *testing.T) {
+	Convey("Retry waits should grow up to the maximum backoff", t, func() {
+		p := RetryPolicy{MaxAttempts: 5, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+		So(p.wait(1), ShouldEqual, 10*time.Millisecond)
+		So(p.wait(2), ShouldEqual, 20*time.Millisecond)
+		So(p.wait(3), ShouldEqual, 40*time.Millisecond)
+		So(p.wait(4), ShouldEqual, 50*time.Millisecond)
+		p.Jitter = 0.5
+		for i := 0; i < 10; i++ {
+			So(p.wait(1), ShouldBeBetweenOrEqual, 5*time.Millisecond, 10*time.Millisecond)
+		}
+	})
+
+	Convey("Given a table locked by another writer", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		i1, _ := item("foo", 1)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		// the clearing pool doesn't wait for locks, so it sees SQLITE_BUSY
+		db, err := sql.Open(DB_DRIVER, DB_FILE+"?_busy_timeout=0")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		c := &recordingCollector{}
+		ch := NewHandler(db, DB_TABLE, WithCollector(c))
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo"}})
+
+		lock, err := h.session.Begin()
+		So(err, ShouldBeNil)
+		_, err = lock.Exec("UPDATE " + DB_TABLE + " SET f2 = 2;")
+		So(err, ShouldBeNil)
+
+		Convey("Clear should be retried until the lock is released", func() {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				lock.Rollback()
+			}()
+			n, err := ch.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(c.retries, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("Writes should be retried until the lock is released", func() {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				lock.Rollback()
+			}()
+			updated, _ := resource.NewItem(map[string]interface{}{"id": i1.ID, "created": i1.Payload["created"], "f1": "new", "f2": 3})
+			So(ch.Update(context.Background(), updated, i1), ShouldBeNil)
+			So(c.retries, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("Writes should not be retried without a retry policy", func() {
+			defer lock.Rollback()
+			nh := NewHandler(db, DB_TABLE, WithCollector(c), WithRetryPolicy(RetryPolicy{}))
+			i2, _ := item("bar", 2)
+			So(isBusy(nh.Insert(context.Background(), []*resource.Item{i2})), ShouldBeTrue)
+			So(c.retries, ShouldEqual, 0)
+		})
+
+		Convey("Clear should give up when the lock is held too long", func() {
+			defer lock.Rollback()
+			_, err := ch.Clear(context.Background(), l)
+			So(isBusy(err), ShouldBeTrue)
+			So(c.retries, ShouldEqual, DefaultRetryPolicy.MaxAttempts-1)
+		})
+	})
+}
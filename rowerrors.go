@@ -0,0 +1,31 @@
+package sqlite3
+
+import (
+	"sync/atomic"
+)
+
+// RowErrorPolicy controls what Find does with a row that can't be converted
+// to an item, like a row with a malformed timestamp or a NULL etag.
+type RowErrorPolicy int
+
+const (
+	// RowErrorFail fails the whole operation. This is the default.
+	RowErrorFail RowErrorPolicy = iota
+	// RowErrorSkip logs the row, counts it in SkippedRows and leaves it out of
+	// the result, so a single corrupt record doesn't break a collection.
+	RowErrorSkip
+)
+
+// WithRowErrorPolicy sets how rows that can't be converted to items are
+// handled.
+func WithRowErrorPolicy(p RowErrorPolicy) Option {
+	return func(h *Handler) {
+		h.rowErrors = p
+	}
+}
+
+// SkippedRows returns the number of rows left out of results by the
+// RowErrorSkip policy since the handler was created.
+func (h *Handler) SkippedRows() int64 {
+	return atomic.LoadInt64(h.skipped)
+}
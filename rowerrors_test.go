@@ -0,0 +1,37 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRowErrorPolicy(t *testing.T) {
+	Convey("Given a corrupt row among stored items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		_, err = h.session.Exec("UPDATE "+DB_TABLE+" SET etag = NULL WHERE id = ?", i1.ID)
+		So(err, ShouldBeNil)
+
+		Convey("Find should fail by default", func() {
+			_, err := h.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Find should skip the row when asked to", func() {
+			sh := NewHandler(h.session, DB_TABLE, WithRowErrorPolicy(RowErrorSkip))
+			list, err := sh.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, i2.ID)
+			So(sh.SkippedRows(), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package sqlite3
+
+import (
+	"database/sql"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// Scratch is a temporary table with the same columns as a handler's table,
+// used to stage request-scoped data (e.g. a bulk upload being validated)
+// before it is committed into the main table. A Scratch owns a transaction:
+// its table is only visible through it, and is dropped when the Scratch is
+// committed or closed. A Scratch must not be used concurrently.
+type Scratch struct {
+	h     *Handler // handler bound to the scratch table
+	main  *Handler // handler of the table the scratch rows are committed to
+	txPtr *sql.Tx
+	done  bool
+}
+
+// NewScratch creates a scratch table for the handler's table. The returned
+// Scratch must be committed or closed to release its connection. It is
+// discarded if ctx is canceled before it is committed.
+func (h *Handler) NewScratch(ctx context.Context) (*Scratch, error) {
+	// learn whether the table stores the created field before the scratch
+	// transaction holds a connection
+	if _, err := h.storesCreated(ctx); err != nil {
+		return nil, err
+	}
+	txPtr, err := h.session.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithField("error", err).Warn("Error starting scratch transaction.")
+		return nil, ctxErr(ctx, err)
+	}
+	name := "_scratch_" + h.tableName
+	_, err = txPtr.ExecContext(ctx, "CREATE TEMP TABLE "+name+" AS SELECT * FROM "+h.tableName+" WHERE 0;")
+	if err != nil {
+		txPtr.Rollback()
+		log.WithFields(log.Fields{
+			"table": h.tableName,
+			"error": err,
+		}).Warn("Error creating scratch table.")
+		return nil, err
+	}
+	sh := *h
+	sh.tableName = "temp." + name
+	return &Scratch{h: &sh, main: h, txPtr: txPtr}, nil
+}
+
+// Table returns the qualified name of the scratch table, for use in custom
+// validation queries run through Query.
+func (s *Scratch) Table() string {
+	return s.h.tableName
+}
+
+// Insert stores items in the scratch table. Like Handler.Insert, it sets
+// their timestamps and computes their etag with the handler's ETagFunc.
+func (s *Scratch) Insert(ctx context.Context, items []*resource.Item) error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	for _, i := range items {
+		// the main table tells whether the created field is stored
+		if err := s.main.setTimestamps(ctx, i); err != nil {
+			log.WithField("error", err).Warn("Error setting timestamps.")
+			return ctxErr(ctx, err)
+		}
+		if err := s.h.setETag(i); err != nil {
+			log.WithField("error", err).Warn("Error computing ETag.")
+			return err
+		}
+		q, err := getInsert(s.h, i)
+		if err != nil {
+			log.WithField("error", err).Warn("Error creating scratch insert statement.")
+			return err
+		}
+		if _, err = s.txPtr.ExecContext(ctx, q); err != nil {
+			log.WithField("error", err).Warn("Error executing scratch insert statement.")
+			return ctxErr(ctx, err)
+		}
+	}
+	return nil
+}
+
+// Find searches for items in the scratch table matching the lookup.
+func (s *Scratch) Find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*resource.ItemList, error) {
+	if s.done {
+		return nil, sql.ErrTxDone
+	}
+	q, err := getSelect(s.h, lookup, page, perPage)
+	if err != nil {
+		log.WithField("error", err).Warn("Error getting the scratch select statement.")
+		return nil, err
+	}
+	return runSelect(ctx, s.h, s.txPtr, q, page)
+}
+
+// Query runs an arbitrary query in the scratch transaction.
+func (s *Scratch) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if s.done {
+		return nil, sql.ErrTxDone
+	}
+	return s.txPtr.QueryContext(ctx, query, args...)
+}
+
+// Commit copies the scratch rows into the main table, drops the scratch table
+// and commits, returning the number of rows copied. If the copy fails, nothing
+// is written to the main table.
+func (s *Scratch) Commit(ctx context.Context) (int, error) {
+	if s.done {
+		return 0, sql.ErrTxDone
+	}
+	s.done = true
+	result, err := s.txPtr.ExecContext(ctx, "INSERT INTO "+s.main.tableName+" SELECT * FROM "+s.h.tableName+";")
+	if err != nil {
+		s.txPtr.Rollback()
+		log.WithField("error", err).Warn("Error copying scratch rows.")
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		s.txPtr.Rollback()
+		return 0, err
+	}
+	if _, err = s.txPtr.ExecContext(ctx, "DROP TABLE "+s.h.tableName+";"); err != nil {
+		s.txPtr.Rollback()
+		log.WithField("error", err).Warn("Error dropping scratch table.")
+		return 0, err
+	}
+	return int(n), s.txPtr.Commit()
+}
+
+// Close discards the scratch table. It is a no-op after Commit.
+func (s *Scratch) Close() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	return s.txPtr.Rollback()
+}
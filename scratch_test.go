@@ -0,0 +1,71 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScratch(t *testing.T) {
+	Convey("Given a scratch table with staged items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		s, err := h.NewScratch(context.Background())
+		So(err, ShouldBeNil)
+		defer s.Close()
+		So(s.Table(), ShouldEqual, "temp._scratch_"+DB_TABLE)
+		So(s.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+
+		Convey("Find should return the staged items", func() {
+			result, err := s.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 2)
+		})
+
+		Convey("Close should discard the staged items", func() {
+			So(s.Close(), ShouldBeNil)
+			result, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 0)
+			_, err = s.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Commit should copy the staged items into the main table", func() {
+			n, err := s.Commit(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			result, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 2)
+		})
+
+		Convey("Insert should set the timestamps and etag like Handler.Insert", func() {
+			s.Close()
+			eh := NewHandler(h.session, DB_TABLE, WithETagFunc(MD5ETag))
+			s, err := eh.NewScratch(context.Background())
+			So(err, ShouldBeNil)
+			i, _ := resource.NewItem(map[string]interface{}{"id": "staged", "f1": "foo"})
+			i.Updated = time.Time{}
+			So(s.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+			_, err = s.Commit(context.Background())
+			So(err, ShouldBeNil)
+
+			var etag, updated, created sql.NullString
+			So(h.session.QueryRow("SELECT etag, updated, created FROM "+DB_TABLE+" WHERE id = 'staged'").Scan(&etag, &updated, &created), ShouldBeNil)
+			md5, _ := MD5ETag(i.Payload)
+			So(etag.String, ShouldEqual, md5)
+			So(updated.String, ShouldNotBeEmpty)
+			So(created.String, ShouldEqual, updated.String)
+		})
+	})
+}
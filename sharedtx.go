@@ -0,0 +1,116 @@
+package sqlite3
+
+import (
+	"database/sql"
+
+	"golang.org/x/net/context"
+)
+
+// savepointName is the name of the savepoints of the operations run in a
+// shared transaction.
+const savepointName = "restlayer_op"
+
+// WithTx returns a copy of the handler running Find, Insert, Update, Delete,
+// Clear and its other reads and writes in the transaction tx, so that the
+// writes of several handlers, like a user and their first post, are committed
+// or rolled back together:
+//
+//	tx, err := db.BeginTx(ctx, nil)
+//	...
+//	err = users.WithTx(tx).Insert(ctx, []*resource.Item{user})
+//	if err == nil {
+//		err = posts.WithTx(tx).Insert(ctx, []*resource.Item{post})
+//	}
+//	if err != nil {
+//		tx.Rollback()
+//	} else {
+//		err = tx.Commit()
+//	}
+//
+// Each operation runs in a savepoint of the transaction, released when it
+// succeeds and rolled back when it fails, so a failed operation leaves the
+// transaction as it was. Finds read the writes of the transaction. Inserts
+// are not batched. Change hooks are called when an operation succeeds, before
+// the transaction is committed. Like the *sql.Tx, the returned handler must
+// not be used concurrently, nor after the transaction ends.
+func (h *Handler) WithTx(tx *sql.Tx) *Handler {
+	th := *h
+	th.tx = tx
+	return &th
+}
+
+// savepoint is the transaction of an operation run in a savepoint of a shared
+// transaction.
+type savepoint struct {
+	*sql.Tx
+}
+
+// beginTx starts the transaction of a write: a new transaction of the pool,
+// or a savepoint of the handler's shared transaction.
+func (h *Handler) beginTx(ctx context.Context) (txConn, error) {
+	if h.tx != nil {
+		if _, err := h.tx.ExecContext(ctx, "SAVEPOINT "+savepointName+";"); err != nil {
+			return nil, err
+		}
+		return savepoint{h.tx}, nil
+	}
+	tx, err := h.session.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// dbConn runs statements.
+type dbConn interface {
+	execer
+	querier
+	rowQuerier
+}
+
+// conn returns where the statements of the handler not needing a transaction
+// of their own run: its shared transaction, or the pool.
+func (h *Handler) conn() dbConn {
+	if h.tx != nil {
+		return h.tx
+	}
+	return h.session
+}
+
+// readConn returns where the reads of the handler not needing a transaction
+// of their own run: its shared transaction, or the reader pool.
+func (h *Handler) readConn() querier {
+	if h.tx != nil {
+		return h.tx
+	}
+	return h.reader()
+}
+
+// beginRead starts the transaction holding the temporary tables of a read: a
+// new transaction of the reader pool, or a savepoint of the handler's shared
+// transaction.
+func (h *Handler) beginRead(ctx context.Context) (txConn, error) {
+	if h.tx != nil {
+		return h.beginTx(ctx)
+	}
+	tx, err := h.reader().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Commit releases the savepoint, leaving its changes to the transaction.
+func (s savepoint) Commit() error {
+	_, err := s.ExecContext(context.Background(), "RELEASE "+savepointName+";")
+	return err
+}
+
+// Rollback reverts the changes made since the savepoint and releases it.
+func (s savepoint) Rollback() error {
+	_, err := s.ExecContext(context.Background(), "ROLLBACK TO "+savepointName+";")
+	if err == nil {
+		_, err = s.ExecContext(context.Background(), "RELEASE "+savepointName+";")
+	}
+	return err
+}
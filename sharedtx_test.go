@@ -0,0 +1,88 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithTx(t *testing.T) {
+	Convey("Given handlers on two tables", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		ph := NewHandler(h.session, "posts")
+		h.session.Exec("DROP TABLE `posts`;")
+		So(ph.EnsureTable(context.Background(), schema.Schema{"title": schema.Field{Validator: &schema.String{}}}), ShouldBeNil)
+		defer h.session.Exec("DROP TABLE `posts`;")
+
+		user, _ := item("user", 1)
+		post, _ := resource.NewItem(map[string]interface{}{"id": "p1", "title": "hello"})
+		count := func(h *Handler) int {
+			list, err := h.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			return len(list.Items)
+		}
+
+		Convey("Writes should be committed together", func() {
+			tx, err := h.session.BeginTx(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(h.WithTx(tx).Insert(context.Background(), []*resource.Item{user}), ShouldBeNil)
+			So(ph.WithTx(tx).Insert(context.Background(), []*resource.Item{post}), ShouldBeNil)
+			So(count(ph.WithTx(tx)), ShouldEqual, 1)
+			So(tx.Commit(), ShouldBeNil)
+			So(count(h), ShouldEqual, 1)
+			So(count(ph), ShouldEqual, 1)
+		})
+
+		Convey("Writes should be rolled back together", func() {
+			tx, err := h.session.BeginTx(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(h.WithTx(tx).Insert(context.Background(), []*resource.Item{user}), ShouldBeNil)
+			So(ph.WithTx(tx).Insert(context.Background(), []*resource.Item{post}), ShouldBeNil)
+			So(tx.Rollback(), ShouldBeNil)
+			So(count(h), ShouldEqual, 0)
+			So(count(ph), ShouldEqual, 0)
+		})
+
+		Convey("A failed operation should leave the transaction usable", func() {
+			tx, err := h.session.BeginTx(context.Background(), nil)
+			So(err, ShouldBeNil)
+			th := h.WithTx(tx)
+			So(th.Insert(context.Background(), []*resource.Item{user}), ShouldBeNil)
+			So(th.Insert(context.Background(), []*resource.Item{user}), ShouldEqual, resource.ErrConflict)
+			stale := *user
+			stale.ETag = "stale"
+			So(th.Update(context.Background(), user, &stale), ShouldEqual, resource.ErrConflict)
+			So(ph.WithTx(tx).Insert(context.Background(), []*resource.Item{post}), ShouldBeNil)
+			So(tx.Commit(), ShouldBeNil)
+			So(count(h), ShouldEqual, 1)
+			So(count(ph), ShouldEqual, 1)
+		})
+
+		Convey("Maintenance writes should run in the transaction", func() {
+			So(ph.Insert(context.Background(), []*resource.Item{post}), ShouldBeNil)
+			tx, err := h.session.BeginTx(context.Background(), nil)
+			So(err, ShouldBeNil)
+			th := ph.WithTx(tx)
+			_, err = th.Migrate(context.Background(), schema.Schema{
+				"title": schema.Field{Validator: &schema.String{}},
+				"body":  schema.Field{Validator: &schema.String{}},
+			}, MigrateOptions{})
+			So(err, ShouldBeNil)
+			n, err := th.Backfill(context.Background(), "body", "empty", 0, nil)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(tx.Rollback(), ShouldBeNil)
+			cols, err := columnTypes(context.Background(), h.session, "posts")
+			So(err, ShouldBeNil)
+			So(cols, ShouldNotContainKey, "body")
+		})
+	})
+}
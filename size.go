@@ -0,0 +1,84 @@
+package sqlite3
+
+import (
+	"errors"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/schema"
+)
+
+// DefaultMaxStatementSize is the maximum statement size of handlers created
+// without the WithMaxStatementSize option: SQLite's default
+// SQLITE_MAX_SQL_LENGTH, in bytes.
+const DefaultMaxStatementSize = 1000000000
+
+// statementMargin is the room left for the rest of a statement when checking
+// the size of its WHERE clause.
+const statementMargin = 1024
+
+// ErrStatementTooLarge is returned when a generated statement is larger than
+// the handler's maximum statement size, instead of SQLite's opaque "string or
+// blob too big" error.
+var ErrStatementTooLarge = errors.New("sqlite3: statement too large")
+
+// WithMaxStatementSize sets the size in bytes above which generated
+// statements are rejected with ErrStatementTooLarge. It must not be larger
+// than the SQLITE_MAX_SQL_LENGTH the SQLite library was built with. The In
+// lists of filters that would make a Find or Clear statement too large are
+// loaded into temporary tables, whatever the In list threshold. A size of 0
+// disables the check.
+func WithMaxStatementSize(n int) Option {
+	return func(h *Handler) {
+		h.maxStatementSize = n
+	}
+}
+
+// checkSize returns ErrStatementTooLarge if a statement is too large.
+func (h *Handler) checkSize(s string) error {
+	if h.maxStatementSize > 0 && len(s) > h.maxStatementSize {
+		log.WithFields(log.Fields{
+			"table": h.tableName,
+			"size":  len(s),
+			"max":   h.maxStatementSize,
+		}).Warn("Statement too large.")
+		return ErrStatementTooLarge
+	}
+	return nil
+}
+
+// spillThreshold returns the In list size above which the lists of the filter
+// must be loaded into temporary tables, and whether any must be: lists larger
+// than the handler's threshold, or all of them when the filter would be too
+// large for a statement.
+func (h *Handler) spillThreshold(q schema.Query) (int, bool) {
+	if h.needsSpill(q) {
+		return h.inListThreshold, true
+	}
+	if h.maxStatementSize <= 0 || !hasInLists(q) {
+		return 0, false
+	}
+	where, err := translateQuery(h, q)
+	if err != nil || len(where)+statementMargin <= h.maxStatementSize {
+		return 0, false
+	}
+	return 0, true
+}
+
+// hasInLists reports whether a filter has In or NotIn expressions.
+func hasInLists(q schema.Query) bool {
+	for _, exp := range q {
+		switch t := exp.(type) {
+		case schema.And:
+			if hasInLists(schema.Query(t)) {
+				return true
+			}
+		case schema.Or:
+			if hasInLists(schema.Query(t)) {
+				return true
+			}
+		case schema.In, schema.NotIn:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatementSize(t *testing.T) {
+	Convey("Given a handler with a small maximum statement size", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		// temporary tables are only used to keep statements small
+		sh := NewHandler(h.session, DB_TABLE, WithMaxStatementSize(2000), WithInListThreshold(0))
+		i1, _ := item("foo", 1)
+		So(sh.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		Convey("Large In lists should be loaded into temporary tables", func() {
+			ids := []schema.Value{i1.ID}
+			for i := 0; i < 200; i++ {
+				ids = append(ids, fmt.Sprintf("missing-%d", i))
+			}
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.In{Field: "id", Values: ids}})
+			list, err := sh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			n, err := sh.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+
+		Convey("Small In lists should stay in the statement", func() {
+			_, ok := sh.spillThreshold(schema.Query{schema.In{Field: "id", Values: []schema.Value{"a", "b"}}})
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Other large statements should be rejected", func() {
+			big := strings.Repeat("x", 3000)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: big}})
+			_, err := sh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldEqual, ErrStatementTooLarge)
+
+			i2, _ := item(big, 2)
+			So(sh.Insert(context.Background(), []*resource.Item{i2}), ShouldEqual, ErrStatementTooLarge)
+		})
+	})
+}
@@ -0,0 +1,96 @@
+package sqlite3
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// DeletedColumn is the column holding the deletion time of soft deleted rows.
+const DeletedColumn = "deleted_at"
+
+// WithSoftDelete makes Delete and Clear mark rows deleted, by setting their
+// deleted_at column, instead of removing them. Soft deleted rows are left out
+// of Find, Update and Delete as if they were removed, until Undelete restores
+// them or Purge removes them for good. Their id stays taken in the meantime.
+// The table needs the deleted_at column, which EnsureTable creates.
+func WithSoftDelete() Option {
+	return func(h *Handler) {
+		h.softDelete = true
+	}
+}
+
+// liveWhere restricts a WHERE clause to the rows which aren't soft deleted.
+func (h *Handler) liveWhere(where string) string {
+	if !h.softDelete {
+		return where
+	}
+	if where == "" {
+		return DeletedColumn + " IS NULL"
+	}
+	return "(" + where + ") AND " + DeletedColumn + " IS NULL"
+}
+
+// softDeleteStatement returns the statement marking the live rows of a table
+// reference matching a WHERE clause deleted.
+func (h *Handler) softDeleteStatement(table, where string) string {
+	return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s;",
+		table, DeletedColumn, h.timeFormat.literal(h.clock.Now()), h.liveWhere(where))
+}
+
+// Undelete restores a soft deleted item. It returns resource.ErrNotFound if no
+// item with the id is soft deleted.
+func (h *Handler) Undelete(ctx context.Context, id interface{}) error {
+	lit, err := h.idLiteral(id)
+	if err != nil {
+		return resource.ErrNotFound
+	}
+	if err = h.ops.begin(); err != nil {
+		return err
+	}
+	defer h.ops.end()
+
+	s := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE id = %s AND %s IS NOT NULL;",
+		h.tableName, DeletedColumn, lit, DeletedColumn)
+	result, err := h.conn().ExecContext(ctx, h.annotate(ctx, s))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    id,
+			"error": err,
+		}).Warn("Error restoring soft deleted item.")
+		return ctxErr(ctx, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return resource.ErrNotFound
+	}
+	return nil
+}
+
+// Purge removes the rows soft deleted before the given time for good, and
+// returns their number.
+func (h *Handler) Purge(ctx context.Context, before time.Time) (int, error) {
+	if err := h.ops.begin(); err != nil {
+		return 0, err
+	}
+	defer h.ops.end()
+
+	s := fmt.Sprintf("DELETE FROM %s WHERE %s < %s;", h.tableName, DeletedColumn, h.timeFormat.literal(before))
+	result, err := h.conn().ExecContext(ctx, h.annotate(ctx, s))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"table": h.tableName,
+			"error": err,
+		}).Warn("Error purging soft deleted items.")
+		return 0, ctxErr(ctx, err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
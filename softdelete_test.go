@@ -0,0 +1,80 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSoftDelete(t *testing.T) {
+	Convey("Soft deleting handlers should mark rows deleted", t, func() {
+		h := NewHandler(nil, DB_TABLE, WithSoftDelete(), WithClock(ClockFunc(func() time.Time {
+			return time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+		})))
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "f2", Value: 1}})
+		s, err := getDelete(h, l)
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "UPDATE "+DB_TABLE+" SET deleted_at = '2016-01-02 03:04:05 +0000 UTC' WHERE (f2 IS 1) AND deleted_at IS NULL;")
+	})
+
+	Convey("Given a soft deleting handler", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		s := schema.Schema{
+			"id": schema.IDField,
+			"f1": schema.Field{Validator: &schema.String{}},
+			"f2": schema.Field{Validator: &schema.Integer{}},
+		}
+		h.session.Exec("DROP TABLE softdelete;")
+		sh := NewHandler(h.session, "softdelete", WithSoftDelete())
+		So(sh.EnsureTable(context.Background(), s), ShouldBeNil)
+		i1, _ := item("foo", 1)
+		i2, _ := item("bar", 2)
+		So(sh.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		So(sh.Delete(context.Background(), i1), ShouldBeNil)
+
+		Convey("Deleted items should be left out of Find", func() {
+			list, err := sh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].ID, ShouldEqual, i2.ID)
+			So(list.Items[0].Payload, ShouldNotContainKey, DeletedColumn)
+
+			So(sh.Delete(context.Background(), i1), ShouldEqual, resource.ErrNotFound)
+			So(sh.Update(context.Background(), i1, i1), ShouldEqual, resource.ErrNotFound)
+		})
+
+		Convey("Undelete should restore deleted items", func() {
+			So(sh.Undelete(context.Background(), i1.ID), ShouldBeNil)
+			So(sh.Undelete(context.Background(), i1.ID), ShouldEqual, resource.ErrNotFound)
+			list, err := sh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 2)
+		})
+
+		Convey("Clear should mark the matching items deleted", func() {
+			n, err := sh.Clear(context.Background(), resource.NewLookup())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			var count int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM softdelete;").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 2)
+		})
+
+		Convey("Purge should remove the deleted items for good", func() {
+			n, err := sh.Purge(context.Background(), time.Now().Add(-time.Hour))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			n, err = sh.Purge(context.Background(), time.Now().Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(sh.Undelete(context.Background(), i1.ID), ShouldEqual, resource.ErrNotFound)
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package sqlite3
+
+// NullsOrder sets where NULL values are placed when sorting on a field.
+type NullsOrder int
+
+const (
+	// NullsDefault keeps SQLite's ordering: NULLs sort before any other value,
+	// so they come first in ascending sorts and last in descending ones.
+	NullsDefault NullsOrder = iota
+	// NullsFirst places NULLs first whatever the sort direction.
+	NullsFirst
+	// NullsLast places NULLs last whatever the sort direction.
+	NullsLast
+)
+
+// SortOption configures how a field is sorted.
+type SortOption struct {
+	// Collation is the collating sequence used to compare the field's values,
+	// e.g. NOCASE. Empty uses the column's collation.
+	Collation string
+	// Nulls sets where rows with a NULL value are placed. SQLite has no NULLS
+	// FIRST/LAST clause, so it is emulated with a "field IS NULL" sort key.
+	Nulls NullsOrder
+}
+
+// WithSortOption sets the collation and NULL ordering used when sorting on
+// field. The field name is the one used in the sort parameter, without the
+// leading "-".
+func WithSortOption(field string, o SortOption) Option {
+	return func(h *Handler) {
+		h.sorts[field] = o
+	}
+}
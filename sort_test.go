@@ -0,0 +1,39 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSortOptions(t *testing.T) {
+	v := schema.Schema{"id": schema.IDField, "f": schema.Field{Sortable: true}, "g": schema.Field{Sortable: true}}
+
+	Convey("Sorts should apply the field collation", t, func() {
+		s, err := callGetSort("f,-g", v, WithSortOption("f", SortOption{Collation: "NOCASE"}))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f COLLATE NOCASE,g DESC")
+
+		s, err = callGetSort("-f", v, WithSortOption("f", SortOption{Collation: "NOCASE"}))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f COLLATE NOCASE DESC")
+
+		_, err = callGetSort("f", v, WithSortOption("f", SortOption{Collation: "NOCASE; DROP"}))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Sorts should place NULLs as requested", t, func() {
+		s, err := callGetSort("f", v, WithSortOption("f", SortOption{Nulls: NullsLast}))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f IS NULL,f")
+
+		s, err = callGetSort("-f,g", v, WithSortOption("f", SortOption{Nulls: NullsFirst}))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f IS NULL DESC,f DESC,g")
+
+		s, err = callGetSort("f", v, WithSortOption("f", SortOption{Collation: "BINARY", Nulls: NullsLast}))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f IS NULL,f COLLATE BINARY")
+	})
+}
@@ -1,15 +1,21 @@
-// Package sql is a REST Layer resource storage handler for databases supported via
-// drivers for database/sql. It implements the Storer interface defined in
-// rest-layer/resource/storage.go.
+// Package sqlite3 is a REST Layer resource storage handler for SQLite3
+// databases, accessed through database/sql and the go-sqlite3 driver. It
+// implements the Storer interface defined in rest-layer/resource/storage.go.
+//
+// The statements built by the handler use SQLite syntax and semantics; other
+// databases supported by database/sql are not supported.
 package sqlite3
 
 import (
 	"database/sql"
 	"fmt"
+	"net/http"
+	"sort"
 
 	"golang.org/x/net/context"
 
 	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
 
 	log "github.com/Sirupsen/logrus"
 	"time"
@@ -17,126 +23,487 @@ import (
 )
 
 const (
-	SQL_NOTFOUND_ERR = "sql: no rows in result set"
+	SQL_NOTFOUND_ERR   = "sql: no rows in result set"
+	SQL_FOREIGNKEY_ERR = "FOREIGN KEY constraint failed"
+	// SQL_UNIQUE_ERR prefixes the errors of unique constraint violations
+	SQL_UNIQUE_ERR = "UNIQUE constraint failed"
+
+	// timeLayout is the layout of the timestamps stored with TimeDefault
+	timeLayout = "2006-01-02 15:04:05.99999999 -0700 MST"
 )
 
+// ErrInvalidReference is returned when an item references an item that doesn't
+// exist, like an extension item whose id has no match in the referenced table.
+// It is a 422 rest.Error, so the API answers with a client error.
+var ErrInvalidReference error = &rest.Error{Code: http.StatusUnprocessableEntity, Message: "Referenced item not found"}
+
+// rowQuerier runs single row queries on a *sql.DB, *sql.Conn or *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// querier runs queries on a *sql.DB, *sql.Conn or *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ctxErr returns the context's error if it is done, so an operation stopped by
+// a cancellation or deadline reports it instead of the resulting driver error,
+// as the resource.Storer contract requires. Otherwise it returns err.
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
 // Handler contains the session and table information for a SQL DB.
 type Handler struct {
 	session   *sql.DB
 	tableName string
+	nullMode  NullMode
+	// nullMatching makes negated comparisons match NULL columns
+	nullMatching bool
+	// equality is how Equal and NotEqual compare strings
+	equality EqualityMode
+	// inListThreshold is the size above which In lists use a temp table
+	inListThreshold int
+	idCodec         IDCodec
+	// view is the materialized view served by the handler, if any
+	view *MaterializedView
+	// timeouts are the time budgets of each operation
+	timeouts map[Operation]time.Duration
+	// requestID extracts the id written in statement comments
+	requestID RequestIDFunc
+	// resolver merges conflicting updates
+	resolver ConflictResolver
+	// tombstones records deleted items in the tombstone table
+	tombstones bool
+	etagMode   ETagMode
+	// etagFunc recomputes the etag of written items when set
+	etagFunc ETagFunc
+	sorts    map[string]SortOption
+	// countTotal makes Find count all matching rows for ItemList.Total
+	countTotal    bool
+	metaCollision MetaCollision
+	// previous is the table of the previous schema version, if any
+	previous *PreviousVersion
+	// ops tracks the operations in flight, shared by copies of the handler
+	ops *opTracker
+	// pragmas are applied to the pool connections
+	pragmas []Pragma
+	// etagCache saves the etag reads of Update and Delete
+	etagCache ETagCache
+	rowErrors RowErrorPolicy
+	storage   StorageMode
+	// columns are the fields stored in columns in StorageHybrid mode
+	columns map[string]bool
+	// allowedFilters are the operators allowed on each field in lookups, nil
+	// allows everything
+	allowedFilters map[string]map[FilterOp]bool
+	// flights coalesces identical concurrent Find statements, shared by
+	// copies of the handler
+	flights *flightGroup
+	// created caches whether the table has a created column
+	created *createdColumn
+	// ftsFields are the fields indexed for full-text search
+	ftsFields []string
+	// skipped counts the rows skipped by the row error policy, shared by
+	// copies of the handler
+	skipped *int64
+	// idempotentInsert makes inserts of already stored items succeed
+	idempotentInsert bool
+	// collector receives the measures of the operations, if set
+	collector Collector
+	// feedOrder is the key of feed pagination, empty if disabled
+	feedOrder FeedOrder
+	// maxStatementSize is the size above which statements are rejected
+	maxStatementSize int
+	// retry is the retry policy of operations failing on a locked database
+	retry RetryPolicy
+	// schema is the schema of the resource, if known
+	schema schema.Schema
+	// foldFields are the fields filtered case-insensitively through an index
+	// on their lowercased value
+	foldFields map[string]bool
+	// collations are the collating sequences of fields
+	collations map[string]string
+	// optionErr is the error of invalid options, checked once by NewHandler
+	// and returned by the operations using them
+	optionErr error
+	// clock is the source of the times set by the handler
+	clock Clock
+	// batcher groups concurrent inserts in transactions, if set; shared by
+	// copies of the handler
+	batcher *insertBatcher
+	// softDelete marks deleted rows instead of removing them
+	softDelete bool
+	// conflictDetails returns conflicts as ConflictError
+	conflictDetails bool
+	// history archives replaced and removed rows
+	history bool
+	// changes dispatches committed changes, shared by copies of the handler
+	changes *changeHub
+	// keyset paginates requests carrying a cursor from the cursor
+	keyset bool
+	// timeFormat is the format of the stored timestamps
+	timeFormat TimeFormat
+	// blobMode is how the values of BLOB columns are returned
+	blobMode BlobMode
+	// columnNames maps fields to the columns storing them, and fieldNames
+	// columns back to their field
+	columnNames map[string]string
+	fieldNames  map[string]string
+	// readDB serves the reads of Find, if set
+	readDB *sql.DB
+	// tx is the shared transaction the operations run in, if set
+	tx *sql.Tx
+	// errorSQL adds the failed statement to storage errors
+	errorSQL bool
+	// importBatch is the number of items Import inserts per transaction
+	importBatch int
+	// computed are the fields computed by SQL functions
+	computed map[string]computedField
 }
 
 // NewHandler creates an new SQL DB session handler.
-func NewHandler(s *sql.DB, tableName string) *Handler {
-	return &Handler{
-		session:   s,
-		tableName: tableName,
+func NewHandler(s *sql.DB, tableName string, opts ...Option) *Handler {
+	h := &Handler{
+		session:          s,
+		tableName:        tableName,
+		nullMatching:     DefaultNullMatching,
+		inListThreshold:  DefaultInListThreshold,
+		maxStatementSize: DefaultMaxStatementSize,
+		retry:            DefaultRetryPolicy,
+		clock:            SystemClock,
+		timeFormat:       TimeDefault,
+		countTotal:       DefaultCountTotal,
+		ops:              &opTracker{},
+		skipped:          new(int64),
+		created:          &createdColumn{},
+		changes:          &changeHub{},
+		idCodec:          DefaultIDCodec,
+		timeouts:         map[Operation]time.Duration{},
+		sorts:            map[string]SortOption{},
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	if h.optionErr = h.checkCollations(); h.optionErr == nil {
+		h.optionErr = h.checkComputed()
+	}
+	if h.optionErr != nil {
+		log.WithFields(log.Fields{
+			"table": tableName,
+			"error": h.optionErr,
+		}).Warn("Invalid handler options.")
+	}
+	if s != nil && len(h.pragmas) > 0 {
+		if err := h.configurePool(context.Background()); err != nil {
+			log.WithFields(log.Fields{
+				"table": tableName,
+				"error": err,
+			}).Warn("Error configuring the pool connections.")
+		}
+	}
+	return h
 }
 
 // Find searches for items in the backend store matching the lookup argument.
 // If no items are found, an empty list is returned with no error. If a query
 // operation is not implemented, a resource.ErrNotImplemented is returned.
 func (h *Handler) Find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*resource.ItemList, error) {
-	var q string // query string
-	var err error
-	var rows *sql.Rows // query result
-	var cols []string  // column names
-	raw := []map[string]interface{}{} // holds the raw results as a map of columns:values
+	start := time.Now()
+	var list *resource.ItemList
+	err := h.retryBusy(ctx, OpFind, func() (err error) {
+		list, err = h.find(ctx, lookup, page, perPage)
+		return err
+	})
+	rows := 0
+	if err == nil {
+		rows = len(list.Items)
+	}
+	h.observe(OpFind, start, rows, err)
+	return list, err
+}
 
-	// build a paginated select statement based
-	q, err = getSelect(h, lookup, page, perPage)
-	if err != nil {
-		log.WithField("error", err).Warn("Error getting the select statement.")
+// find runs Find.
+func (h *Handler) find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*resource.ItemList, error) {
+	if err := h.ops.begin(); err != nil {
 		return nil, err
 	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpFind)
+	defer cancel()
 
-	// execute the DB query, get the results
-	rows, err = h.session.Query(q)
+	p, err := h.planFind(ctx, lookup, page, perPage)
 	if err != nil {
-		log.WithField("error", err).Warn("Error querying the DB.")
 		return nil, err
 	}
-	defer rows.Close()
+	defer p.close()
 
-	cols, err = rows.Columns()
+	var list *resource.ItemList
+	if h.flights != nil && p.tx == nil && h.tx == nil {
+		list, err = h.flights.do(ctx, fmt.Sprintf("%d:%s", p.page, p.q), func() (*resource.ItemList, error) {
+			return runSelect(ctx, h, p.db, p.q, p.page)
+		})
+	} else {
+		list, err = runSelect(ctx, h, p.db, p.q, p.page)
+	}
+	if err != nil || !h.countTotal {
+		return list, err
+	}
+	if perPage < 0 || (p.hints.Limit <= 0 && len(list.Items) < perPage && (len(list.Items) > 0 || p.page == 1)) {
+		// the page is the last one, so the total is known without counting
+		if perPage > 0 {
+			list.Total += (p.page - 1) * perPage
+		}
+		return list, nil
+	}
+	list.Total, err = countRows(ctx, h, p.db, p.filter, p.hints)
 	if err != nil {
-		log.WithField("error", err).Warn("Error getting columns.")
 		return nil, err
 	}
+	return list, nil
+}
 
-	for rows.Next() {
-		rowMap := make(map[string]interface{})       // col:val map for a row
-		rowVals := make([]interface{}, len(cols))    // values for a row
-		rowValPtrs := make([]interface{}, len(cols)) // pointers to row values used by Scan
+// findPlan is the select statement of a Find, and what it runs on.
+type findPlan struct {
+	q      string
+	db     querier
+	filter schema.Query
+	hints  Hints
+	page   int
+	// tx holds the temporary tables of spilled In lists, if any
+	tx txConn
+}
 
-		// create the pointers to the row value elements
-		for i, _ := range cols {
-			rowValPtrs[i] = &rowVals[i]
-		}
+// close releases the transaction of the plan, if any.
+func (p *findPlan) close() {
+	if p.tx != nil {
+		p.tx.Rollback()
+	}
+}
 
-		// scan into the pointer slice (and set the values)
-		err := rows.Scan(rowValPtrs...)
-		if err != nil {
-			log.WithField("error", err).Warn("Error scanning a row.")
+// planFind checks the lookup of a Find and builds its select statement.
+func (h *Handler) planFind(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*findPlan, error) {
+	var err error
+
+	if err = h.checkFilter(lookup.Filter()); err != nil {
+		log.WithField("error", err).Warn("Rejected find filter.")
+		return nil, err
+	}
+
+	if h.view != nil {
+		if err = h.view.RefreshIfStale(ctx); err != nil {
 			return nil, err
 		}
+	}
 
-		// convert byte arrays to strings
-		for i, v := range rowVals {
-			b, ok := v.([]byte)
-			if ok {
-				v = string(b)
-			}
-			rowMap[cols[i]] = v
+	// large membership lists are moved into temporary tables, which only live
+	// as long as the transaction on their connection.
+	p := &findPlan{db: h.readConn(), filter: lookup.Filter(), page: page}
+	if threshold, ok := h.spillThreshold(p.filter); ok {
+		if p.tx, err = h.beginRead(ctx); err != nil {
+			log.WithField("error", err).Warn("Error starting find transaction.")
+			return nil, ctxErr(ctx, err)
 		}
+		p.db = p.tx
+		if p.filter, _, err = spillInLists(ctx, h, p.tx, p.filter, threshold); err != nil {
+			p.close()
+			return nil, ctxErr(ctx, err)
+		}
+	}
 
-		// add the row to the intermediate data structure
-		raw = append(raw, rowMap)
+	// build a paginated select statement based
+	p.hints = HintsFromContext(ctx)
+	order := lookup.Sort()
+	if fq, ok := FeedFromContext(ctx); ok && h.feedOrder != "" {
+		if p.filter, order, err = h.feedFilter(p.filter, fq); err != nil {
+			p.close()
+			log.WithField("error", err).Warn("Error getting the feed range.")
+			return nil, err
+		}
+		p.page = 1
+	}
+	if c, ok := CursorFromContext(ctx); ok && h.keyset {
+		if p.filter, order, err = h.keysetFilter(p.filter, order, c); err != nil {
+			p.close()
+			log.WithField("error", err).Warn("Error getting the keyset range.")
+			return nil, err
+		}
+		p.page = 1
 	}
+	p.q, err = buildSelect(h, p.filter, order, p.page, perPage, p.hints)
+	if err != nil {
+		p.close()
+		log.WithField("error", err).Warn("Error getting the select statement.")
+		return nil, h.storageErr(ctx, ErrStatementBuild, OpFind, "", err)
+	}
+	return p, nil
+}
 
-	// check for any errors during row iteration
-	err = rows.Err()
+// countRows returns the number of rows matching the filter.
+func countRows(ctx context.Context, h *Handler, db querier, filter schema.Query, hints Hints) (int, error) {
+	q, err := buildCount(h, filter, hints)
 	if err != nil {
-		log.WithField("error", err).Warn("Error during row iteration.")
-		return nil, err
+		log.WithField("error", err).Warn("Error getting the count statement.")
+		return -1, h.storageErr(ctx, ErrStatementBuild, OpFind, "", err)
+	}
+	rows, err := db.QueryContext(ctx, h.annotate(ctx, q))
+	if err != nil {
+		log.WithField("error", err).Warn("Error counting rows.")
+		return -1, h.storageErr(ctx, ErrExec, OpFind, q, err)
+	}
+	defer rows.Close()
+	var n int
+	if rows.Next() {
+		if err = rows.Scan(&n); err != nil {
+			log.WithField("error", err).Warn("Error scanning the row count.")
+			return -1, h.storageErr(ctx, ErrScan, OpFind, q, err)
+		}
 	}
+	return n, h.storageErr(ctx, ErrExec, OpFind, q, rows.Err())
+}
 
-	// return a *resource.ItemList or an error
-	return newItemList(raw, page)
+// runSelect executes a SELECT statement and converts the resulting rows to a
+// *resource.ItemList. args are bound to the statement placeholders, if any.
+func runSelect(ctx context.Context, h *Handler, db querier, q string, page int, args ...interface{}) (*resource.ItemList, error) {
+	// execute the DB query, then convert the rows to items as they are read
+	rows, err := db.QueryContext(ctx, h.annotate(ctx, q), args...)
+	if err != nil {
+		log.WithField("error", err).Warn("Error querying the DB.")
+		return nil, h.storageErr(ctx, ErrExec, OpFind, q, err)
+	}
+	it, err := newItemIterator(ctx, h, rows, q)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
 
+	items := []*resource.Item{}
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	return &resource.ItemList{Page: page, Total: len(items), Items: items}, nil
 }
 
 // Insert stores new items in the backend store. If any of the items already exist,
 // no item should be inserted and a resource.ErrConflict must be returned. The insertion
 // of the items is performed atomically.
 func (h *Handler) Insert(ctx context.Context, items []*resource.Item) error {
+	start := time.Now()
+	var err error
+	if h.batcher != nil && h.tx == nil {
+		err = h.batchInsert(ctx, items)
+	} else {
+		err = h.retryBusy(ctx, OpInsert, func() error {
+			return h.insert(ctx, items)
+		})
+	}
+	h.observe(OpInsert, start, len(items), err)
+	if err == nil {
+		h.emitItems(OpInsert, items)
+	}
+	return err
+}
+
+// insert runs Insert.
+func (h *Handler) insert(ctx context.Context, items []*resource.Item) error {
+
+	if err := h.ops.begin(); err != nil {
+		return err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpInsert)
+	defer cancel()
+	return h.insertItems(ctx, items)
+}
+
+// insertItems inserts items in a transaction.
+func (h *Handler) insertItems(ctx context.Context, items []*resource.Item) error {
+	return h.insertRows(ctx, items, false, nil)
+}
+
+// insertRows inserts items in a transaction. With ignore, items conflicting
+// with existing rows are skipped instead of failing the insert. then, if set,
+// is called in the transaction before it is committed, with the number of
+// items inserted.
+func (h *Handler) insertRows(ctx context.Context, items []*resource.Item, ignore bool, then func(tx txConn, inserted int) error) error {
+	for _, i := range items {
+		if err := h.setTimestamps(ctx, i); err != nil {
+			log.WithField("error", err).Warn("Error setting timestamps.")
+			return ctxErr(ctx, err)
+		}
+	}
 
 	// begin a database transaction
-	txPtr, err := h.session.Begin()
+	txPtr, err := h.beginTx(ctx)
 	if err != nil {
 		log.WithField("error", err).Warn("Error starting insert transaction.")
-		return err
+		return h.storageErr(ctx, ErrExec, OpInsert, "", err)
 	}
 
 	// construct and execute an insert statement for each item provided.  If anything
 	// fails, rollback the transaction and return.
+	inserted := make([]*resource.Item, 0, len(items))
 	for _, i := range items {
+		if err = h.setETag(i); err != nil {
+			txPtr.Rollback()
+			log.WithField("error", err).Warn("Error computing ETag.")
+			return err
+		}
 		s, err := getInsert(h, i)
 		if err != nil {
 			txPtr.Rollback()
 			log.WithField("error", err).Warn("Error creating insert statement.")
-			return err
+			return h.storageErr(ctx, ErrStatementBuild, OpInsert, "", err)
+		}
+		if ignore {
+			// conflicting rows are skipped instead of failing the statement
+			s = "INSERT OR IGNORE" + s[len("INSERT"):]
+		}
+		result, err := txPtr.ExecContext(ctx, h.annotate(ctx, s))
+		if err == nil && ignore {
+			var n int64
+			if n, err = result.RowsAffected(); err == nil && n == 0 {
+				continue
+			}
 		}
-		_, err = h.session.Exec(s)
 		if err != nil {
+			if err = h.replayed(ctx, txPtr, i, err); err == nil {
+				// the item was already inserted by a previous attempt
+				inserted = append(inserted, i)
+				continue
+			}
 			txPtr.Rollback()
 			log.WithField("error", err).Warn("Error executing insert statement.")
+			if isForeignKeyErr(err) {
+				return ErrInvalidReference
+			}
+			return h.storageErr(ctx, ErrExec, OpInsert, s, err)
+		}
+		inserted = append(inserted, i)
+	}
+	if then != nil {
+		if err = then(txPtr, len(inserted)); err != nil {
+			txPtr.Rollback()
 			return err
 		}
 	}
 	// inserts all succeeded, commit the transaction.
-	txPtr.Commit()
+	if err = txPtr.Commit(); err != nil {
+		log.WithField("error", err).Warn("Error committing insert transaction.")
+		return h.storageErr(ctx, ErrExec, OpInsert, "", err)
+	}
+	for _, i := range inserted {
+		h.cacheETag(i.ID, i.ETag)
+	}
 	return nil
 }
 
@@ -144,12 +511,33 @@ func (h *Handler) Insert(ctx context.Context, items []*resource.Item) error {
 // item is not found, a resource.ErrNotFound is returned. If the etags don't match, a
 // resource.ErrConflict is returned.
 func (h *Handler) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	start := time.Now()
+	err := h.retryBusy(ctx, OpUpdate, func() error {
+		return h.update(ctx, item, original)
+	})
+	h.observe(OpUpdate, start, 1, err)
+	if err == nil {
+		h.emitItems(OpUpdate, []*resource.Item{item})
+	}
+	return err
+}
+
+// update runs Update.
+func (h *Handler) update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+
+	if err := h.ops.begin(); err != nil {
+		return err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpUpdate)
+	defer cancel()
 
 	// begin a database transaction
-	txPtr, err := h.session.Begin()
+	txPtr, err := h.beginTx(ctx)
 	if err != nil {
 		log.WithField("error", err).Warn("Error starting update transaction.")
-		return err
+		return h.storageErr(ctx, ErrExec, OpUpdate, "", err)
 	}
 
 	// get the original item
@@ -160,34 +548,116 @@ func (h *Handler) Update(ctx context.Context, item *resource.Item, original *res
 	if err != nil {
 		txPtr.Rollback()
 		log.WithField("error", err).Warn("Error constructing select to retreive original record.")
-		return err
+		return h.storageErr(ctx, ErrStatementBuild, OpUpdate, "", err)
 	}
 
-	err = compareEtags(h, original.ID, original.ETag)
+	// the update statement compares exact etags itself, and only writes the
+	// row if it still matches: the affected rows tell the outcome, without a
+	// window for another writer between a check and the write. Weak etags
+	// can't be compared in SQL, they are checked first, unless the stored
+	// etag is cached: the statement then compares the cached one.
+	cas := h.etagMode != ETagWeak
+	expected := original
+	stored, cached := h.cachedETag(original.ID, original.ETag)
+	if cached {
+		cas = true
+		expected = &resource.Item{ID: original.ID, ETag: stored}
+	} else if !cas {
+		err = compareEtags(ctx, h, txPtr, original.ID, original.ETag)
+	}
+	if IsConflict(err) && h.resolver != nil {
+		item, original, err = h.resolveConflict(ctx, txPtr, s, item)
+		expected = original
+	}
 	if err != nil {
 		txPtr.Rollback()
 		log.WithField("error", err).Warn("Error comparing ETags.")
-		return err
+		return ctxErr(ctx, err)
 	}
 
-	s, err = getUpdate(h, item, original)
-	if err != nil {
+	if err = h.setETag(item); err != nil {
 		txPtr.Rollback()
-		log.WithField("error", err).Warn("Error creating update statement.")
+		log.WithField("error", err).Warn("Error computing ETag.")
 		return err
 	}
-	_, err = h.session.Exec(s)
+	if h.previous != nil {
+		// items of the previous version are moved to the current table first
+		id, err := h.idLiteral(original.ID)
+		if err == nil {
+			for _, s := range getUpgrade(h, id) {
+				if _, err = txPtr.ExecContext(ctx, h.annotate(ctx, s)); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			txPtr.Rollback()
+			log.WithField("error", err).Warn("Error upgrading previous version item.")
+			return ctxErr(ctx, err)
+		}
+	}
+	n, err := h.runUpdate(ctx, txPtr, item, expected, cas)
+	if err == nil && n == 0 && cached {
+		// the cached etag is stale: check the stored one as without a cache
+		h.cacheETag(original.ID, "")
+		if compareEtags(ctx, h, txPtr, original.ID, original.ETag) == nil {
+			n, err = h.runUpdate(ctx, txPtr, item, original, false)
+		}
+	}
+	if err == nil && n == 0 {
+		// tell a missing row from a changed one
+		err = missedWrite(ctx, h, txPtr, original.ID, original.ETag)
+		if IsConflict(err) && h.resolver != nil {
+			// merge with the version that won the race, once
+			if item, original, err = h.resolveConflict(ctx, txPtr, s, item); err == nil {
+				err = h.setETag(item)
+			}
+			if err == nil {
+				n, err = h.runUpdate(ctx, txPtr, item, original, true)
+			}
+			if err == nil && n == 0 {
+				err = resource.ErrConflict
+			}
+		}
+	}
 	if err != nil {
 		txPtr.Rollback()
-		log.WithField("error", err).Warn("Error executing update statement.")
 		return err
 	}
 
 	// update succeeded, commit the transaction.
-	txPtr.Commit()
+	if err = txPtr.Commit(); err != nil {
+		log.WithField("error", err).Warn("Error committing update transaction.")
+		return h.storageErr(ctx, ErrExec, OpUpdate, "", err)
+	}
+	h.cacheETag(item.ID, item.ETag)
 	return nil
 }
 
+// runUpdate archives the original item and replaces it with item in the
+// transaction, and returns the number of rows updated. Unless the update is a
+// compare-and-swap (cas), the row is assumed to be found.
+func (h *Handler) runUpdate(ctx context.Context, tx txConn, item *resource.Item, original *resource.Item, cas bool) (int64, error) {
+	s, err := updateStatement(h, item, original, cas && h.verifiesETags())
+	if err != nil {
+		log.WithField("error", err).Warn("Error creating update statement.")
+		return 0, h.storageErr(ctx, ErrStatementBuild, OpUpdate, "", err)
+	}
+	if err = h.archiveItem(ctx, tx, original, cas); err != nil {
+		return 0, ctxErr(ctx, err)
+	}
+	result, err := tx.ExecContext(ctx, h.annotate(ctx, s))
+	var n int64 = 1
+	if err == nil && cas {
+		n, err = result.RowsAffected()
+	}
+	if err != nil {
+		log.WithField("error", err).Warn("Error executing update statement.")
+		return 0, h.storageErr(ctx, ErrExec, OpUpdate, s, err)
+	}
+	return n, nil
+}
+
 // Delete deletes the provided item by its ID. The Etag of the item stored in the
 // backend store must match the Etag of the provided item or a resource.ErrConflict
 // must be returned. This check should be performed atomically.
@@ -199,107 +669,410 @@ func (h *Handler) Update(ctx context.Context, item *resource.Item, original *res
 // on the passed ctx. If the operation is stopped due to context cancellation, the
 // function must return the result of the ctx.Err() method.
 func (h *Handler) Delete(ctx context.Context, item *resource.Item) error {
+	start := time.Now()
+	err := h.retryBusy(ctx, OpDelete, func() error {
+		return h.delete(ctx, item)
+	})
+	h.observe(OpDelete, start, 1, err)
+	if err == nil {
+		h.emitItems(OpDelete, []*resource.Item{item})
+	}
+	return err
+}
+
+// delete runs Delete.
+func (h *Handler) delete(ctx context.Context, item *resource.Item) error {
+
+	if err := h.ops.begin(); err != nil {
+		return err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpDelete)
+	defer cancel()
 
 	// begin a transaction
-	txPtr, err := h.session.Begin()
+	txPtr, err := h.beginTx(ctx)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"id":    item.ID,
 			"error": err,
 		}).Warn("Error starting delete transaction.")
-		return err
+		return h.storageErr(ctx, ErrExec, OpDelete, "", err)
 	}
 
-	err = compareEtags(h, item.ID, item.ETag)
+	// like Update, the delete statement compares exact etags itself and the
+	// affected rows tell the outcome. Weak etags are checked first, unless
+	// the stored etag is cached.
+	cas := h.etagMode != ETagWeak
+	expected := item
+	stored, cached := h.cachedETag(item.ID, item.ETag)
+	if cached {
+		cas = true
+		expected = &resource.Item{ID: item.ID, ETag: stored}
+	} else if !cas {
+		err = compareEtags(ctx, h, txPtr, item.ID, item.ETag)
+	}
 	if err != nil {
 		txPtr.Rollback()
 		log.WithField("error", err).Warn("Error comparing ETags.")
-		return err
+		return ctxErr(ctx, err)
 	}
 
 	// prepare and execute the delete statement, then finish the transaction
-	s := fmt.Sprintf("DELETE FROM %s WHERE id = '%s'", h.tableName, item.ID)
-	stmt, err := h.session.Prepare(s)
+	id, err := h.idLiteral(item.ID)
+	if err != nil {
+		txPtr.Rollback()
+		log.WithField("error", err).Warn("Error converting ID to string.")
+		return resource.ErrNotFound
+	}
+	if h.previous != nil {
+		// items of the previous version are moved to the current table
+		// first, where the delete statement matches their etag
+		for _, u := range getUpgrade(h, id) {
+			if _, err = txPtr.ExecContext(ctx, h.annotate(ctx, u)); err != nil {
+				txPtr.Rollback()
+				log.WithField("error", err).Warn("Error upgrading previous version item.")
+				return ctxErr(ctx, err)
+			}
+		}
+	}
+	n, err := h.runDelete(ctx, txPtr, expected, id, cas)
+	if err == nil && n == 0 && cached {
+		// the cached etag is stale: check the stored one as without a cache
+		h.cacheETag(item.ID, "")
+		if compareEtags(ctx, h, txPtr, item.ID, item.ETag) == nil {
+			n, err = h.runDelete(ctx, txPtr, item, id, false)
+		}
+	}
+	if err == nil && n == 0 {
+		err = missedWrite(ctx, h, txPtr, item.ID, item.ETag)
+	}
+	if err != nil {
+		txPtr.Rollback()
+		return err
+	}
+
+	if h.tombstones {
+		etag, _ := valueToString(item.ETag)
+		_, err = txPtr.ExecContext(ctx, h.annotate(ctx, fmt.Sprintf("INSERT INTO %s(id,etag,deleted) VALUES(%s,%s,%s);",
+			tombstoneTable(h), id, etag, h.timeFormat.literal(h.clock.Now()))))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"id":    item.ID,
+				"error": err,
+			}).Warn("Error recording tombstone.")
+			txPtr.Rollback()
+			return ctxErr(ctx, err)
+		}
+	}
+
+	if err = txPtr.Commit(); err != nil {
+		log.WithField("error", err).Warn("Error committing delete transaction.")
+		return h.storageErr(ctx, ErrExec, OpDelete, "", err)
+	}
+	h.cacheETag(item.ID, "")
+	return nil
+}
+
+// runDelete archives the item with the id literal and removes it in the
+// transaction, and returns the number of rows removed. Unless the delete is a
+// compare-and-swap (cas), the row is assumed to be found.
+func (h *Handler) runDelete(ctx context.Context, tx txConn, item *resource.Item, id string, cas bool) (int64, error) {
+	where := "id = " + id
+	if cas && h.verifiesETags() {
+		etag, _ := valueToString(item.ETag)
+		where += " AND " + h.column("etag") + " = " + etag
+	}
+	s := fmt.Sprintf("DELETE FROM %s WHERE %s", h.tableName, where)
+	if h.softDelete {
+		s = h.softDeleteStatement(h.tableName, where)
+	}
+	stmt, err := tx.PrepareContext(ctx, h.annotate(ctx, s))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"id":    item.ID,
 			"error": err,
 		}).Warn("Error preparing delete statement.")
-		txPtr.Rollback()
-		return err
+		return 0, h.storageErr(ctx, ErrStatementBuild, OpDelete, s, err)
 	}
+	defer stmt.Close()
 
-	_, err = stmt.Exec()
+	if err = h.archiveItem(ctx, tx, item, cas); err != nil {
+		return 0, ctxErr(ctx, err)
+	}
+	result, err := stmt.ExecContext(ctx)
+	var n int64 = 1
+	if err == nil && cas {
+		n, err = result.RowsAffected()
+	}
 	if err != nil {
 		log.WithFields(log.Fields{
 			"id":    item.ID,
 			"error": err,
 		}).Warn("Error executing delete statement.")
-		txPtr.Rollback()
-		return err
+		return 0, h.storageErr(ctx, ErrExec, OpDelete, s, err)
 	}
-
-	txPtr.Commit()
-	return nil
+	return n, nil
 }
 
 // Clear removes all items matching the lookup and returns the number of items
 // removed as the first value.  If a query operation is not implemented
 // by the storage handler, a resource.ErrNotImplemented is returned.
 func (h *Handler) Clear(ctx context.Context, lookup *resource.Lookup) (int, error) {
+	start := time.Now()
+	n, err := h.clear(ctx, lookup)
+	h.observe(OpClear, start, n, err)
+	return n, err
+}
 
-	// construct the delete statement from the lookup data
-	s, err := getDelete(h, lookup)
+// clear runs Clear.
+func (h *Handler) clear(ctx context.Context, lookup *resource.Lookup) (int, error) {
+
+	if err := h.ops.begin(); err != nil {
+		return -1, err
+	}
+	defer h.ops.end()
+
+	ctx, cancel := h.withBudget(ctx, OpClear)
+	defer cancel()
+
+	if h.etagCache != nil {
+		// the removed ids aren't known, cached etags would only cause misses
+		defer h.etagCache.Purge()
+	}
+
+	filter := lookup.Filter()
+	if err := h.checkFilter(filter); err != nil {
+		log.WithField("error", err).Warn("Rejected clear filter.")
+		return -1, err
+	}
+
+	// the transaction takes the write lock before anything is done, so it is
+	// safe to run again when another writer holds it.
+	n := -1
+	var ids []interface{}
+	err := h.retryBusy(ctx, OpClear, func() error {
+		var err error
+		n, ids, err = h.clearInTx(ctx, filter)
+		return err
+	})
+	if err == nil && ids != nil {
+		h.changes.emit(Change{Table: h.tableName, Op: OpClear, IDs: ids})
+	}
+	return n, err
+}
+
+// clearInTx runs Clear in an immediate transaction, moving large membership
+// lists of the filter into temporary tables and recording tombstones for the
+// removed items. It returns the ids of the removed items if changes are
+// listened to.
+func (h *Handler) clearInTx(ctx context.Context, filter schema.Query) (int, []interface{}, error) {
+	var txPtr txConn
+	var err error
+	if h.tx != nil {
+		txPtr, err = h.beginTx(ctx)
+	} else {
+		txPtr, err = h.beginImmediate(ctx)
+	}
 	if err != nil {
+		log.WithField("error", err).Warn("Error starting clear transaction.")
+		return -1, nil, h.storageErr(ctx, ErrExec, OpClear, "", err)
+	}
+	var tables []string
+	if threshold, ok := h.spillThreshold(filter); ok {
+		filter, tables, err = spillInLists(ctx, h, txPtr, filter, threshold)
+		if err != nil {
+			txPtr.Rollback()
+			return -1, nil, ctxErr(ctx, err)
+		}
+	}
+	s, err := buildDelete(h, filter, HintsFromContext(ctx))
+	if err != nil {
+		txPtr.Rollback()
 		log.WithField("error", err).Warn("Error building delete statement for clear.")
-		return -1, err // should only be ErrNotImplemented
+		return -1, nil, h.storageErr(ctx, ErrStatementBuild, OpClear, "", err)
 	}
-	result, err := h.session.Exec(s)
+	if h.tombstones {
+		where, err := translateQuery(h, filter)
+		if err == nil {
+			_, err = txPtr.ExecContext(ctx, h.annotate(ctx, getTombstoneInsert(h, h.liveWhere(where), h.clock.Now())))
+		}
+		if err != nil {
+			txPtr.Rollback()
+			log.WithField("error", err).Warn("Error recording tombstones for clear.")
+			return -1, nil, ctxErr(ctx, err)
+		}
+	}
+	var ids []interface{}
+	if h.changes.active() {
+		where, err := translateQuery(h, filter)
+		if err == nil {
+			ids, err = selectIDs(ctx, h, txPtr, h.liveWhere(where))
+		}
+		if err != nil {
+			txPtr.Rollback()
+			return -1, nil, ctxErr(ctx, err)
+		}
+	}
+	if h.history {
+		where, err := translateQuery(h, filter)
+		if err == nil {
+			err = h.archive(ctx, txPtr, h.liveWhere(where))
+		}
+		if err != nil {
+			txPtr.Rollback()
+			return -1, nil, ctxErr(ctx, err)
+		}
+	}
+	var prev int64
+	if h.previous != nil {
+		// the previous version rows are matched through the current table,
+		// so they must be removed first
+		where, err := translateQuery(h, filter)
+		var result sql.Result
+		if err == nil {
+			result, err = txPtr.ExecContext(ctx, h.annotate(ctx, getPreviousDelete(h, where)))
+		}
+		if err == nil {
+			prev, err = result.RowsAffected()
+		}
+		if err != nil {
+			txPtr.Rollback()
+			log.WithField("error", err).Warn("Error executing previous version delete statement for clear.")
+			return -1, nil, ctxErr(ctx, err)
+		}
+	}
+	result, err := txPtr.ExecContext(ctx, h.annotate(ctx, s))
 	if err != nil {
+		txPtr.Rollback()
 		log.WithField("error", err).Warn("Error executing delete statement for clear.")
-		return -1, err
+		return -1, nil, h.storageErr(ctx, ErrExec, OpClear, s, err)
 	}
 	ra, err := result.RowsAffected()
 	if err != nil {
+		txPtr.Rollback()
 		log.WithField("error", err).Warn("Error getting row count for clear.")
-		return -1, nil
+		return -1, nil, err
+	}
+	if err = dropSpills(ctx, txPtr, tables); err != nil {
+		txPtr.Rollback()
+		return -1, nil, ctxErr(ctx, err)
 	}
-	return int(ra), nil
+	return int(ra + prev), ids, ctxErr(ctx, txPtr.Commit())
 }
 
 // getSelect returns a SQL SELECT statement that represents the Lookup data
 func getSelect(h *Handler, l *resource.Lookup, page, perPage int) (string, error) {
-	str := "SELECT * FROM " + h.tableName
-	q, err := getQuery(l)
+	return buildSelect(h, l.Filter(), l.Sort(), page, perPage, Hints{})
+}
+
+// buildSelect returns a SQL SELECT statement for a filter and sort, adjusted by
+// the query hints
+func buildSelect(h *Handler, filter schema.Query, sort []string, page, perPage int, hints Hints) (string, error) {
+	t, err := h.readRef(hints)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range hints.Fields {
+		if err = h.checkField(f, "projection"); err != nil {
+			return "", err
+		}
+	}
+	switch h.storage {
+	case StorageJSON:
+		// the payload fields are all read from a single column
+		hints.Fields = nil
+	case StorageHybrid:
+		if len(hints.Fields) > 0 {
+			fields := []string{ExtraColumn}
+			for _, f := range hints.Fields {
+				if h.hasColumn(f) {
+					fields = append(fields, f)
+				}
+			}
+			hints.Fields = fields
+		}
+	}
+	cols, err := hints.columns(h.metaColumnList(), h.column)
+	if err != nil {
+		return "", err
+	}
+	str := "SELECT " + cols + " FROM " + t
+	q, err := translateQuery(h, filter)
 	if err != nil {
 		log.WithField("error", err).Warn("Error building query for select statement.")
 		return "", err
 	}
-	if q != "" {
+	if q = h.liveWhere(q); q != "" {
 		str += " WHERE " + q
 	}
-	if l.Sort() != nil {
-		str += " ORDER BY " + getSort(l)
+	if sort != nil {
+		s, err := translateSort(h, sort)
+		if err != nil {
+			log.WithField("error", err).Warn("Error building sort for select statement.")
+			return "", err
+		}
+		str += " ORDER BY " + s
+	} else if rank, ok := searchRank(h, filter); ok {
+		str += " ORDER BY " + rank
 	}
 
-	if perPage >= 0 {
+	if hints.Limit > 0 {
+		str += fmt.Sprintf(" LIMIT %d", hints.Limit)
+		if perPage >= 0 {
+			str += fmt.Sprintf(" OFFSET %d", (page-1)*perPage)
+		}
+	} else if perPage >= 0 {
 		str += fmt.Sprintf(" LIMIT %d", perPage)
 		str += fmt.Sprintf(" OFFSET %d", (page-1)*perPage)
 	}
 	str += ";"
-	return str, nil
+	return str, h.checkSize(str)
+}
+
+// buildCount returns a SQL SELECT statement counting the rows matching a
+// filter, adjusted by the query hints
+func buildCount(h *Handler, filter schema.Query, hints Hints) (string, error) {
+	t, err := h.readRef(hints)
+	if err != nil {
+		return "", err
+	}
+	str := "SELECT COUNT(*) FROM " + t
+	q, err := translateQuery(h, filter)
+	if err != nil {
+		log.WithField("error", err).Warn("Error building query for count statement.")
+		return "", err
+	}
+	if q = h.liveWhere(q); q != "" {
+		str += " WHERE " + q
+	}
+	return str + ";", nil
 }
 
 // getDelete returns a SQL DELETE statement that represents the Lookup data
 func getDelete(h *Handler, l *resource.Lookup) (string, error) {
-	str := "DELETE FROM " + h.tableName + " WHERE "
-	q, err := getQuery(l)
+	return buildDelete(h, l.Filter(), Hints{})
+}
+
+// buildDelete returns a SQL DELETE statement for a filter, adjusted by the
+// query hints
+func buildDelete(h *Handler, filter schema.Query, hints Hints) (string, error) {
+	t, err := hints.tableRef(h.tableName)
+	if err != nil {
+		return "", err
+	}
+	str := "DELETE FROM " + t + " WHERE "
+	q, err := translateQuery(h, filter)
 	if err != nil {
 		log.WithField("error", err).Warn("Error building query for delete statement.")
 		return "", err
 	}
 	str += q + ";"
-	return str, nil
+	if h.softDelete {
+		str = h.softDeleteStatement(t, q)
+	}
+	return str, h.checkSize(str)
 }
 
 // getInsert returns a SQL INSERT statement constructed from the Item data
@@ -312,17 +1085,47 @@ func getInsert(h *Handler, i *resource.Item) (string, error) {
 		log.WithField("error", err).Warn("Error converting ETag to string.")
 		return "", resource.ErrNotImplemented
 	}
-	upd, err = valueToString(i.Updated)
-	if err != nil {
-		log.WithField("error", err).Warn("Error converting Updated to string.")
-		return "", resource.ErrNotImplemented
+	upd = h.timeFormat.literal(i.Updated)
+	meta, vals := h.column("etag")+","+h.column("updated"), etag+","+upd
+	if h.etagMode == ETagNone {
+		meta, vals = h.column("updated"), upd
 	}
-	a := fmt.Sprintf("INSERT INTO %s(etag,updated,", h.tableName)
-	z := fmt.Sprintf("VALUES(%s,%s,", etag, upd)
-	for k, v := range i.Payload {
+	if h.storage == StorageJSON {
+		id, err := h.idLiteral(i.ID)
+		if err != nil {
+			log.WithField("error", err).Warn("Error converting ID to string.")
+			return "", resource.ErrNotImplemented
+		}
+		p, err := h.payloadLiteral(i.Payload)
+		if err != nil {
+			log.WithField("error", err).Warn("Error encoding payload.")
+			return "", err
+		}
+		result := fmt.Sprintf("INSERT INTO %s(%s,id,%s) VALUES(%s,%s,%s);",
+			h.tableName, meta, PayloadColumn, vals, id, p)
+		return result, h.checkSize(result)
+	}
+	a := fmt.Sprintf("INSERT INTO %s(%s,", h.tableName, meta)
+	z := fmt.Sprintf("VALUES(%s,", vals)
+	for _, k := range sortedKeys(i.Payload) {
 		var val string
-		a += k + ","
-		val, err = valueToString(v)
+		if !h.hasColumn(k) {
+			continue
+		}
+		col, skip, err := h.payloadColumn(k)
+		if err != nil {
+			log.WithField("error", err).Warn("Error mapping payload field to column.")
+			return "", err
+		}
+		if skip {
+			continue
+		}
+		a += col + ","
+		if k == "id" {
+			val, err = h.idLiteral(i.Payload[k])
+		} else {
+			val, err = h.valueLiteral(i.Payload[k])
+		}
 		if err != nil {
 			log.WithFields(log.Fields{
 				"key":    k,
@@ -332,20 +1135,35 @@ func getInsert(h *Handler, i *resource.Item) (string, error) {
 		}
 		z += val + ","
 	}
+	if h.storage == StorageHybrid {
+		extra, err := h.payloadLiteral(i.Payload)
+		if err != nil {
+			log.WithField("error", err).Warn("Error encoding extra fields.")
+			return "", err
+		}
+		a += ExtraColumn + ","
+		z += extra + ","
+	}
 	// remove trailing commas
 	a = a[:len(a)-1] + ")"
 	z = z[:len(z)-1] + ")"
 
 	result := fmt.Sprintf("%s %s;", a, z)
-	return result, nil
+	return result, h.checkSize(result)
 }
 
 // getUpdate returns a SQL INSERT statement constructed from the Item data
 func getUpdate(h *Handler, i *resource.Item, o *resource.Item) (string, error) {
+	return updateStatement(h, i, o, h.etagMode == ETagExact)
+}
+
+// updateStatement returns the statement of getUpdate, only writing the row if
+// its etag is the one of o when matchETag is set.
+func updateStatement(h *Handler, i *resource.Item, o *resource.Item, matchETag bool) (string, error) {
 	var id, oEtag, iEtag, upd string
 	var err error
 
-	id, err = valueToString(o.ID)
+	id, err = h.idLiteral(o.ID)
 	if err != nil {
 		log.WithField("error", err).Warn("Error converting ID to string.")
 		return "", resource.ErrNotImplemented
@@ -360,17 +1178,42 @@ func getUpdate(h *Handler, i *resource.Item, o *resource.Item) (string, error) {
 		log.WithField("error", err).Warn("Error converting new ETag to string.")
 		return "", resource.ErrNotImplemented
 	}
-	upd, err = valueToString(i.Updated)
-	if err != nil {
-		log.WithField("error", err).Warn("Error converting Updated to string.")
-		return "", resource.ErrNotImplemented
+	upd = h.timeFormat.literal(i.Updated)
+	verb := "UPDATE OR ROLLBACK"
+	if h.tx != nil {
+		// rolling back would end the shared transaction, not the operation
+		verb = "UPDATE"
 	}
-	a := fmt.Sprintf("UPDATE OR ROLLBACK %s SET etag=%s,updated=%s,", h.tableName, iEtag, upd)
-	z := fmt.Sprintf("WHERE id=%s AND etag=%s;", id, oEtag)
-	for k, v := range i.Payload {
-		if k != "id" {
+	a := fmt.Sprintf("%s %s SET %s=%s,%s=%s,", verb, h.tableName, h.column("etag"), iEtag, h.column("updated"), upd)
+	if h.etagMode == ETagNone {
+		a = fmt.Sprintf("%s %s SET %s=%s,", verb, h.tableName, h.column("updated"), upd)
+	}
+	where := fmt.Sprintf("id=%s AND %s=%s", id, h.column("etag"), oEtag)
+	if !matchETag {
+		// the etag was already verified (or deliberately not) by compareEtags
+		where = fmt.Sprintf("id=%s", id)
+	}
+	z := "WHERE " + h.liveWhere(where) + ";"
+	if h.storage == StorageJSON {
+		p, err := h.payloadLiteral(i.Payload)
+		if err != nil {
+			log.WithField("error", err).Warn("Error encoding payload.")
+			return "", err
+		}
+		return fmt.Sprintf("%s%s=%s %s", a, PayloadColumn, p, z), nil
+	}
+	for _, k := range sortedKeys(i.Payload) {
+		if k != "id" && h.hasColumn(k) {
+			col, skip, err := h.payloadColumn(k)
+			if err != nil {
+				log.WithField("error", err).Warn("Error mapping payload field to column.")
+				return "", err
+			}
+			if skip {
+				continue
+			}
 			var val string
-			val, err = valueToString(v)
+			val, err = h.valueLiteral(i.Payload[k])
 			if err != nil {
 				log.WithFields(log.Fields{
 					"key":    k,
@@ -378,70 +1221,128 @@ func getUpdate(h *Handler, i *resource.Item, o *resource.Item) (string, error) {
 				}).Warn("Error converting payload value to string.", )
 				return "", resource.ErrNotImplemented
 			}
-			a += fmt.Sprintf("%s=%s,", k, val)
+			a += fmt.Sprintf("%s=%s,", col, val)
 		}
 
 	}
+	// clear the columns of fields removed from the item
+	if h.nullMode == NullExplicit {
+		for _, k := range sortedKeys(o.Payload) {
+			if _, found := i.Payload[k]; !found && k != "id" && h.hasColumn(k) {
+				col, skip, _ := h.payloadColumn(k)
+				if !skip && col != "" {
+					a += fmt.Sprintf("%s=NULL,", col)
+				}
+			}
+		}
+	}
+	if h.storage == StorageHybrid {
+		extra, err := h.payloadLiteral(i.Payload)
+		if err != nil {
+			log.WithField("error", err).Warn("Error encoding extra fields.")
+			return "", err
+		}
+		a += fmt.Sprintf("%s=%s,", ExtraColumn, extra)
+	}
 	// remove trailing comma
 	a = a[:len(a)-1]
 
 	result := fmt.Sprintf("%s %s", a, z)
-	return result, nil
+	return result, h.checkSize(result)
 }
 
-// newItemList creates a list of resource.Item from a SQL result row slice
-func newItemList(rows []map[string]interface{}, page int) (*resource.ItemList, error) {
+// formatTime formats a time in the TimeDefault layout, in UTC so stored
+// values sort chronologically, for values converted outside a handler.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
 
-	items := make([]*resource.Item, len(rows))
-	l := &resource.ItemList{Page: page, Total: len(rows), Items: items}
-	for i, r := range rows {
-		item, err := newItem(r)
-		if err != nil {
-			log.WithField("error", err).Warn("Error creating an Item from a row.")
-			return nil, err
-		}
-		items[i] = item
+// sortedKeys returns the keys of a payload in a stable order, so generated
+// statements are deterministic.
+func sortedKeys(p map[string]interface{}) []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
 	}
-	return l, nil
+	sort.Strings(keys)
+	return keys
 }
 
 // newItem creates resource.Item from a SQL result row
-func newItem(row map[string]interface{}) (*resource.Item, error) {
+func newItem(h *Handler, row map[string]interface{}) (*resource.Item, error) {
 	// Add the id back (we use the same map hoping the mongoItem won't be stored back)
-	id := row["id"]
-	etag := row["etag"]
-	created := row["created"]
-	updated := row["updated"]
+	id, err := h.idCodec.Decode(row["id"])
+	if err != nil {
+		log.WithField("error", err).Warn("Error decoding id.")
+		return nil, err
+	}
+	h.renameColumns(row)
+	if err = h.mergePayload(row); err != nil {
+		return nil, err
+	}
+	etag, ok := row["etag"].(string)
+	if !ok && h.etagMode != ETagNone {
+		return nil, fmt.Errorf("sqlite3: invalid etag: %v", row["etag"])
+	}
+	created, updated := row["created"], row["updated"]
+	row["id"] = id
 	delete(row, "etag")
+	delete(row, DeletedColumn)
 	delete(row, "updated")
+	h.restoreNamespaced(row)
 
-	ct, err := time.Parse("2006-01-02 15:04:05.99999999 -0700 MST", created.(string))
-	if err != nil {
-		log.WithField("error", err).Warn("Error parsing updated.")
-		return nil, err
+	if created != nil {
+		ct, err := h.timeFormat.parse(created)
+		if err != nil {
+			log.WithField("error", err).Warn("Error parsing created.")
+			return nil, err
+		}
+		row["created"] = ct
 	}
-	row["created"] = ct
 
-	tu, err := time.Parse("2006-01-02 15:04:05.99999999 -0700 MST", updated.(string))
-	if err != nil {
-		log.WithField("error", err).Warn("Error parsing updated.")
-		return nil, err
+	// a NULL updated, from a row written outside the handler, is left zero
+	var tu time.Time
+	if updated != nil {
+		if tu, err = h.timeFormat.parse(updated); err != nil {
+			log.WithField("error", err).Warn("Error parsing updated.")
+			return nil, err
+		}
 	}
 	return &resource.Item{
 		ID:      id,
-		ETag:    etag.(string),
+		ETag:    etag,
 		Updated: tu,
 		Payload: row,
 	}, nil
 }
 
+// missedWrite is called when a write comparing etags in SQL matched no row.
+// It reads the row to report resource.ErrNotFound or resource.ErrConflict.
+func missedWrite(ctx context.Context, h *Handler, db rowQuerier, id interface{}, etag string) error {
+	if err := compareEtags(ctx, h, db, id, etag); err != nil {
+		return err
+	}
+	// the row was changed back in the meantime, the write still lost the race
+	return resource.ErrConflict
+}
 
-func compareEtags(h *Handler, id, origEtag interface{}) error {
+func compareEtags(ctx context.Context, h *Handler, db rowQuerier, id interface{}, origEtag string) error {
 	// query for record with the same id, and return ErrNotFound if we don't find one.
 	var etag string
 	var err error
-	err = h.session.QueryRow(
-		fmt.Sprintf("SELECT etag FROM %s WHERE id='%v'", h.tableName, id)).Scan(&etag)
+	lit, err := h.idLiteral(id)
+	if err != nil {
+		// an id the codec can't encode can't be stored either
+		return resource.ErrNotFound
+	}
+	col := h.column("etag")
+	if h.etagMode == ETagNone {
+		// only check the row exists
+		col = "''"
+	}
+	var updated sql.NullString
+	err = db.QueryRowContext(ctx,
+		h.annotate(ctx, fmt.Sprintf("SELECT %s,%s FROM %s WHERE %s", col, h.column("updated"), h.readSource(), h.liveWhere("id="+lit)))).Scan(&etag, &updated)
 	if err != nil {
 		switch {
 		case err.Error() == SQL_NOTFOUND_ERR:
@@ -455,13 +1356,17 @@ func compareEtags(h *Handler, id, origEtag interface{}) error {
 		}
 	}
 
+	if h.useETagCache() {
+		h.etagCache.Set(lit, etag)
+	}
+
 	// compare the etags to ensure that someone else hasn't scooped us.
-	if etag != origEtag {
+	if !h.etagsMatch(etag, origEtag) {
 		log.WithFields(log.Fields{
 			"id":    id,
 			"error": err,
 		}).Warn("ETag of record does not match the one supplied.")
-		return resource.ErrConflict
+		return h.conflict(id, etag, updated)
 	}
 
 	return nil
@@ -14,7 +14,6 @@ import (
 	"testing"
 
 	"database/sql"
-	_ "github.com/mattn/go-sqlite3"
 
 	"golang.org/x/net/context"
 
@@ -27,7 +26,7 @@ import (
 )
 
 const (
-	DB_DRIVER   = "sqlite3"
+	DB_DRIVER   = DriverName
 	DB_FILE     = "./test.db"
 	DB_TABLE    = "testtable"
 	DB_UP_DDL   = "CREATE TABLE `" + DB_TABLE + "` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`f1` VARCHAR(128),`f2` INTEGER);"
@@ -143,12 +142,40 @@ func TestModel(t *testing.T) {
 				})
 			})
 
+			Convey("Values containing quotes should round trip", func() {
+				var i3, _ = item("O'Brien", 3)
+				So(h.Insert(context.Background(), []*resource.Item{i3}), ShouldBeNil)
+
+				l := resource.NewLookup()
+				l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "O'Brien"}})
+				result, err := h.Find(context.Background(), l, 1, 10)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, 1)
+				So(result.Items[0].Payload["f1"], ShouldEqual, "O'Brien")
+			})
+
+			Convey("Inserting an extension of a missing item should return ErrInvalidReference", func() {
+				db, err := sql.Open(DB_DRIVER, DB_FILE+"?_foreign_keys=1")
+				So(err, ShouldBeNil)
+				defer db.Close()
+				db.Exec("DROP TABLE `exttable`;")
+				_, err = db.Exec("CREATE TABLE `exttable` (" + IDColumnDDL(schema.Schema{"id": schema.Field{Validator: &schema.Reference{Path: DB_TABLE}}}) + ",`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128));")
+				So(err, ShouldBeNil)
+				eh := NewHandler(db, "exttable")
+
+				ext, _ := resource.NewItem(map[string]interface{}{"id": i1.ID, "created": "2006-01-02 15:04:05.99999999 -0700 MST"})
+				So(eh.Insert(context.Background(), []*resource.Item{ext}), ShouldBeNil)
+
+				ext, _ = resource.NewItem(map[string]interface{}{"id": "missing", "created": "2006-01-02 15:04:05.99999999 -0700 MST"})
+				So(eh.Insert(context.Background(), []*resource.Item{ext}), ShouldEqual, ErrInvalidReference)
+			})
+
 			Convey("SELECT statements should be correct", func() {
 				q := schema.Query{schema.Equal{Field: "f1", Value: "foo"}}
 				v := schema.Schema{"id": schema.IDField, "f1": schema.Field{Sortable: true}}
 				s, err := callGetSelect(h, q, "-f1,f1", v, 1, -1)
 				So(err, ShouldBeNil)
-				So(s, ShouldEqual, "SELECT * FROM "+h.tableName+" WHERE f1 LIKE 'foo' ESCAPE '\\' ORDER BY f1 DESC,f1;")
+				So(s, ShouldEqual, "SELECT * FROM "+h.tableName+" WHERE f1 = 'foo' ORDER BY f1 DESC,f1;")
 			})
 
 
@@ -157,7 +184,7 @@ func TestModel(t *testing.T) {
 				v := schema.Schema{"id": schema.IDField, "f1": schema.Field{Sortable: true}}
 				s, err := callGetSelect(h, q, "-f1,f1", v, 1, 10)
 				So(err, ShouldBeNil)
-				So(s, ShouldEqual, "SELECT * FROM "+h.tableName+" WHERE f1 LIKE 'foo' ESCAPE '\\' ORDER BY f1 DESC,f1 LIMIT 10 OFFSET 0;")
+				So(s, ShouldEqual, "SELECT * FROM "+h.tableName+" WHERE f1 = 'foo' ORDER BY f1 DESC,f1 LIMIT 10 OFFSET 0;")
 			})
 
 			Convey("UPDATE statements should be correct", func() {
@@ -177,12 +204,52 @@ func TestModel(t *testing.T) {
 				So(u, ShouldEqual, "UPDATE OR ROLLBACK "+h.tableName+" SET etag="+etag+",updated="+upd+",f1='foo',f2=1 WHERE id="+id+" AND etag="+etag+";")
 			})
 
+			Convey("UPDATE statements should clear removed fields in NullExplicit mode", func() {
+				nh := NewHandler(h.session, DB_TABLE, WithNullMode(NullExplicit))
+				var testItem, _ = item("foo", 1)
+				delete(testItem.Payload, "created")
+				var updated, _ = item("foo", 1)
+				delete(updated.Payload, "created")
+				delete(updated.Payload, "f2")
+				updated.Payload["f1"] = nil
+
+				id, _ := valueToString(testItem.ID)
+				oEtag, _ := valueToString(testItem.ETag)
+				iEtag, _ := valueToString(updated.ETag)
+				upd, _ := valueToString(updated.Updated)
+				u, err := getUpdate(nh, updated, testItem)
+				So(err, ShouldBeNil)
+				So(u, ShouldEqual, "UPDATE OR ROLLBACK "+h.tableName+" SET etag="+iEtag+",updated="+upd+",f1=NULL,f2=NULL WHERE id="+id+" AND etag="+oEtag+";")
+			})
+
+			Convey("NULL columns should be omitted from payloads in NullExplicit mode", func() {
+				nh := NewHandler(h.session, DB_TABLE, WithNullMode(NullExplicit))
+				var i3, _ = item("baz", 3)
+				i3.Payload["f1"] = nil
+				So(nh.Insert(context.Background(), []*resource.Item{i3}), ShouldBeNil)
+
+				l := resource.NewLookup()
+				l.AddQuery(schema.Query{schema.Equal{Field: "f2", Value: 3}})
+				result, err := nh.Find(context.Background(), l, 1, 10)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, 1)
+				_, found := result.Items[0].Payload["f1"]
+				So(found, ShouldBeFalse)
+
+				result, err = h.Find(context.Background(), l, 1, 10)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, 1)
+				f1, found := result.Items[0].Payload["f1"]
+				So(found, ShouldBeTrue)
+				So(f1, ShouldBeNil)
+			})
+
 			Convey("DELETE statements should be correct", func() {
 				q := schema.Query{schema.Equal{Field: "f1", Value: "foo"}}
 				So(err, ShouldBeNil)
 				s, err := callGetDelete(h, q)
 				So(err, ShouldBeNil)
-				So(s, ShouldEqual, "DELETE FROM "+h.tableName+" WHERE f1 LIKE 'foo' ESCAPE '\\';")
+				So(s, ShouldEqual, "DELETE FROM "+h.tableName+" WHERE f1 = 'foo';")
 			})
 
 		})
@@ -194,3 +261,184 @@ func TestModel(t *testing.T) {
 		//})
 	})
 }
+
+// TestCancellation tests that operations on a canceled context return its error.
+func TestCancellation(t *testing.T) {
+	Convey("Given a canceled context", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Convey("Operations should return the context error", func() {
+			_, err := h.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldEqual, context.Canceled)
+			So(h.Insert(ctx, []*resource.Item{i2}), ShouldEqual, context.Canceled)
+			So(h.Update(ctx, i1, i1), ShouldEqual, context.Canceled)
+			So(h.Delete(ctx, i1), ShouldEqual, context.Canceled)
+			_, err = h.Clear(ctx, resource.NewLookup())
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("Nothing should have been changed", func() {
+			h.Delete(ctx, i1)
+			h.Clear(ctx, resource.NewLookup())
+			result, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+		})
+	})
+}
+
+// TestTotal tests that Find reports the number of items matching the lookup.
+func TestTotal(t *testing.T) {
+	Convey("Given five stored items", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		for n := 0; n < 5; n++ {
+			it, _ := item("foo", n)
+			So(h.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+		}
+
+		Convey("Total should count all matching items", func() {
+			for page, size := range map[int]int{1: 2, 2: 2, 3: 1, 4: 0} {
+				result, err := h.Find(context.Background(), resource.NewLookup(), page, 2)
+				So(err, ShouldBeNil)
+				So(len(result.Items), ShouldEqual, size)
+				So(result.Total, ShouldEqual, 5)
+			}
+
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: 1}})
+			result, err := h.Find(context.Background(), l, 1, 2)
+			So(err, ShouldBeNil)
+			So(result.Total, ShouldEqual, 3)
+
+			result, err = h.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(result.Total, ShouldEqual, 5)
+		})
+
+		Convey("Total should be the page size without counting", func() {
+			nh := NewHandler(h.session, DB_TABLE, WithCountTotal(false))
+			result, err := nh.Find(context.Background(), resource.NewLookup(), 1, 2)
+			So(err, ShouldBeNil)
+			So(result.Total, ShouldEqual, 2)
+		})
+
+		Convey("COUNT statements should be correct", func() {
+			s, err := buildCount(h, schema.Query{schema.Equal{Field: "f2", Value: 1}}, Hints{IndexedBy: "idx"})
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "SELECT COUNT(*) FROM testtable INDEXED BY idx WHERE f2 IS 1;")
+		})
+	})
+}
+
+func TestUpdateCompareAndSwap(t *testing.T) {
+	Convey("Given a stored item", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+		updated, _ := resource.NewItem(map[string]interface{}{"id": i1.ID, "created": i1.Payload["created"], "f1": "new", "f2": 1})
+
+		Convey("Update should only write over the original etag", func() {
+			So(h.Update(context.Background(), updated, i1), ShouldBeNil)
+			So(h.Update(context.Background(), updated, i1), ShouldEqual, resource.ErrConflict)
+			var etag string
+			So(h.session.QueryRow("SELECT etag FROM "+DB_TABLE+" WHERE id = ?", i1.ID).Scan(&etag), ShouldBeNil)
+			So(etag, ShouldEqual, updated.ETag)
+		})
+
+		Convey("Update of a missing item should return ErrNotFound", func() {
+			missing := *i1
+			missing.ID = "missing"
+			So(h.Update(context.Background(), updated, &missing), ShouldEqual, resource.ErrNotFound)
+		})
+
+		Convey("Weak etags should still be compared", func() {
+			wh := NewHandler(h.session, DB_TABLE, WithETagMode(ETagWeak))
+			weak := *i1
+			weak.ETag = `W/"` + i1.ETag + `"`
+			So(wh.Update(context.Background(), updated, &weak), ShouldBeNil)
+			So(wh.Update(context.Background(), updated, &weak), ShouldEqual, resource.ErrConflict)
+		})
+	})
+}
+
+func TestDeleteCompareAndSwap(t *testing.T) {
+	Convey("Given a stored item", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		Convey("Delete should only remove the original etag", func() {
+			stale := *i1
+			stale.ETag = "stale"
+			So(h.Delete(context.Background(), &stale), ShouldEqual, resource.ErrConflict)
+			So(h.Delete(context.Background(), i1), ShouldBeNil)
+			So(h.Delete(context.Background(), i1), ShouldEqual, resource.ErrNotFound)
+		})
+
+		Convey("Without etag verification, Delete should only need the row", func() {
+			sh := NewHandler(h.session, DB_TABLE, WithETagMode(ETagSkipVerify))
+			stale := *i1
+			stale.ETag = "stale"
+			So(sh.Delete(context.Background(), &stale), ShouldBeNil)
+			So(sh.Delete(context.Background(), &stale), ShouldEqual, resource.ErrNotFound)
+		})
+	})
+}
+
+func TestInsertAtomicity(t *testing.T) {
+	Convey("Given a stored item", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		Convey("A failing Insert should insert none of its items", func() {
+			fresh, _ := item("fresh", 3)
+			dup, _ := item("dup", 4)
+			dup.ID = i1.ID
+			dup.Payload["id"] = i1.ID
+			So(h.Insert(context.Background(), []*resource.Item{fresh, dup}), ShouldEqual, resource.ErrConflict)
+
+			result, err := h.Find(context.Background(), resource.NewLookup(), 1, -1)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+			So(result.Items[0].ID, ShouldEqual, i1.ID)
+		})
+
+		Convey("A failing Update should leave the history untouched", func() {
+			hh := NewHandler(h.session, DB_TABLE, WithHistory())
+			h.session.Exec("DROP TABLE `" + historyTable(hh) + "`;")
+			So(hh.CreateHistoryTable(context.Background()), ShouldBeNil)
+			So(h.Insert(context.Background(), []*resource.Item{i2}), ShouldBeNil)
+			_, err := h.session.Exec("CREATE UNIQUE INDEX `testtable_f1` ON `" + DB_TABLE + "` (`f1`);")
+			So(err, ShouldBeNil)
+
+			// the row is archived before the update fails on the unique index
+			updated, _ := resource.NewItem(map[string]interface{}{"id": i1.ID, "created": i1.Payload["created"], "f1": "bar", "f2": 1})
+			So(hh.Update(context.Background(), updated, i1), ShouldEqual, resource.ErrConflict)
+			versions, err := hh.History(context.Background(), i1.ID)
+			So(err, ShouldBeNil)
+			So(versions, ShouldBeEmpty)
+		})
+	})
+}
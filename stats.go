@@ -0,0 +1,174 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rs/rest-layer/resource"
+)
+
+// StatsHandler is a read-only resource storage handler reporting storage
+// statistics of the tables of a SQLite3 database, one item per table. Bind it
+// to an admin resource to monitor the database through the REST API. The item
+// ID is the table name, and the payload has:
+//
+//	name        the table name
+//	rows        the number of rows
+//	size        the bytes used by the table, if the dbstat table is available
+//	index_size  the bytes used by the indexes of the table, same condition
+//	last_write  the latest updated time, for tables with an updated column
+//
+// Sizes come from the dbstat virtual table, which SQLite only has when built
+// with SQLITE_ENABLE_DBSTAT_VTAB (CGO_CFLAGS=-DSQLITE_ENABLE_DBSTAT_VTAB with
+// go-sqlite3). Without it, they are left out.
+type StatsHandler struct {
+	session *sql.DB
+	// TimeFormat is the format of the updated columns, as set with
+	// WithTimeFormat on the handlers of the tables. The zero value is
+	// TimeDefault.
+	TimeFormat TimeFormat
+}
+
+// NewStatsHandler creates a new storage statistics handler for the database.
+func NewStatsHandler(s *sql.DB) *StatsHandler {
+	return &StatsHandler{session: s}
+}
+
+// Find returns the statistics of the tables matching the lookup.
+func (h *StatsHandler) Find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*resource.ItemList, error) {
+	tables, err := tableNames(ctx, h.session)
+	if err != nil {
+		return nil, err
+	}
+	sizes, hasSizes := tableSizes(ctx, h.session)
+
+	items := []*resource.Item{}
+	for _, t := range tables {
+		p, err := tableStats(ctx, h.session, t, h.TimeFormat)
+		if err != nil {
+			return nil, err
+		}
+		if hasSizes {
+			p["size"] = sizes[t].table
+			p["index_size"] = sizes[t].indexes
+		}
+		if !lookup.Filter().Match(p) {
+			continue
+		}
+		item, err := resource.NewItem(p)
+		if err != nil {
+			log.WithField("error", err).Warn("Error creating an Item from table statistics.")
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return pageItems(items, page, perPage), nil
+}
+
+// Insert is not supported by the statistics handler.
+func (h *StatsHandler) Insert(ctx context.Context, items []*resource.Item) error {
+	return resource.ErrNotImplemented
+}
+
+// Update is not supported by the statistics handler.
+func (h *StatsHandler) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	return resource.ErrNotImplemented
+}
+
+// Delete is not supported by the statistics handler.
+func (h *StatsHandler) Delete(ctx context.Context, item *resource.Item) error {
+	return resource.ErrNotImplemented
+}
+
+// Clear is not supported by the statistics handler.
+func (h *StatsHandler) Clear(ctx context.Context, lookup *resource.Lookup) (int, error) {
+	return 0, resource.ErrNotImplemented
+}
+
+// pageItems returns the page of a list of items.
+func pageItems(items []*resource.Item, page, perPage int) *resource.ItemList {
+	total := len(items)
+	if perPage >= 0 {
+		start := (page - 1) * perPage
+		if start > len(items) {
+			start = len(items)
+		}
+		end := start + perPage
+		if end > len(items) {
+			end = len(items)
+		}
+		items = items[start:end]
+	}
+	return &resource.ItemList{Page: page, Total: total, Items: items}
+}
+
+// tableSize is the space used by a table and its indexes, in bytes.
+type tableSize struct {
+	table   int64
+	indexes int64
+}
+
+// tableSizes returns the space used by each table, read from the dbstat
+// virtual table. ok is false if the table is not available.
+func tableSizes(ctx context.Context, db *sql.DB) (sizes map[string]tableSize, ok bool) {
+	rows, err := db.QueryContext(ctx, "SELECT m.tbl_name, m.type, SUM(s.pgsize) FROM dbstat s JOIN sqlite_master m ON m.name = s.name GROUP BY m.tbl_name, m.type;")
+	if err != nil {
+		log.WithField("error", err).Debug("Table sizes are not available.")
+		return nil, false
+	}
+	defer rows.Close()
+
+	sizes = map[string]tableSize{}
+	for rows.Next() {
+		var name, typ string
+		var size int64
+		if err := rows.Scan(&name, &typ, &size); err != nil {
+			log.WithField("error", err).Warn("Error scanning table size.")
+			return nil, false
+		}
+		s := sizes[name]
+		if typ == "index" {
+			s.indexes += size
+		} else {
+			s.table += size
+		}
+		sizes[name] = s
+	}
+	return sizes, rows.Err() == nil
+}
+
+// tableStats returns the row count and last write time of a table as an item
+// payload, reading the updated column in the given format.
+func tableStats(ctx context.Context, db *sql.DB, table string, f TimeFormat) (map[string]interface{}, error) {
+	cols, err := columnTypes(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf("SELECT COUNT(*), NULL FROM `%s`;", table)
+	if _, found := cols["updated"]; found {
+		q = fmt.Sprintf("SELECT COUNT(*), MAX(updated) FROM `%s`;", table)
+	}
+	var count int
+	var updated interface{}
+	if err = db.QueryRowContext(ctx, q).Scan(&count, &updated); err != nil {
+		log.WithFields(log.Fields{
+			"table": table,
+			"error": err,
+		}).Warn("Error reading table statistics.")
+		return nil, ctxErr(ctx, err)
+	}
+	p := map[string]interface{}{
+		"id":   table,
+		"name": table,
+		"rows": count,
+	}
+	if updated != nil {
+		if t, err := f.parse(updated); err == nil {
+			p["last_write"] = t
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,72 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatsHandler(t *testing.T) {
+	Convey("Given a statistics handler on the test database", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		i1, _ := item("foo", 1)
+		i2, _ := item("bar", 2)
+		i2.Updated = i1.Updated.Add(time.Hour)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		sh := NewStatsHandler(h.session)
+
+		Convey("Find should report the test table statistics", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "name", Value: DB_TABLE}})
+			list, err := sh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			p := list.Items[0].Payload
+			So(list.Items[0].ID, ShouldEqual, DB_TABLE)
+			So(p["rows"], ShouldEqual, 2)
+			So(p["last_write"], ShouldEqual, i2.Updated.UTC())
+			if _, ok := tableSizes(context.Background(), h.session); ok {
+				So(p["size"], ShouldBeGreaterThan, 0)
+			} else {
+				So(p, ShouldNotContainKey, "size")
+			}
+		})
+
+		Convey("Find should read the last write time in the handler's format", func() {
+			_, err := h.session.Exec("CREATE TABLE unixtimes (id VARCHAR(128) PRIMARY KEY, updated INTEGER);")
+			So(err, ShouldBeNil)
+			defer h.session.Exec("DROP TABLE unixtimes;")
+			_, err = h.session.Exec("INSERT INTO unixtimes VALUES ('a', 1451703845600), ('b', 1451703845000);")
+			So(err, ShouldBeNil)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "name", Value: "unixtimes"}})
+			sh.TimeFormat = TimeUnixMilli
+			list, err := sh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items, ShouldHaveLength, 1)
+			So(list.Items[0].Payload["last_write"], ShouldEqual, time.Date(2016, 1, 2, 3, 4, 5, 600000000, time.UTC))
+		})
+
+		Convey("Find should stop when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := sh.Find(ctx, resource.NewLookup(), 1, 10)
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("Mutations should not be implemented", func() {
+			So(sh.Insert(context.Background(), []*resource.Item{i1}), ShouldEqual, resource.ErrNotImplemented)
+			_, err := sh.Clear(context.Background(), resource.NewLookup())
+			So(err, ShouldEqual, resource.ErrNotImplemented)
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package sqlite3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/rest-layer/schema"
+)
+
+// StorageMode sets how item payloads are laid out in the handler's table.
+type StorageMode int
+
+const (
+	// StorageColumns stores each payload field in a column of the same name.
+	// This is the default.
+	StorageColumns StorageMode = iota
+	// StorageJSON stores the whole payload, but the id, as a JSON object in
+	// the PayloadColumn text column, next to the id, etag and updated
+	// columns. Fields don't need a column of their own; filters and sorts on
+	// payload fields go through json_extract. Time values are stored in
+	// the handler's TimeFormat, and the created field is read back as a time.Time like in
+	// column storage.
+	StorageJSON
+	// StorageHybrid stores the fields of a schema in their own column, and
+	// any other payload field as a JSON object in the ExtraColumn text
+	// column, merged back into the payload on read. It is set by
+	// WithHybridStorage.
+	StorageHybrid
+)
+
+// PayloadColumn is the column holding the JSON payload in StorageJSON mode.
+const PayloadColumn = "payload"
+
+// ExtraColumn is the column holding the payload fields without a column of
+// their own in StorageHybrid mode.
+const ExtraColumn = "extra"
+
+// WithStorageMode sets how item payloads are stored.
+func WithStorageMode(m StorageMode) Option {
+	return func(h *Handler) {
+		h.storage = m
+	}
+}
+
+// WithHybridStorage stores the fields of s in columns and the other payload
+// fields in the ExtraColumn JSON column. Filters and sorts on the other fields
+// go through json_extract. Tables created by EnsureTable get the extra
+// column.
+func WithHybridStorage(s schema.Schema) Option {
+	return func(h *Handler) {
+		h.storage = StorageHybrid
+		h.columns = map[string]bool{}
+		for name := range s {
+			h.columns[name] = true
+		}
+	}
+}
+
+// jsonColumn returns the column holding JSON encoded fields, if any.
+func (h *Handler) jsonColumn() string {
+	switch h.storage {
+	case StorageJSON:
+		return PayloadColumn
+	case StorageHybrid:
+		return ExtraColumn
+	}
+	return ""
+}
+
+// hasColumn reports whether a payload field is stored in its own column.
+func (h *Handler) hasColumn(field string) bool {
+	switch h.storage {
+	case StorageJSON:
+		return field == "id"
+	case StorageHybrid:
+		return field == "id" || h.columns[field]
+	}
+	return true
+}
+
+// fieldRef returns the SQL expression reading a field in filters and sorts.
+// Dotted names (e.g. address.city) refer to a path in a JSON sub-document
+// stored in the column of the first element.
+func (h *Handler) fieldRef(field string) string {
+	if ref, ok := h.computedRef(field); ok {
+		return ref
+	}
+	return h.storedRef(field)
+}
+
+// storedRef returns the SQL expression referencing a stored field.
+func (h *Handler) storedRef(field string) string {
+	if i := strings.IndexByte(field, '.'); i > 0 && h.hasColumn(field[:i]) {
+		path, _ := valueToString("$" + field[i:])
+		return "json_extract(" + h.column(field[:i]) + "," + path + ")"
+	}
+	if h.hasColumn(field) || isMetaColumn(field) {
+		return h.column(field)
+	}
+	path, _ := valueToString("$." + field)
+	return "json_extract(" + h.jsonColumn() + "," + path + ")"
+}
+
+// payloadLiteral returns the SQL literal of the JSON encoding of the payload
+// fields without a column. Fields colliding with meta columns are handled
+// like in column storage, namespaced fields get a prefixed key.
+func (h *Handler) payloadLiteral(p map[string]interface{}) (string, error) {
+	doc := make(map[string]interface{}, len(p))
+	for k, v := range p {
+		if h.hasColumn(k) {
+			continue
+		}
+		key, skip, err := h.payloadColumn(k)
+		if err != nil {
+			return "", err
+		}
+		if skip {
+			continue
+		}
+		if t, ok := v.(time.Time); ok {
+			v = h.timeFormat.value(t)
+		}
+		doc[key] = v
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return valueToString(string(b))
+}
+
+// mergePayload replaces the JSON column of a row by the fields it holds.
+// Numbers are read back like SQLite returns them: int64 if they are integral,
+// float64 otherwise.
+func (h *Handler) mergePayload(row map[string]interface{}) error {
+	col := h.jsonColumn()
+	if col == "" {
+		return nil
+	}
+	s, _ := row[col].(string)
+	delete(row, col)
+	if s == "" {
+		return nil
+	}
+	doc := map[string]interface{}{}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return fmt.Errorf("sqlite3: invalid payload: %v", err)
+	}
+	for k, v := range doc {
+		if v == nil && h.nullMode == NullExplicit {
+			continue
+		}
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				v = i
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			}
+		}
+		row[k] = v
+	}
+	return nil
+}
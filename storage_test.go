@@ -0,0 +1,160 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONStorage(t *testing.T) {
+	Convey("JSON storage should create a payload column", t, func() {
+		h := NewHandler(nil, "t", WithStorageMode(StorageJSON))
+		So(tableDDL(h, schema.Schema{"id": schema.IDField, "f1": schema.Field{}}), ShouldEqual,
+			"CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`payload` TEXT);")
+	})
+
+	Convey("Payload fields should be read with json_extract", t, func() {
+		s, err := callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo"}, schema.Equal{Field: "id", Value: "a"}},
+			WithStorageMode(StorageJSON))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "json_extract(payload,'$.f1') = 'foo' AND id = 'a'")
+
+		s, err = callGetSort("-f2", nil, WithStorageMode(StorageJSON))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "json_extract(payload,'$.f2') DESC")
+	})
+
+	Convey("Given a handler storing payloads as JSON", t, func() {
+		db, err := handler()
+		So(err, ShouldBeNil)
+		db.session.Exec(DB_DOWN_DDL)
+		h := NewHandler(db.session, DB_TABLE, WithStorageMode(StorageJSON))
+		So(h.EnsureTable(context.Background(), schema.Schema{"id": schema.IDField}), ShouldBeNil)
+		a, _ := item("foo", 1)
+		b, _ := item("bar", 2)
+		b.Payload["extra"] = map[string]interface{}{"x": true}
+		So(h.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+
+		Convey("Find should filter and sort on payload fields", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: 0}})
+			l.SetSort("-f2", nil)
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 2)
+			So(list.Items[0].ID, ShouldEqual, b.ID)
+			So(list.Items[0].ETag, ShouldEqual, b.ETag)
+			So(list.Items[0].Payload["f1"], ShouldEqual, "bar")
+			So(list.Items[0].Payload["f2"], ShouldEqual, int64(2))
+			So(list.Items[0].Payload["extra"], ShouldResemble, map[string]interface{}{"x": true})
+			So(list.Items[1].Payload["id"], ShouldEqual, a.ID)
+		})
+
+		Convey("Update should replace the payload", func() {
+			updated, _ := resource.NewItem(map[string]interface{}{"id": a.ID, "created": a.Payload["created"], "f1": "new"})
+			So(h.Update(context.Background(), updated, a), ShouldBeNil)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "new"}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			_, found := list.Items[0].Payload["f2"]
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Clear should match on payload fields", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo"}})
+			n, err := h.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestHybridStorage(t *testing.T) {
+	s := schema.Schema{
+		"id":      schema.IDField,
+		"created": schema.CreatedField,
+		"f1":      schema.Field{Validator: &schema.String{MaxLen: 128}},
+		"f2":      schema.Field{Validator: &schema.Integer{}},
+	}
+
+	Convey("Given a handler storing extra fields as JSON", t, func() {
+		db, err := handler()
+		So(err, ShouldBeNil)
+		db.session.Exec(DB_DOWN_DDL)
+		_, err = db.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		h := NewHandler(db.session, DB_TABLE, WithHybridStorage(s))
+		stmts, err := h.Migrate(context.Background(), s, MigrateOptions{})
+		So(err, ShouldBeNil)
+		So(stmts, ShouldResemble, []string{"ALTER TABLE `testtable` ADD COLUMN `extra` TEXT;"})
+
+		a, _ := item("foo", 1)
+		a.Payload["color"] = "red"
+		b, _ := item("bar", 2)
+		So(h.Insert(context.Background(), []*resource.Item{a, b}), ShouldBeNil)
+
+		Convey("Declared fields should be stored in columns", func() {
+			var f1, extra string
+			So(db.session.QueryRow("SELECT f1, extra FROM "+DB_TABLE+" WHERE id = ?", a.ID).Scan(&f1, &extra), ShouldBeNil)
+			So(f1, ShouldEqual, "foo")
+			So(extra, ShouldEqual, `{"color":"red"}`)
+		})
+
+		Convey("Find should merge extra fields and filter on them", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "color", Value: "red"}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].Payload["f1"], ShouldEqual, "foo")
+			So(list.Items[0].Payload["color"], ShouldEqual, "red")
+			_, found := list.Items[0].Payload[ExtraColumn]
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Update should replace the extra fields", func() {
+			updated, _ := resource.NewItem(map[string]interface{}{"id": a.ID, "created": a.Payload["created"], "f1": "foo", "size": 3})
+			So(h.Update(context.Background(), updated, a), ShouldBeNil)
+			var extra string
+			So(db.session.QueryRow("SELECT extra FROM "+DB_TABLE+" WHERE id = ?", a.ID).Scan(&extra), ShouldBeNil)
+			So(extra, ShouldEqual, `{"size":3}`)
+		})
+
+		Convey("The schema check should expect the extra column", func() {
+			drifts, err := h.CheckSchema(context.Background(), s)
+			So(err, ShouldBeNil)
+			So(drifts, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestNestedFields(t *testing.T) {
+	Convey("Given items with a JSON sub-document column", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		_, err = h.session.Exec("UPDATE "+DB_TABLE+` SET f1 = '{"address":{"city":"Lyon"}}' WHERE id = ?`, i1.ID)
+		So(err, ShouldBeNil)
+		_, err = h.session.Exec("UPDATE "+DB_TABLE+` SET f1 = '{"address":{"city":"Paris"}}' WHERE id = ?`, i2.ID)
+		So(err, ShouldBeNil)
+
+		Convey("Find should filter on a nested field", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1.address.city", Value: "Paris"}})
+			list, err := h.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, i2.ID)
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package sqlite3
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/rest-layer/schema"
+)
+
+// TimeFormat is the format of the timestamps stored by a handler: the updated
+// and created fields, soft deletion, tombstone and history times, and any
+// other time.Time payload value. Timestamps are always stored in UTC.
+type TimeFormat struct {
+	// layout is the time.Format layout of text timestamps
+	layout string
+	// unit is the unit of integer timestamps counted from the Unix epoch,
+	// zero for text timestamps
+	unit time.Duration
+}
+
+var (
+	// TimeDefault stores timestamps as text in the layout of time.Time's
+	// String method. This is the default.
+	TimeDefault = TimeFormat{layout: timeLayout}
+	// TimeRFC3339 stores timestamps as RFC 3339 text, with nanoseconds padded
+	// so the text order is the chronological order.
+	TimeRFC3339 = TimeFormat{layout: "2006-01-02T15:04:05.000000000Z07:00"}
+	// TimeUnix stores timestamps as integer seconds since the Unix epoch.
+	TimeUnix = TimeFormat{unit: time.Second}
+	// TimeUnixMilli stores timestamps as integer milliseconds since the Unix
+	// epoch.
+	TimeUnixMilli = TimeFormat{unit: time.Millisecond}
+)
+
+// TimeLayout returns a format storing timestamps as text in the given
+// time.Format layout. Range filters and sorts on timestamps compare the text,
+// so the layout should sort chronologically, with fixed width fields from the
+// year down.
+func TimeLayout(layout string) TimeFormat {
+	return TimeFormat{layout: layout}
+}
+
+// WithTimeFormat sets the format of the stored timestamps. It must match the
+// rows already in the table: changing it doesn't convert them. Integer formats
+// store less than the resolution of the handler's times, so items updated
+// within the same unit get the same updated time.
+func WithTimeFormat(f TimeFormat) Option {
+	return func(h *Handler) {
+		h.timeFormat = f
+	}
+}
+
+// textLayout returns the layout of a text format, the default one for the
+// zero TimeFormat.
+func (f TimeFormat) textLayout() string {
+	if f.layout == "" {
+		return timeLayout
+	}
+	return f.layout
+}
+
+// resolution returns the smallest difference between the stored times: the
+// unit of integer timestamps, timeResolution for text ones.
+func (f TimeFormat) resolution() time.Duration {
+	if f.unit > 0 {
+		return f.unit
+	}
+	return timeResolution
+}
+
+// value returns the stored value of a time, a string or an int64.
+func (f TimeFormat) value(t time.Time) schema.Value {
+	if f.unit > 0 {
+		return t.UnixNano() / int64(f.unit)
+	}
+	return t.UTC().Format(f.textLayout())
+}
+
+// literal returns the SQL literal of a time.
+func (f TimeFormat) literal(t time.Time) string {
+	s, _ := valueToString(f.value(t))
+	return s
+}
+
+// columnType returns the type of the columns holding timestamps.
+func (f TimeFormat) columnType() string {
+	if f.unit > 0 {
+		return "INTEGER"
+	}
+	return "VARCHAR(128)"
+}
+
+// parse converts a stored timestamp back to a time.
+func (f TimeFormat) parse(v interface{}) (time.Time, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if f.unit > 0 {
+		var n int64
+		switch t := v.(type) {
+		case int64:
+			n = t
+		case float64:
+			n = int64(t)
+		case string:
+			var err error
+			if n, err = strconv.ParseInt(t, 10, 64); err != nil {
+				return time.Time{}, err
+			}
+		default:
+			return time.Time{}, fmt.Errorf("sqlite3: invalid timestamp: %v", v)
+		}
+		return time.Unix(0, n*int64(f.unit)).UTC(), nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("sqlite3: invalid timestamp: %v", v)
+	}
+	return time.Parse(f.textLayout(), s)
+}
+
+// valueLiteral converts a payload value to SQL like valueToString, with
+// times in the handler's format.
+func (h *Handler) valueLiteral(v schema.Value) (string, error) {
+	if t, ok := v.(time.Time); ok {
+		return h.timeFormat.literal(t), nil
+	}
+	return valueToString(v)
+}
@@ -0,0 +1,116 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimeFormat(t *testing.T) {
+	at := time.Date(2016, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+	Convey("Times should be converted to the stored format", t, func() {
+		So(TimeDefault.literal(at), ShouldEqual, "'2016-01-02 03:04:05.6 +0000 UTC'")
+		So(TimeRFC3339.literal(at), ShouldEqual, "'2016-01-02T03:04:05.600000000Z'")
+		So(TimeUnix.literal(at), ShouldEqual, "1451703845")
+		So(TimeUnixMilli.literal(at), ShouldEqual, "1451703845600")
+		So(TimeLayout("20060102").literal(at), ShouldEqual, "'20160102'")
+
+		p, err := TimeUnixMilli.parse(int64(1451703845600))
+		So(err, ShouldBeNil)
+		So(p.Equal(at), ShouldBeTrue)
+		p, err = TimeRFC3339.parse([]byte("2016-01-02T03:04:05.600000000Z"))
+		So(err, ShouldBeNil)
+		So(p.Equal(at), ShouldBeTrue)
+		_, err = TimeUnix.parse("yesterday")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Timestamp filters should use the handler's format", t, func() {
+		s, err := callGetQuery(schema.Query{schema.GreaterThan{Field: "updated", Value: "2016-01-02T03:04:05Z"}}, WithTimeFormat(TimeUnix))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "updated > 1451703845")
+
+		s, err = callGetQuery(schema.Query{schema.LowerThan{Field: "created", Value: at}}, WithTimeFormat(TimeRFC3339))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "created < '2016-01-02T03:04:05.600000000Z'")
+
+		s, err = callGetQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: at}}, WithTimeFormat(TimeUnixMilli))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f2 > 1451703845600")
+
+		s, err = callGetQuery(schema.Query{schema.In{Field: "f2", Values: []schema.Value{at}}}, WithTimeFormat(TimeRFC3339))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f2 IN ('2016-01-02T03:04:05.600000000Z')")
+
+		// only timestamp fields parse strings as times
+		s, err = callGetQuery(schema.Query{schema.Equal{Field: "f1", Value: "2016-01-02T03:04:05Z"}}, WithTimeFormat(TimeUnix))
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "f1 = '2016-01-02T03:04:05Z'")
+	})
+
+	Convey("The DDL should store integer timestamps in INTEGER columns", t, func() {
+		s := schema.Schema{"f1": schema.Field{Validator: &schema.String{MaxLen: 128}}, "f2": schema.Field{Validator: &schema.Time{}}}
+		h := NewHandler(nil, "t", WithTimeFormat(TimeUnix))
+		So(tableDDL(h, s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` INTEGER,`created` INTEGER,`f1` VARCHAR(128),`f2` INTEGER);")
+		h = NewHandler(nil, "t", WithTimeFormat(TimeRFC3339))
+		So(tableDDL(h, s), ShouldEqual, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`f1` VARCHAR(128),`f2` VARCHAR(128));")
+	})
+
+	Convey("Given a table with unix timestamps", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec("CREATE TABLE `" + DB_TABLE + "` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` INTEGER,`created` INTEGER,`f1` VARCHAR(128),`f2` INTEGER);")
+		So(err, ShouldBeNil)
+		uh := NewHandler(h.session, DB_TABLE, WithTimeFormat(TimeUnix),
+			WithClock(ClockFunc(func() time.Time { return at })))
+
+		Convey("Items should be written and read back at the format's precision", func() {
+			i, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "foo"})
+			i.Updated = time.Time{}
+			So(uh.Insert(context.Background(), []*resource.Item{i}), ShouldBeNil)
+
+			var updated, created int64
+			So(h.session.QueryRow("SELECT updated, created FROM "+DB_TABLE+" WHERE id = 'a'").Scan(&updated, &created), ShouldBeNil)
+			So(updated, ShouldEqual, 1451703845)
+			So(created, ShouldEqual, 1451703845)
+
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterOrEqual{Field: "updated", Value: at.Add(-time.Second)}})
+			list, err := uh.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].Updated.Equal(at.Truncate(time.Second)), ShouldBeTrue)
+			So(list.Items[0].Payload["created"].(time.Time).Equal(at.Truncate(time.Second)), ShouldBeTrue)
+
+			u := list.Items[0]
+			n, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "bar"})
+			n.Updated = at.Add(time.Hour)
+			So(uh.Update(context.Background(), n, u), ShouldBeNil)
+			So(h.session.QueryRow("SELECT updated FROM "+DB_TABLE+" WHERE id = 'a'").Scan(&updated), ShouldBeNil)
+			So(updated, ShouldEqual, 1451707445)
+		})
+
+		Convey("Time payload values should be filtered in the stored format", func() {
+			i, _ := resource.NewItem(map[string]interface{}{"id": "a", "f2": at})
+			j, _ := resource.NewItem(map[string]interface{}{"id": "b", "f2": at.Add(time.Hour)})
+			So(uh.Insert(context.Background(), []*resource.Item{i, j}), ShouldBeNil)
+
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{
+				schema.GreaterThan{Field: "f2", Value: at.Add(time.Minute)},
+				schema.LowerOrEqual{Field: "f2", Value: at.Add(2 * time.Hour)},
+			})
+			list, err := uh.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			So(list.Items[0].ID, ShouldEqual, "b")
+		})
+	})
+}
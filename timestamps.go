@@ -0,0 +1,57 @@
+package sqlite3
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// createdColumn caches whether the handler's table has a created column. It
+// is shared by copies of the handler.
+type createdColumn struct {
+	mu     sync.Mutex
+	known  bool
+	exists bool
+}
+
+// storesCreated reports whether the created field of the items is stored.
+func (h *Handler) storesCreated(ctx context.Context) (bool, error) {
+	if !h.hasColumn("created") {
+		// stored with the JSON encoded fields
+		return true, nil
+	}
+	h.created.mu.Lock()
+	defer h.created.mu.Unlock()
+	if !h.created.known {
+		cols, err := columnTypes(ctx, h.session, h.tableName)
+		if err != nil {
+			return false, err
+		}
+		_, h.created.exists = cols[h.column("created")]
+		h.created.known = len(cols) > 0
+	}
+	return h.created.exists, nil
+}
+
+// setTimestamps sets the updated time of an item to insert if it is zero,
+// and its created field to the updated time if the payload has none and the
+// table stores it. Both are stored in the handler's timestamp format.
+func (h *Handler) setTimestamps(ctx context.Context, i *resource.Item) error {
+	if i.Updated.IsZero() {
+		i.Updated = h.clock.Now()
+	}
+	if _, found := i.Payload["created"]; found {
+		return nil
+	}
+	stored, err := h.storesCreated(ctx)
+	if err != nil || !stored {
+		return err
+	}
+	if i.Payload == nil {
+		i.Payload = map[string]interface{}{}
+	}
+	i.Payload["created"] = i.Updated
+	return nil
+}
@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimestamps(t *testing.T) {
+	Convey("Given an empty table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		Convey("Insert should set created from the item update time", func() {
+			it, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "foo"})
+			So(h.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+			So(it.Payload["created"], ShouldEqual, it.Updated)
+
+			var created string
+			So(h.session.QueryRow("SELECT created FROM "+DB_TABLE+" WHERE id = 'a'").Scan(&created), ShouldBeNil)
+			So(created, ShouldEqual, formatTime(it.Updated))
+
+			list, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(list.Items[0].Payload["created"].(time.Time).Equal(it.Updated), ShouldBeTrue)
+		})
+
+		Convey("Insert should set a zero update time", func() {
+			it := &resource.Item{ID: "a", ETag: "x", Payload: map[string]interface{}{"id": "a"}}
+			So(h.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+			So(it.Updated.IsZero(), ShouldBeFalse)
+		})
+
+		Convey("Tables without a created column should be supported", func() {
+			_, err := h.session.Exec("CREATE TABLE IF NOT EXISTS nocreated (id VARCHAR(128) PRIMARY KEY, etag VARCHAR(128), updated VARCHAR(128), f1 TEXT);")
+			So(err, ShouldBeNil)
+			defer h.session.Exec("DROP TABLE nocreated;")
+			nh := NewHandler(h.session, "nocreated")
+			it, _ := resource.NewItem(map[string]interface{}{"id": "a", "f1": "foo"})
+			So(nh.Insert(context.Background(), []*resource.Item{it}), ShouldBeNil)
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f1", Value: "foo"}})
+			list, err := nh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(len(list.Items), ShouldEqual, 1)
+			_, found := list.Items[0].Payload["created"]
+			So(found, ShouldBeFalse)
+		})
+	})
+}
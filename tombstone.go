@@ -0,0 +1,107 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Tombstone records the deletion of an item, so offline clients can learn
+// about items deleted since their last synchronization.
+type Tombstone struct {
+	ID      interface{}
+	ETag    string
+	Deleted time.Time
+}
+
+// WithTombstones makes Delete and Clear record a Tombstone for every removed
+// item in the <table>_tombstones table, created with CreateTombstoneTable.
+func WithTombstones() Option {
+	return func(h *Handler) {
+		h.tombstones = true
+	}
+}
+
+// tombstoneTable returns the name of the handler's tombstone table.
+func tombstoneTable(h *Handler) string {
+	return h.tableName + "_tombstones"
+}
+
+// CreateTombstoneTable creates the handler's tombstone table if it does not
+// exist yet.
+func (h *Handler) CreateTombstoneTable(ctx context.Context) error {
+	t := tombstoneTable(h)
+	for _, s := range []string{
+		"CREATE TABLE IF NOT EXISTS `" + t + "` (`id` VARCHAR(128),`etag` VARCHAR(128),`deleted` " + h.timeFormat.columnType() + ");",
+		"CREATE INDEX IF NOT EXISTS `" + t + "_deleted` ON `" + t + "` (`deleted`);",
+	} {
+		if _, err := h.session.ExecContext(ctx, s); err != nil {
+			log.WithFields(log.Fields{
+				"table": t,
+				"error": err,
+			}).Warn("Error creating tombstone table.")
+			return err
+		}
+	}
+	return nil
+}
+
+// Tombstones returns the tombstones recorded at or after since, oldest first.
+func (h *Handler) Tombstones(ctx context.Context, since time.Time) ([]Tombstone, error) {
+	return tombstonesBetween(ctx, h, h.session, since, time.Time{})
+}
+
+// tombstonesBetween reads the tombstones recorded at or after since and before
+// until, or without upper bound if until is zero.
+func tombstonesBetween(ctx context.Context, h *Handler, db querier, since, until time.Time) ([]Tombstone, error) {
+	where := fmt.Sprintf("deleted >= %s", h.timeFormat.literal(since))
+	if !until.IsZero() {
+		where += fmt.Sprintf(" AND deleted < %s", h.timeFormat.literal(until))
+	}
+	q := fmt.Sprintf("SELECT id,etag,deleted FROM %s WHERE %s ORDER BY deleted;", tombstoneTable(h), where)
+	rows, err := db.QueryContext(ctx, h.annotate(ctx, q))
+	if err != nil {
+		log.WithField("error", err).Warn("Error querying tombstones.")
+		return nil, ctxErr(ctx, err)
+	}
+	defer rows.Close()
+
+	ts := []Tombstone{}
+	for rows.Next() {
+		var id interface{}
+		var etag sql.NullString
+		var deleted interface{}
+		if err = rows.Scan(&id, &etag, &deleted); err != nil {
+			log.WithField("error", err).Warn("Error scanning tombstone.")
+			return nil, err
+		}
+		if b, ok := id.([]byte); ok {
+			id = string(b)
+		}
+		t := Tombstone{ETag: etag.String}
+		if t.ID, err = h.idCodec.Decode(id); err != nil {
+			return nil, err
+		}
+		if t.Deleted, err = h.timeFormat.parse(deleted); err != nil {
+			log.WithField("error", err).Warn("Error parsing tombstone time.")
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, ctxErr(ctx, rows.Err())
+}
+
+// getTombstoneInsert returns a statement recording tombstones for the rows
+// matching the WHERE clause.
+func getTombstoneInsert(h *Handler, where string, deleted time.Time) string {
+	etag := h.column("etag")
+	if h.etagMode == ETagNone {
+		etag = "NULL"
+	}
+	return fmt.Sprintf("INSERT INTO %s(id,etag,deleted) SELECT id,%s,%s FROM %s WHERE %s;",
+		tombstoneTable(h), etag, h.timeFormat.literal(deleted), h.readSource(), where)
+}
@@ -0,0 +1,52 @@
+package sqlite3
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTombstones(t *testing.T) {
+	Convey("Given a handler recording tombstones", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		th := NewHandler(h.session, DB_TABLE, WithTombstones())
+		h.session.Exec("DROP TABLE `" + tombstoneTable(th) + "`;")
+		So(th.CreateTombstoneTable(context.Background()), ShouldBeNil)
+		So(th.Insert(context.Background(), []*resource.Item{i1, i2}), ShouldBeNil)
+		start := time.Now().Add(-time.Second)
+
+		Convey("Delete should record a tombstone", func() {
+			So(th.Delete(context.Background(), i1), ShouldBeNil)
+			ts, err := th.Tombstones(context.Background(), start)
+			So(err, ShouldBeNil)
+			So(len(ts), ShouldEqual, 1)
+			So(ts[0].ID, ShouldEqual, i1.ID)
+			So(ts[0].ETag, ShouldEqual, i1.ETag)
+			So(ts[0].Deleted.After(start), ShouldBeTrue)
+
+			ts, err = th.Tombstones(context.Background(), time.Now().Add(time.Minute))
+			So(err, ShouldBeNil)
+			So(len(ts), ShouldEqual, 0)
+		})
+
+		Convey("Clear should record a tombstone per removed item", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.GreaterThan{Field: "f2", Value: 0}})
+			n, err := th.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			ts, err := th.Tombstones(context.Background(), start)
+			So(err, ShouldBeNil)
+			So(len(ts), ShouldEqual, 2)
+		})
+	})
+}
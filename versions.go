@@ -0,0 +1,71 @@
+package sqlite3
+
+import (
+	"fmt"
+)
+
+// PreviousVersion describes the table holding the items of the previous
+// version of a resource schema (e.g. items_v1 for a handler on items_v2).
+// Items not migrated yet are read through the handler of the current version,
+// converted by the Columns select list, so both API versions can be served
+// while the items are migrated gradually.
+type PreviousVersion struct {
+	// Table is the table of the previous version.
+	Table string
+	// Columns is the select list converting a row of Table into a row of the
+	// current table, with the columns in the current table's order, e.g.
+	// "id,etag,updated,created,name AS title,0 AS views".
+	Columns string
+}
+
+// WithPreviousVersion makes the handler read the items of the previous
+// version table that have no row in its own table yet. Filters, sorts and
+// pagination apply to the converted rows. Updating an old item first copies it
+// into the current table, and deleting or clearing items removes them from
+// both tables. Index hints are not supported on such handlers.
+func WithPreviousVersion(p PreviousVersion) Option {
+	return func(h *Handler) {
+		h.previous = &p
+	}
+}
+
+// readSource returns the table expression the handler reads items from.
+func (h *Handler) readSource() string {
+	if h.previous == nil {
+		return h.tableName
+	}
+	return fmt.Sprintf("(SELECT * FROM %s UNION ALL SELECT %s FROM %s WHERE id NOT IN (SELECT id FROM %s))",
+		h.tableName, h.previous.Columns, h.previous.Table, h.tableName)
+}
+
+// readRef returns the table reference of a select statement with the index
+// hints applied.
+func (h *Handler) readRef(hints Hints) (string, error) {
+	if h.previous == nil {
+		return hints.tableRef(h.tableName)
+	}
+	if hints.IndexedBy != "" || hints.NotIndexed {
+		return "", fmt.Errorf("sqlite3: index hints are not supported with a previous version table")
+	}
+	return h.readSource(), nil
+}
+
+// getUpgrade returns the statements moving an item of the previous version
+// table into the current table, if it is not there yet.
+func getUpgrade(h *Handler, id string) []string {
+	return []string{
+		fmt.Sprintf("INSERT OR IGNORE INTO %s SELECT %s FROM %s WHERE id=%s;",
+			h.tableName, h.previous.Columns, h.previous.Table, id),
+		fmt.Sprintf("DELETE FROM %s WHERE id=%s;", h.previous.Table, id),
+	}
+}
+
+// getPreviousDelete returns a statement removing the items of the previous
+// version table that match the WHERE clause once converted.
+func getPreviousDelete(h *Handler, where string) string {
+	if where == "" {
+		return fmt.Sprintf("DELETE FROM %s;", h.previous.Table)
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE %s);",
+		h.previous.Table, h.readSource(), where)
+}
@@ -0,0 +1,86 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPreviousVersion(t *testing.T) {
+	Convey("Given items in the current and previous version tables", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		h.session.Exec("DROP TABLE `testtable_v1`;")
+		_, err = h.session.Exec("CREATE TABLE `testtable_v1` (`id` VARCHAR(128) PRIMARY KEY,`etag` VARCHAR(128),`updated` VARCHAR(128),`created` VARCHAR(128),`name` VARCHAR(128));")
+		So(err, ShouldBeNil)
+
+		old, _ := item("old", 0)
+		delete(old.Payload, "f1")
+		delete(old.Payload, "f2")
+		old.Payload["name"] = "old"
+		So(NewHandler(h.session, "testtable_v1").Insert(context.Background(), []*resource.Item{old}), ShouldBeNil)
+		So(h.Insert(context.Background(), []*resource.Item{i1}), ShouldBeNil)
+
+		vh := NewHandler(h.session, DB_TABLE, WithPreviousVersion(PreviousVersion{
+			Table:   "testtable_v1",
+			Columns: "id,etag,updated,created,name AS f1,0 AS f2",
+		}))
+
+		Convey("Find should return converted previous version items", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "f2", Value: 0}})
+			result, err := vh.Find(context.Background(), l, 1, 10)
+			So(err, ShouldBeNil)
+			So(result.Total, ShouldEqual, 1)
+			So(result.Items[0].ID, ShouldEqual, old.ID)
+			So(result.Items[0].Payload["f1"], ShouldEqual, "old")
+
+			result, err = vh.Find(context.Background(), resource.NewLookup(), 1, 1)
+			So(err, ShouldBeNil)
+			So(result.Total, ShouldEqual, 2)
+
+			_, err = vh.Find(NewHintsContext(context.Background(), Hints{NotIndexed: true}), l, 1, 10)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Update should move the item to the current table", func() {
+			updated, _ := item("new", 5)
+			updated.Payload["id"] = old.ID
+			updated.ID = old.ID
+			updated.Payload["created"] = old.Payload["created"]
+			So(vh.Update(context.Background(), updated, old), ShouldBeNil)
+
+			result, err := h.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 2)
+			var n int
+			So(h.session.QueryRow("SELECT COUNT(*) FROM testtable_v1;").Scan(&n), ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("Delete should remove a previous version item", func() {
+			So(vh.Delete(context.Background(), old), ShouldBeNil)
+			result, err := vh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 1)
+		})
+
+		Convey("Clear should remove matching items of both tables", func() {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.LowerThan{Field: "f2", Value: 10}})
+			n, err := vh.Clear(context.Background(), l)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			result, err := vh.Find(context.Background(), resource.NewLookup(), 1, 10)
+			So(err, ShouldBeNil)
+			So(len(result.Items), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,83 @@
+package sqlite3
+
+import (
+	"database/sql"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultWarmupConns is the number of connections opened by Warmup when the
+// pool has no maximum. It matches the default number of idle connections kept
+// by database/sql, so the warmed connections stay in the pool.
+const DefaultWarmupConns = 2
+
+// Warmup opens the pool's connections, pings them, applies the configured
+// pragmas and prepares the hot statements of the handler (select, insert and
+// delete by id) on each of them, so the first requests after a deploy don't
+// pay for opening connections and loading the schema. Call it once after
+// creating the handler, before serving requests.
+//
+// Warmup opens as many connections as the pool's maximum, or
+// DefaultWarmupConns if it has none. Statements built by the handler embed
+// their values, so the prepared statements are not kept: preparing them loads
+// and parses the schema in each connection.
+func (h *Handler) Warmup(ctx context.Context) error {
+	stmts := []string{
+		"SELECT * FROM " + h.tableName + " WHERE id = ?;",
+		"INSERT INTO " + h.tableName + " SELECT * FROM " + h.tableName + " WHERE 0;",
+		"DELETE FROM " + h.tableName + " WHERE id = ?;",
+	}
+
+	return h.eachConn(ctx, func(c *sql.Conn) error {
+		if err := applyPragmas(ctx, c, h.pragmas); err != nil {
+			return err
+		}
+		for _, s := range stmts {
+			stmt, err := c.PrepareContext(ctx, s)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"table": h.tableName,
+					"error": err,
+				}).Warn("Error preparing statement.")
+				return err
+			}
+			stmt.Close()
+		}
+		return nil
+	})
+}
+
+// eachConn runs fn on as many connections of the pool as its maximum, or
+// DefaultWarmupConns if it has none, after pinging them.
+func (h *Handler) eachConn(ctx context.Context, fn func(c *sql.Conn) error) error {
+	n := h.session.Stats().MaxOpenConnections
+	if n <= 0 {
+		n = DefaultWarmupConns
+	}
+	// hold every connection until the end, so each one is a different
+	// connection of the pool.
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		c, err := h.session.Conn(ctx)
+		if err != nil {
+			log.WithField("error", err).Warn("Error opening connection.")
+			return err
+		}
+		conns = append(conns, c)
+		if err = c.PingContext(ctx); err != nil {
+			log.WithField("error", err).Warn("Error pinging connection.")
+			return err
+		}
+		if err = fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
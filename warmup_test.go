@@ -0,0 +1,43 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWarmup(t *testing.T) {
+	Convey("Given a handler on the test table", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+
+		Convey("Warmup should open and prepare the pool connections", func() {
+			So(h.Warmup(context.Background()), ShouldBeNil)
+			So(h.session.Stats().OpenConnections, ShouldBeGreaterThanOrEqualTo, DefaultWarmupConns)
+		})
+
+		Convey("Warmup should apply the pragmas on each connection", func() {
+			h.session.SetMaxOpenConns(1)
+			ph := NewHandler(h.session, DB_TABLE, WithPragma("cache_size", "-4000"))
+			So(ph.Warmup(context.Background()), ShouldBeNil)
+			var size int
+			So(h.session.QueryRow("PRAGMA cache_size;").Scan(&size), ShouldBeNil)
+			So(size, ShouldEqual, -4000)
+		})
+
+		Convey("Warmup should reject invalid pragmas", func() {
+			ph := NewHandler(h.session, DB_TABLE, WithPragma("cache_size", "1; DROP TABLE x"))
+			So(ph.Warmup(context.Background()), ShouldNotBeNil)
+		})
+
+		Convey("Warmup should fail on a missing table", func() {
+			mh := NewHandler(h.session, "missingtable")
+			So(mh.Warmup(context.Background()), ShouldNotBeNil)
+		})
+	})
+}
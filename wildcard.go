@@ -0,0 +1,62 @@
+package sqlite3
+
+import (
+	"regexp"
+	"strings"
+)
+
+// EqualityMode sets how Equal and NotEqual filters compare strings.
+type EqualityMode int
+
+const (
+	// EqualityExact compares strings with = and <>, so a value only matches
+	// itself. Pattern matching is left to Wildcard and Regex filters. This is
+	// the default.
+	EqualityExact EqualityMode = iota
+	// EqualityPattern compares strings with LIKE, where * is a wildcard and
+	// ASCII letters match regardless of case, like earlier versions of the
+	// handler did. It is meant for APIs whose clients rely on it.
+	EqualityPattern
+)
+
+// WithEqualityMode sets how Equal and NotEqual filters compare strings.
+func WithEqualityMode(m EqualityMode) Option {
+	return func(h *Handler) {
+		h.equality = m
+	}
+}
+
+// Wildcard is a filter expression matching the string values of a field
+// against a pattern where * matches any sequence of characters. Like SQLite's
+// LIKE, ASCII letters match regardless of case. Other characters, % and _
+// included, match themselves.
+type Wildcard struct {
+	Field string
+	Value string
+}
+
+// Match reports whether the payload matches the pattern, so Wildcard can be
+// evaluated in memory like the rest-layer expressions.
+func (e Wildcard) Match(payload map[string]interface{}) bool {
+	s, ok := payload[e.Field].(string)
+	if !ok {
+		return false
+	}
+	// foldCase only folds ASCII letters, as LIKE does: other letters only
+	// match themselves, so SQL and memory agree on non-ASCII values
+	parts := strings.Split(e.Value, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(foldCase(p))
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, "(?s:.*)") + "$")
+	return err == nil && re.MatchString(foldCase(s))
+}
+
+// likePattern returns the LIKE pattern, escaped with \, matching the values
+// of a wildcard pattern.
+func likePattern(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, "%", `\%`, -1)
+	v = strings.Replace(v, "_", `\_`, -1)
+	return strings.Replace(v, "*", "%", -1)
+}
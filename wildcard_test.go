@@ -0,0 +1,83 @@
+package sqlite3
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWildcard(t *testing.T) {
+	Convey("Wildcard filters should only treat * as a wildcard", t, func() {
+		s, err := callGetQuery(schema.Query{Wildcard{Field: "f1", Value: `50%_a\b*`}})
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, `f1 LIKE '50\%\_a\\b%' ESCAPE '\'`)
+	})
+
+	Convey("Wildcard filters should match in memory", t, func() {
+		p := map[string]interface{}{"f1": "Foo.Bar"}
+		So(Wildcard{Field: "f1", Value: "foo*"}.Match(p), ShouldBeTrue)
+		So(Wildcard{Field: "f1", Value: "*.bar"}.Match(p), ShouldBeTrue)
+		So(Wildcard{Field: "f1", Value: "foo"}.Match(p), ShouldBeFalse)
+		So(Wildcard{Field: "f1", Value: "f..*"}.Match(p), ShouldBeFalse)
+		So(Wildcard{Field: "f2", Value: "*"}.Match(p), ShouldBeFalse)
+	})
+
+	Convey("Given stored items with pattern characters", t, func() {
+		h, err := handler()
+		So(err, ShouldBeNil)
+		h.session.Exec(DB_DOWN_DDL)
+		_, err = h.session.Exec(DB_UP_DDL)
+		So(err, ShouldBeNil)
+		a, _ := item("100%", 1)
+		b, _ := item("1000", 2)
+		c, _ := item("FOO", 3)
+		So(h.Insert(context.Background(), []*resource.Item{a, b, c}), ShouldBeNil)
+
+		find := func(h *Handler, exp schema.Expression) []*resource.Item {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{exp})
+			list, err := h.Find(context.Background(), l, 1, -1)
+			So(err, ShouldBeNil)
+			return list.Items
+		}
+
+		Convey("Equal should match values exactly", func() {
+			So(find(h, schema.Equal{Field: "f1", Value: "100%"}), ShouldHaveLength, 1)
+			So(find(h, schema.Equal{Field: "f1", Value: "foo"}), ShouldHaveLength, 0)
+			So(find(h, schema.NotEqual{Field: "f1", Value: "100%"}), ShouldHaveLength, 2)
+		})
+
+		Convey("Wildcard should match patterns", func() {
+			So(find(h, Wildcard{Field: "f1", Value: "100%"}), ShouldHaveLength, 1)
+			So(find(h, Wildcard{Field: "f1", Value: "10*"}), ShouldHaveLength, 2)
+			So(find(h, Wildcard{Field: "f1", Value: "foo"}), ShouldHaveLength, 1)
+		})
+
+		Convey("Wildcard should fold ASCII letters only, in SQL and in memory", func() {
+			d, _ := item("École", 4)
+			e, _ := item("ÉCOLE", 5)
+			So(h.Insert(context.Background(), []*resource.Item{d, e}), ShouldBeNil)
+			for pattern, n := range map[string]int{"école*": 0, "École": 2, "*COLE": 2, "éCOLE": 0} {
+				w := Wildcard{Field: "f1", Value: pattern}
+				So(find(h, w), ShouldHaveLength, n)
+				matched := 0
+				for _, i := range []*resource.Item{d, e} {
+					if w.Match(i.Payload) {
+						matched++
+					}
+				}
+				So(matched, ShouldEqual, n)
+			}
+		})
+
+		Convey("The compatibility mode should match patterns with Equal", func() {
+			ph := NewHandler(h.session, DB_TABLE, WithEqualityMode(EqualityPattern))
+			So(find(ph, schema.Equal{Field: "f1", Value: "foo"}), ShouldHaveLength, 1)
+			So(find(ph, schema.Equal{Field: "f1", Value: "10*"}), ShouldHaveLength, 2)
+		})
+	})
+}